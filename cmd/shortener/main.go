@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/SversusN/shortener/internal/app"
+	"github.com/SversusN/shortener/internal/buildinfo"
 )
 
 var (
@@ -19,5 +20,5 @@ func main() {
 		buildDate,
 		buildCommit,
 	)
-	app.New().Run()
+	app.New(buildinfo.New(buildVersion, buildDate, buildCommit)).Run()
 }
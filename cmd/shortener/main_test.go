@@ -1,22 +1,47 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"io"
 	"log"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 
+	"github.com/SversusN/shortener/config"
+	"github.com/SversusN/shortener/internal/accounts"
+	"github.com/SversusN/shortener/internal/admin"
+	"github.com/SversusN/shortener/internal/apikeys"
 	"github.com/SversusN/shortener/internal/app"
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/buildinfo"
+	"github.com/SversusN/shortener/internal/clientip"
 	"github.com/SversusN/shortener/internal/handlers"
+	"github.com/SversusN/shortener/internal/health"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/metrics"
+	"github.com/SversusN/shortener/internal/oidc"
+	"github.com/SversusN/shortener/internal/oidc/oidctest"
+	"github.com/SversusN/shortener/internal/quota"
+	"github.com/SversusN/shortener/internal/ratelimit"
+	"github.com/SversusN/shortener/internal/storage/dbstorage"
 	"github.com/SversusN/shortener/internal/storage/primitivestorage"
+	"github.com/SversusN/shortener/internal/tlsconf"
+	"github.com/SversusN/shortener/internal/tlsconf/tlstest"
+	"github.com/SversusN/shortener/internal/workspaces"
 )
 
 func testRequest(t *testing.T, ts *httptest.Server, method, path string, body string) (*http.Response, string) {
@@ -42,14 +67,14 @@ func testRequest(t *testing.T, ts *httptest.Server, method, path string, body st
 }
 
 func TestRouter(t *testing.T) {
-	a := app.New()
+	a := app.New(buildinfo.New("test", "N/A", "N/A"))
 	//хенлеры проверяем не портим БД
 	a.Storage = nil
 	a.Storage = primitivestorage.NewStorage(nil, errors.New("dont need file"))
 	//Для хендлеров тоже мап
 	wg := &sync.WaitGroup{}
-	a.Handlers = handlers.NewHandlers(a.Config, a.Storage, wg)
-	a.Storage.SetURL("sk", "http://example.com", uuid.NewString())
+	a.Handlers = handlers.NewHandlers(a.Config, a.Storage, wg, a.BuildInfo)
+	a.Storage.SetURL(context.Background(), "sk", "http://example.com", dbstorage.Owner{UserID: uuid.NewString()})
 	s := httptest.NewServer(a.CreateRouter(*a.Handlers))
 
 	defer s.Close()
@@ -122,6 +147,18 @@ func TestRouter(t *testing.T) {
 			path:         "/api/internal/stats",
 			expectedCode: http.StatusForbidden,
 		},
+		{
+			name:         "Forbidden time series",
+			method:       http.MethodGet,
+			path:         "/api/internal/stats/timeseries?interval=day",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Forbidden version",
+			method:       http.MethodGet,
+			path:         "/api/internal/version",
+			expectedCode: http.StatusForbidden,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -146,3 +183,589 @@ func TestRouter(t *testing.T) {
 	//на всякий обнуляем конвеер
 	a = nil
 }
+
+func TestRouterTLS(t *testing.T) {
+	ca := tlstest.NewCA(t, "shortener-test-ca")
+	pair := ca.Server(t)
+	tp, err := tlsconf.New(tlsconf.Options{CertFile: pair.CertFile, KeyFile: pair.KeyFile})
+	require.NoError(t, err)
+
+	cfg := &config.Config{FlagBaseAddress: "https://localhost", EnableHTTPS: true}
+	st := primitivestorage.NewStorage(nil, errors.New("dont need file"))
+	tokens, err := auth.New(auth.Options{TTL: time.Hour})
+	require.NoError(t, err)
+	a := &app.App{
+		Config:   cfg,
+		Storage:  st,
+		Handlers: handlers.NewHandlers(cfg, st, &sync.WaitGroup{}, buildinfo.New("test", "N/A", "N/A")),
+		Logger:   &logger.ServerLogger{Logger: zap.NewNop()},
+		Metrics:  metrics.New(),
+		Health:   health.New(),
+		TLS:      tp,
+		Tokens:   tokens,
+	}
+	s := httptest.NewUnstartedServer(a.CreateRouter(*a.Handlers))
+	s.TLS = a.TLS.Config
+	s.StartTLS()
+	defer s.Close()
+
+	//Клиент доверяет только тестовому CA, а не сертификату httptest
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: ca.Pool}}}
+	resp, err := client.Post(s.URL+"/", "text/plain", strings.NewReader("https://example.com/tls"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(string(body), "https://localhost/"))
+	assert.GreaterOrEqual(t, resp.TLS.Version, uint16(tls.VersionTLS12))
+
+	resp, err = client.Get(s.URL + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	//Проверки балансировщика не выдают анонимный токен
+	assert.Empty(t, resp.Cookies())
+}
+
+func TestAccounts(t *testing.T) {
+	cfg := &config.Config{FlagBaseAddress: "http://localhost"}
+	st := primitivestorage.NewStorage(nil, errors.New("dont need file"))
+	tokens, err := auth.New(auth.Options{TTL: time.Hour})
+	require.NoError(t, err)
+	accts, err := accounts.New(st, tokens, bcrypt.MinCost)
+	require.NoError(t, err)
+	a := &app.App{
+		Config:   cfg,
+		Storage:  st,
+		Handlers: handlers.NewHandlers(cfg, st, &sync.WaitGroup{}, buildinfo.New("test", "N/A", "N/A")),
+		Logger:   &logger.ServerLogger{Logger: zap.NewNop()},
+		Metrics:  metrics.New(),
+		Health:   health.New(),
+		Tokens:   tokens,
+		Accounts: accts,
+	}
+	s := httptest.NewServer(a.CreateRouter(*a.Handlers))
+	defer s.Close()
+
+	newClient := func() *http.Client {
+		jar, err := cookiejar.New(nil)
+		require.NoError(t, err)
+		return &http.Client{Jar: jar}
+	}
+	do := func(c *http.Client, method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+	creds := `{"login":"alice","password":"correct horse"}`
+
+	//Ссылка создается под зарегистрированным пользователем
+	browser := newClient()
+	code, _ := do(browser, http.MethodPost, "/api/auth/register", creds)
+	require.Equal(t, http.StatusCreated, code)
+	code, _ = do(browser, http.MethodPost, "/", "https://example.com/account")
+	require.Equal(t, http.StatusCreated, code)
+	code, _ = do(browser, http.MethodPost, "/api/auth/register", creds)
+	assert.Equal(t, http.StatusConflict, code)
+
+	//В другом браузере ссылки доступны после входа
+	other := newClient()
+	code, _ = do(other, http.MethodPost, "/api/auth/login", `{"login":"alice","password":"wrong password"}`)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = do(other, http.MethodPost, "/api/auth/login", creds)
+	require.Equal(t, http.StatusOK, code)
+	code, body := do(other, http.MethodGet, "/api/user/urls", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "https://example.com/account")
+
+	//После выхода кука удалена
+	code, _ = do(other, http.MethodPost, "/api/auth/logout", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = do(other, http.MethodGet, "/api/user/urls", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	//Анонимные ссылки переносятся при входе по запросу, повтор ничего не меняет
+	anon := newClient()
+	code, _ = do(anon, http.MethodPost, "/", "https://example.com/anonymous")
+	require.Equal(t, http.StatusCreated, code)
+	claim := `{"login":"alice","password":"correct horse","claim_anonymous":true}`
+	code, body = do(anon, http.MethodPost, "/api/auth/login", claim)
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"claimed":1`)
+	code, body = do(anon, http.MethodPost, "/api/auth/login", claim)
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"claimed":0`)
+	code, body = do(anon, http.MethodGet, "/api/user/urls", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "https://example.com/anonymous")
+	assert.Contains(t, body, "https://example.com/account")
+}
+
+func TestAPIKeys(t *testing.T) {
+	cfg := &config.Config{FlagBaseAddress: "http://localhost"}
+	st := primitivestorage.NewStorage(nil, errors.New("dont need file"))
+	tokens, err := auth.New(auth.Options{TTL: time.Hour})
+	require.NoError(t, err)
+	a := &app.App{
+		Config:   cfg,
+		Storage:  st,
+		Handlers: handlers.NewHandlers(cfg, st, &sync.WaitGroup{}, buildinfo.New("test", "N/A", "N/A")),
+		Logger:   &logger.ServerLogger{Logger: zap.NewNop()},
+		Metrics:  metrics.New(),
+		Health:   health.New(),
+		Tokens:   tokens,
+		APIKeys:  apikeys.New(st, st),
+	}
+	s := httptest.NewServer(a.CreateRouter(*a.Handlers))
+	defer s.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	browser := &http.Client{Jar: jar}
+	do := func(c *http.Client, method, path, key, body string) (int, string) {
+		req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if key != "" {
+			req.Header.Set(apikeys.Header, key)
+		}
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+
+	//Ключ выпускается из сессии пользователя, секрет виден только в ответе на создание
+	code, body := do(browser, http.MethodPost, "/api/user/keys", "", `{"name":"ci","scopes":["shorten"]}`)
+	require.Equal(t, http.StatusCreated, code)
+	var created struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	require.True(t, apikeys.IsAPIKey(created.Key))
+
+	//Ключ работает только в своих областях доступа и не управляет ключами
+	code, _ = do(http.DefaultClient, http.MethodPost, "/api/shorten/batch", created.Key,
+		`[{"correlation_id":"1","original_url":"https://example.com/key"}]`)
+	assert.Equal(t, http.StatusCreated, code)
+	code, _ = do(http.DefaultClient, http.MethodGet, "/api/user/urls", created.Key, "")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = do(http.DefaultClient, http.MethodGet, "/api/user/keys", created.Key, "")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	//Ссылки ключа принадлежат пользователю
+	code, body = do(browser, http.MethodGet, "/api/user/urls", "", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "https://example.com/key")
+	code, body = do(browser, http.MethodGet, "/api/user/keys", "", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, created.ID)
+	assert.NotContains(t, body, created.Key)
+
+	//Отозванный ключ отклоняется
+	code, _ = do(browser, http.MethodDelete, "/api/user/keys/"+created.ID, "", "")
+	require.Equal(t, http.StatusNoContent, code)
+	code, _ = do(http.DefaultClient, http.MethodPost, "/api/shorten", created.Key, `{"url":"https://example.com/revoked"}`)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = do(browser, http.MethodDelete, "/api/user/keys/"+created.ID, "", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	cfg := &config.Config{FlagBaseAddress: "http://localhost"}
+	st := primitivestorage.NewStorage(nil, errors.New("dont need file"))
+	tokens, err := auth.New(auth.Options{TTL: time.Hour})
+	require.NoError(t, err)
+	accts, err := accounts.New(st, tokens, bcrypt.MinCost)
+	require.NoError(t, err)
+	//Адрес возврата известен только после запуска сервера
+	s := httptest.NewServer(http.NotFoundHandler())
+	defer s.Close()
+	idp := oidctest.New(t, "shortener", "secret")
+	provider, err := oidc.New(oidc.Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     "shortener",
+		ClientSecret: "secret",
+		RedirectURL:  s.URL + "/api/auth/oidc/callback",
+	}, nil)
+	require.NoError(t, err)
+	a := &app.App{
+		Config:   cfg,
+		Storage:  st,
+		Handlers: handlers.NewHandlers(cfg, st, &sync.WaitGroup{}, buildinfo.New("test", "N/A", "N/A")),
+		Logger:   &logger.ServerLogger{Logger: zap.NewNop()},
+		Metrics:  metrics.New(),
+		Health:   health.New(),
+		Tokens:   tokens,
+		Accounts: accts,
+		OIDC:     provider,
+	}
+	s.Config.Handler = a.CreateRouter(*a.Handlers)
+
+	newClient := func() *http.Client {
+		jar, err := cookiejar.New(nil)
+		require.NoError(t, err)
+		return &http.Client{Jar: jar}
+	}
+	do := func(c *http.Client, method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+	var session struct {
+		UserID  string `json:"user_id"`
+		Claimed int    `json:"claimed"`
+	}
+
+	//Вход через провайдер с переносом анонимных ссылок
+	browser := newClient()
+	code, _ := do(browser, http.MethodPost, "/", "https://example.com/sso")
+	require.Equal(t, http.StatusCreated, code)
+	code, body := do(browser, http.MethodGet, "/api/auth/oidc/login?claim_anonymous=true", "")
+	require.Equal(t, http.StatusOK, code, body)
+	require.NoError(t, json.Unmarshal([]byte(body), &session))
+	assert.Equal(t, 1, session.Claimed)
+	firstID := session.UserID
+	code, body = do(browser, http.MethodGet, "/api/user/urls", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "https://example.com/sso")
+
+	//Тот же субъект получает того же пользователя, другой - нового
+	code, body = do(newClient(), http.MethodGet, "/api/auth/oidc/login", "")
+	require.Equal(t, http.StatusOK, code)
+	require.NoError(t, json.Unmarshal([]byte(body), &session))
+	assert.Equal(t, firstID, session.UserID)
+	idp.SetSubject("user-2")
+	code, body = do(newClient(), http.MethodGet, "/api/auth/oidc/login", "")
+	require.Equal(t, http.StatusOK, code)
+	require.NoError(t, json.Unmarshal([]byte(body), &session))
+	assert.NotEqual(t, firstID, session.UserID)
+
+	//Возврат без начатого входа отклоняется
+	code, _ = do(newClient(), http.MethodGet, "/api/auth/oidc/callback?code=x&state=y", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestAdminAPI(t *testing.T) {
+	cfg := &config.Config{FlagBaseAddress: "http://localhost"}
+	st := primitivestorage.NewStorage(nil, errors.New("dont need file"))
+	tokens, err := auth.New(auth.Options{TTL: time.Hour})
+	require.NoError(t, err)
+	accts, err := accounts.New(st, tokens, bcrypt.MinCost)
+	require.NoError(t, err)
+	accts.SetAdminLogins([]string{"root"})
+	tokens.SetRoleSource(accts)
+	a := &app.App{
+		Config:   cfg,
+		Storage:  st,
+		Handlers: handlers.NewHandlers(cfg, st, &sync.WaitGroup{}, buildinfo.New("test", "N/A", "N/A")),
+		Logger:   &logger.ServerLogger{Logger: zap.NewNop()},
+		Metrics:  metrics.New(),
+		Health:   health.New(),
+		Tokens:   tokens,
+		Accounts: accts,
+		Admin:    admin.New(st),
+	}
+	s := httptest.NewServer(a.CreateRouter(*a.Handlers))
+	defer s.Close()
+
+	do := func(token, method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+	var root, bob struct {
+		UserID string `json:"user_id"`
+		Token  string `json:"token"`
+	}
+	code, body := do("", http.MethodPost, "/api/auth/register", `{"login":"root","password":"correct horse"}`)
+	require.Equal(t, http.StatusCreated, code)
+	require.NoError(t, json.Unmarshal([]byte(body), &root))
+	code, body = do("", http.MethodPost, "/api/auth/register", `{"login":"bob","password":"correct horse"}`)
+	require.Equal(t, http.StatusCreated, code)
+	require.NoError(t, json.Unmarshal([]byte(body), &bob))
+	code, body = do(bob.Token, http.MethodPost, "/", "https://example.com/bob")
+	require.Equal(t, http.StatusCreated, code)
+	shortKey := strings.TrimPrefix(body, "http://localhost/")
+
+	//Обычному пользователю административный API недоступен, администратор видит все ссылки
+	code, _ = do(bob.Token, http.MethodGet, "/api/admin/urls", "")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, body = do(root.Token, http.MethodGet, "/api/admin/urls?user_id="+bob.UserID, "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "https://example.com/bob")
+
+	//Аудитор читает, но не меняет; роль приходит с новым токеном
+	code, _ = do(root.Token, http.MethodPut, "/api/admin/users/"+bob.UserID+"/role", `{"role":"auditor"}`)
+	require.Equal(t, http.StatusNoContent, code)
+	code, body = do("", http.MethodPost, "/api/auth/login", `{"login":"bob","password":"correct horse"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.NoError(t, json.Unmarshal([]byte(body), &bob))
+	code, body = do(bob.Token, http.MethodGet, "/api/admin/users?q=bo", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"role":"auditor"`)
+	code, _ = do(bob.Token, http.MethodPut, "/api/admin/urls/"+shortKey+"/disabled", `{"disabled":true}`)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	//Заблокированная ссылка отвечает 410
+	code, _ = do(root.Token, http.MethodPut, "/api/admin/urls/"+shortKey+"/disabled", `{"disabled":true}`)
+	require.Equal(t, http.StatusNoContent, code)
+	code, _ = do("", http.MethodGet, "/"+shortKey, "")
+	assert.Equal(t, http.StatusGone, code)
+
+	//Администратор не блокирует себя, заблокированный пользователь не входит
+	code, _ = do(root.Token, http.MethodPut, "/api/admin/users/"+root.UserID+"/disabled", `{"disabled":true}`)
+	assert.Equal(t, http.StatusConflict, code)
+	code, _ = do(root.Token, http.MethodPut, "/api/admin/users/"+bob.UserID+"/disabled", `{"disabled":true}`)
+	require.Equal(t, http.StatusNoContent, code)
+	code, _ = do("", http.MethodPost, "/api/auth/login", `{"login":"bob","password":"correct horse"}`)
+	assert.Equal(t, http.StatusForbidden, code)
+	//Выданный до блокировки токен тоже не действует
+	code, _ = do(bob.Token, http.MethodGet, "/api/admin/users", "")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = do(bob.Token, http.MethodPost, "/", "https://example.com/bob-disabled")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, body = do(root.Token, http.MethodDelete, "/api/admin/users/"+bob.UserID, "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"deleted_urls":1`)
+	code, body = do(root.Token, http.MethodGet, "/api/admin/stats", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"deleted":1`)
+}
+
+func TestWorkspaces(t *testing.T) {
+	cfg := &config.Config{FlagBaseAddress: "http://localhost"}
+	st := primitivestorage.NewStorage(nil, errors.New("dont need file"))
+	tokens, err := auth.New(auth.Options{TTL: time.Hour})
+	require.NoError(t, err)
+	accts, err := accounts.New(st, tokens, bcrypt.MinCost)
+	require.NoError(t, err)
+	a := &app.App{
+		Config:     cfg,
+		Storage:    st,
+		Handlers:   handlers.NewHandlers(cfg, st, &sync.WaitGroup{}, buildinfo.New("test", "N/A", "N/A")),
+		Logger:     &logger.ServerLogger{Logger: zap.NewNop()},
+		Metrics:    metrics.New(),
+		Health:     health.New(),
+		Tokens:     tokens,
+		Accounts:   accts,
+		Workspaces: workspaces.New(st),
+	}
+	s := httptest.NewServer(a.CreateRouter(*a.Handlers))
+	defer s.Close()
+
+	do := func(token, workspaceID, method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if workspaceID != "" {
+			req.Header.Set(workspaces.Header, workspaceID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+	type session struct {
+		UserID string `json:"user_id"`
+		Token  string `json:"token"`
+	}
+	register := func(login string) session {
+		code, body := do("", "", http.MethodPost, "/api/auth/register", `{"login":"`+login+`","password":"correct horse"}`)
+		require.Equal(t, http.StatusCreated, code)
+		var res session
+		require.NoError(t, json.Unmarshal([]byte(body), &res))
+		return res
+	}
+	alice, bob, carol := register("alice"), register("bob"), register("carol")
+
+	code, body := do(alice.Token, "", http.MethodPost, "/api/workspaces", `{"name":"team"}`)
+	require.Equal(t, http.StatusCreated, code)
+	var ws struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &ws))
+
+	//Не участник не работает с пространством, редактор добавляется владельцем
+	code, _ = do(bob.Token, ws.ID, http.MethodGet, "/api/user/urls", "")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = do(alice.Token, "", http.MethodPut, "/api/workspaces/"+ws.ID+"/members/"+bob.UserID, `{"role":"editor"}`)
+	require.Equal(t, http.StatusNoContent, code)
+	code, _ = do(alice.Token, "", http.MethodPut, "/api/workspaces/"+ws.ID+"/members/"+carol.UserID, `{"role":"viewer"}`)
+	require.Equal(t, http.StatusNoContent, code)
+	code, _ = do(bob.Token, "", http.MethodPut, "/api/workspaces/"+ws.ID+"/members/"+carol.UserID, `{"role":"owner"}`)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	//Ссылку редактора видят все участники, но не личный список
+	code, _ = do(bob.Token, ws.ID, http.MethodPost, "/", "https://example.com/team")
+	require.Equal(t, http.StatusCreated, code)
+	code, body = do(carol.Token, ws.ID, http.MethodGet, "/api/user/urls", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "https://example.com/team")
+	code, _ = do(bob.Token, "", http.MethodGet, "/api/user/urls", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	//Наблюдатель не создает ссылки, последний владелец не уходит
+	code, _ = do(carol.Token, ws.ID, http.MethodPost, "/", "https://example.com/viewer")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = do(alice.Token, "", http.MethodDelete, "/api/workspaces/"+ws.ID+"/members/"+alice.UserID, "")
+	assert.Equal(t, http.StatusConflict, code)
+	code, body = do(carol.Token, "", http.MethodGet, "/api/workspaces/"+ws.ID+"/members", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"role":"editor"`)
+}
+
+func TestRateLimit(t *testing.T) {
+	cfg := &config.Config{FlagBaseAddress: "http://localhost"}
+	st := primitivestorage.NewStorage(nil, errors.New("dont need file"))
+	tokens, err := auth.New(auth.Options{TTL: time.Hour})
+	require.NoError(t, err)
+	ips, err := clientip.New([]string{"127.0.0.1"})
+	require.NoError(t, err)
+	a := &app.App{
+		Config:    cfg,
+		Storage:   st,
+		Handlers:  handlers.NewHandlers(cfg, st, &sync.WaitGroup{}, buildinfo.New("test", "N/A", "N/A")),
+		Logger:    &logger.ServerLogger{Logger: zap.NewNop()},
+		Metrics:   metrics.New(),
+		Health:    health.New(),
+		Tokens:    tokens,
+		ClientIP:  ips,
+		RateLimit: ratelimit.New(ratelimit.NewMemoryStore(), map[string]int{ratelimit.ActionShorten: 1}),
+	}
+	s := httptest.NewServer(a.CreateRouter(*a.Handlers))
+	defer s.Close()
+
+	shorten := func(forwardedFor string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, s.URL+"/", strings.NewReader("https://example.com/"+forwardedFor))
+		require.NoError(t, err)
+		req.Header.Set(clientip.ForwardedForHeader, forwardedFor)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	//Анонимные клиенты за доверенным прокси различаются по X-Forwarded-For
+	assert.Equal(t, http.StatusCreated, shorten("198.51.100.1").StatusCode)
+	resp := shorten("198.51.100.1")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+	assert.Equal(t, http.StatusCreated, shorten("198.51.100.2").StatusCode)
+}
+
+func TestTrustedSubnet(t *testing.T) {
+	cfg := &config.Config{FlagBaseAddress: "http://localhost", TrustedSubnet: []string{"10.0.0.0/8", "fd00::/8"}}
+	st := primitivestorage.NewStorage(nil, errors.New("dont need file"))
+	tokens, err := auth.New(auth.Options{TTL: time.Hour})
+	require.NoError(t, err)
+	ips, err := clientip.New([]string{"127.0.0.1"})
+	require.NoError(t, err)
+	trusted, err := clientip.NewGate(cfg.TrustedSubnet, ips)
+	require.NoError(t, err)
+	nh := handlers.NewHandlers(cfg, st, &sync.WaitGroup{}, buildinfo.New("test", "N/A", "N/A"))
+	nh.SetTrustedGate(trusted)
+	a := &app.App{
+		Config:   cfg,
+		Storage:  st,
+		Handlers: nh,
+		Logger:   &logger.ServerLogger{Logger: zap.NewNop()},
+		Metrics:  metrics.New(),
+		Health:   health.New(),
+		Tokens:   tokens,
+		ClientIP: ips,
+		Trusted:  trusted,
+	}
+	s := httptest.NewServer(a.CreateRouter(*a.Handlers))
+	defer s.Close()
+
+	stats := func(header, value string) int {
+		req, err := http.NewRequest(http.MethodGet, s.URL+"/api/internal/stats", nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	//Соединение идет от доверенного прокси 127.0.0.1, адрес клиента берется из заголовков
+	assert.Equal(t, http.StatusForbidden, stats("", ""))
+	assert.Equal(t, http.StatusOK, stats(clientip.RealIPHeader, "10.1.2.3"))
+	assert.Equal(t, http.StatusOK, stats(clientip.ForwardedForHeader, "fd00::5"))
+	assert.Equal(t, http.StatusForbidden, stats(clientip.ForwardedForHeader, "10.1.2.3, 198.51.100.1"))
+}
+
+func TestQuota(t *testing.T) {
+	cfg := &config.Config{FlagBaseAddress: "http://localhost"}
+	ms := primitivestorage.NewStorage(nil, errors.New("dont need file"))
+	q := quota.New(ms, quota.Limits{MaxLinks: 2, MaxBatchSize: 1, MaxURLLength: 40})
+	st := quota.NewStorage(ms, q)
+	tokens, err := auth.New(auth.Options{TTL: time.Hour})
+	require.NoError(t, err)
+	token, err := tokens.BuildNewToken(uuid.NewString())
+	require.NoError(t, err)
+	a := &app.App{
+		Config:   cfg,
+		Storage:  st,
+		Handlers: handlers.NewHandlers(cfg, st, &sync.WaitGroup{}, buildinfo.New("test", "N/A", "N/A")),
+		Logger:   &logger.ServerLogger{Logger: zap.NewNop()},
+		Metrics:  metrics.New(),
+		Health:   health.New(),
+		Tokens:   tokens,
+		Quota:    q,
+	}
+	s := httptest.NewServer(a.CreateRouter(*a.Handlers))
+	defer s.Close()
+
+	do := func(method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+	code, _ := do(http.MethodPost, "/", "https://example.com/"+strings.Repeat("x", 40))
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(http.MethodPost, "/api/shorten/batch",
+		`[{"correlation_id":"1","original_url":"https://example.com/1"},{"correlation_id":"2","original_url":"https://example.com/2"}]`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	code, _ = do(http.MethodPost, "/", "https://example.com/1")
+	require.Equal(t, http.StatusCreated, code)
+	code, _ = do(http.MethodPost, "/api/shorten", `{"url":"https://example.com/2"}`)
+	require.Equal(t, http.StatusCreated, code)
+	code, _ = do(http.MethodPost, "/", "https://example.com/3")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, body := do(http.MethodGet, "/api/user/quota", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"links":2,"max_links":2,"max_batch_size":1,"max_url_length":40}`, body)
+}
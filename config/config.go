@@ -8,14 +8,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 )
 
-// Cfg, Config для работы с JSON файлом
-var (
-	Cfg        Config // Cfg - переменная с конфигурацией
-	ConfigPath string
-)
+// ConfigPath путь к JSON файлу конфигурации
+var ConfigPath string
 
 // Config структура с полями конфигурации
 type Config struct {
@@ -24,8 +22,107 @@ type Config struct {
 	FlagFilePath    string `json:"flag_file_path"`    //Флаг для хранения файла
 	DataBaseDSN     string `json:"data_base_dsn"`     //Строка соединения с БД
 	EnableHTTPS     bool   `json:"enable_https"`      //Подключение tls соединения
-	TrustedSubnet   string `json:"trusted_subnet"`    //доверенная посеть
+	TrustedSubnet   List   `json:"trusted_subnet"`    //Доверенные подсети IPv4 и IPv6
 	GRPCAddress     string `json:"grpc_address"`      //Адрес сервера grpc
+	//Строки соединения с репликами БД для чтения
+	DataBaseReplicaDSN []string `json:"data_base_replica_dsn"`
+	//Окно read-your-writes в секундах: чтение после записи идет на основную БД
+	ReadYourWritesSec int `json:"read_your_writes_sec"`
+	//Размер кэша ссылок для редиректа, 0 выключает кэш.
+	//Записи живут несколько секунд: удаление на другом экземпляре видно с этой задержкой
+	URLCacheSize int `json:"url_cache_size"`
+	//Адрес служебного сервера (метрики), по умолчанию пустой - сервер выключен.
+	//Включается флагом -admin или ADMIN_ADDRESS, например localhost:9090.
+	//Без admin_trusted_only сервер запускается только на loopback адресе
+	AdminAddress string `json:"admin_address"`
+	//Доступ к служебному серверу только из доверенной подсети
+	AdminTrustedOnly bool `json:"admin_trusted_only"`
+	//Экспортер трассировки: none, otlp или stdout
+	TracingExporter string `json:"tracing_exporter"`
+	//Адрес OTLP коллектора (gRPC)
+	OTLPEndpoint string `json:"otlp_endpoint"`
+	//Соединение с OTLP коллектором без TLS
+	OTLPInsecure bool `json:"otlp_insecure"`
+	//Уровень логирования: debug, info, warn, error
+	LogLevel string `json:"log_level"`
+	//Формат логов: json или console
+	LogEncoding string `json:"log_encoding"`
+	//Файл логов, пустой путь - вывод в stdout
+	LogFile string `json:"log_file"`
+	//Размер файла логов в мегабайтах, после которого он ротируется
+	LogMaxSizeMB int `json:"log_max_size_mb"`
+	//Число хранимых ротированных файлов логов
+	LogMaxBackups int `json:"log_max_backups"`
+	//Формат журнала запросов: json или combined
+	AccessLogFormat string `json:"access_log_format"`
+	//Запись каждого N-го успешного редиректа в журнал запросов, 1 - все
+	AccessLogSampleEvery int `json:"access_log_sample_every"`
+	//Пауза между снятием готовности и остановкой серверов в секундах
+	ShutdownDelaySec int `json:"shutdown_delay_sec"`
+	//Адрес отладочного сервера (pprof, expvar), пустой адрес выключает сервер
+	DebugAddress string `json:"debug_address"`
+	//Пользователь и пароль Basic авторизации отладочного сервера
+	DebugUser     string `json:"debug_user"`
+	DebugPassword string `json:"debug_password"`
+	//Bearer токен отладочного сервера
+	DebugToken string `json:"debug_token"`
+	//Каталог для профилей, снятых по запросу
+	ProfilesDir string `json:"profiles_dir"`
+	//Файлы сертификата и ключа для HTTPS, имеют приоритет над autocert
+	TLSCertFile string `json:"tls_cert_file"`
+	TLSKeyFile  string `json:"tls_key_file"`
+	//Хосты для сертификатов Let's Encrypt и каталог для их хранения
+	AutocertHosts    []string `json:"autocert_hosts"`
+	AutocertCacheDir string   `json:"autocert_cache_dir"`
+	//Минимальная версия TLS: 1.2 или 1.3
+	TLSMinVersion string `json:"tls_min_version"`
+	//Адрес HTTP сервера с перенаправлением на HTTPS, пустой адрес выключает сервер
+	HTTPRedirectAddress string `json:"http_redirect_address"`
+	//Файл CA клиентских сертификатов gRPC, непустой путь включает mTLS
+	GRPCClientCAFile string `json:"grpc_client_ca_file"`
+	//Секрет HS256 для токенов пользователей, без него и без файла ключей генерируется при старте
+	JWTSecret string `json:"jwt_secret"`
+	//Файл ключей подписи токенов с kid, алгоритмом и активным ключом
+	JWTKeysFile string `json:"jwt_keys_file"`
+	//Время жизни токена пользователя в минутах
+	TokenTTLMin int `json:"token_ttl_min"`
+	//Окно продления истекшего токена в минутах, 0 - без продления
+	TokenRefreshGraceMin int `json:"token_refresh_grace_min"`
+	//Путь куки с токеном
+	CookiePath string `json:"cookie_path"`
+	//Домен куки с токеном, пустой - текущий хост
+	CookieDomain string `json:"cookie_domain"`
+	//Кука только по HTTPS, с enable_https включается всегда
+	CookieSecure bool `json:"cookie_secure"`
+	//Режим SameSite куки: lax, strict или none
+	CookieSameSite string `json:"cookie_same_site"`
+	//Файл зарегистрированных пользователей при хранении ссылок в файле
+	UsersFilePath string `json:"users_file_path"`
+	//Файл ключей API при хранении ссылок в файле
+	APIKeysFilePath string `json:"api_keys_file_path"`
+	//Издатель OpenID Connect для входа через SSO, пустой выключает вход
+	OIDCIssuer string `json:"oidc_issuer"`
+	//Клиент сервиса у провайдера, секрет пустой для публичного клиента
+	OIDCClientID     string `json:"oidc_client_id"`
+	OIDCClientSecret string `json:"oidc_client_secret"`
+	//Адрес возврата от провайдера, путь /api/auth/oidc/callback
+	OIDCRedirectURL string `json:"oidc_redirect_url"`
+	//Логины, получающие роль администратора при входе, для назначения первого администратора
+	AdminLogins []string `json:"admin_logins"`
+	//Подсети прокси, которым доверяются заголовки X-Forwarded-For и X-Real-IP
+	TrustedProxies []string `json:"trusted_proxies"`
+	//Хранилище счетчиков ограничения запросов: memory или postgres для нескольких экземпляров
+	RateLimitStore string `json:"rate_limit_store"`
+	//Запросов в минуту на клиента для сокращения, переходов и удаления, 0 - без ограничения.
+	//По умолчанию выключено, включается флагами -rate-limit-* или RATE_LIMIT_SHORTEN, RATE_LIMIT_REDIRECT, RATE_LIMIT_DELETE
+	RateLimitShorten  int `json:"rate_limit_shorten"`
+	RateLimitRedirect int `json:"rate_limit_redirect"`
+	RateLimitDelete   int `json:"rate_limit_delete"`
+	//Квоты: активных ссылок на пользователя, ссылок в пакете и длина ссылки, 0 - без ограничения.
+	//По умолчанию выключены, включаются флагами -max-* или MAX_USER_LINKS, MAX_BATCH_SIZE, MAX_URL_LENGTH
+	MaxUserLinks int `json:"max_user_links"`
+	MaxBatchSize int `json:"max_batch_size"`
+	MaxURLLength int `json:"max_url_length"`
 }
 
 // NewConfig конструктор для внедрения зависимостей
@@ -38,13 +135,51 @@ func NewConfig() (c *Config) {
 		FlagFilePath:    fmt.Sprint(currentDir, "/tmp/short-url-db.json"),
 		DataBaseDSN:     os.Getenv("DATABASE_DSN"),
 		EnableHTTPS:     false,
-		TrustedSubnet:   "",
-		GRPCAddress:     "3200",
+		GRPCAddress:     ":3200",
+		//Реплики по умолчанию не используются
+		DataBaseReplicaDSN: nil,
+		ReadYourWritesSec:  5,
+		URLCacheSize:       0,
+		AdminAddress:       "",
+		AdminTrustedOnly:   false,
+		TracingExporter:    "none",
+		OTLPEndpoint:       "localhost:4317",
+		OTLPInsecure:       true,
+		LogLevel:           "info",
+		LogEncoding:        "json",
+		LogFile:            "",
+		LogMaxSizeMB:       100,
+		LogMaxBackups:      3,
+		AccessLogFormat:    "json",
+		//Журнал запросов без прореживания
+		AccessLogSampleEvery: 1,
+		ShutdownDelaySec:     0,
+		DebugAddress:         "",
+		ProfilesDir:          "profiles",
+		AutocertCacheDir:     "cache-dir",
+		TLSMinVersion:        "1.2",
+		HTTPRedirectAddress:  "",
+		TokenTTLMin:          180,
+		//Неделя на возврат пользователя с истекшим токеном
+		TokenRefreshGraceMin: 7 * 24 * 60,
+		CookiePath:           "/",
+		CookieSameSite:       "lax",
+		UsersFilePath:        fmt.Sprint(currentDir, "/tmp/users.json"),
+		APIKeysFilePath:      fmt.Sprint(currentDir, "/tmp/api_keys.json"),
+		RateLimitStore:       "memory",
+		//Лимиты запросов и квоты по умолчанию выключены
+		RateLimitShorten:  0,
+		RateLimitRedirect: 0,
+		RateLimitDelete:   0,
+		MaxUserLinks:      0,
+		MaxBatchSize:      0,
+		MaxURLLength:      0,
 	}
-	// Получаем путь к конфигурационному файлу из переменных окружения, если указан
+	// Получаем путь к конфигурационному файлу из переменных окружения, если указан.
+	// Значения из файла заменяют значения по умолчанию, флаги и переменные окружения - значения из файла
 	if configPath := os.Getenv("CONFIG_PATH"); configPath != "" {
 		ConfigPath = configPath
-		err := loadConfigFromFile()
+		err := loadConfigFromFile(c)
 		if err != nil {
 			return
 		}
@@ -66,8 +201,70 @@ func NewConfig() (c *Config) {
 	flag.StringVar(&c.FlagFilePath, "f", c.FlagFilePath, "set file path")
 	flag.StringVar(&c.DataBaseDSN, "d", c.DataBaseDSN, "Database connection string")
 	flag.BoolVar(&c.EnableHTTPS, "s", c.EnableHTTPS, "Enable secure connection")
-	flag.StringVar(&c.TrustedSubnet, "t", c.TrustedSubnet, "Trusted CIDR subnet")
-	flag.StringVar(&c.GRPCAddress, "g", ":3200", "Адрес запуска gRPC-сервера")
+	flag.Func("t", "Trusted CIDR subnets, comma separated", func(flagValue string) error {
+		c.TrustedSubnet = splitList(flagValue)
+		return nil
+	})
+	flag.StringVar(&c.GRPCAddress, "g", c.GRPCAddress, "Адрес запуска gRPC-сервера")
+	flag.Func("dr", "Replica connection strings, comma separated", func(flagValue string) error {
+		c.DataBaseReplicaDSN = splitList(flagValue)
+		return nil
+	})
+	flag.IntVar(&c.ReadYourWritesSec, "rw", c.ReadYourWritesSec, "Read-your-writes window in seconds")
+	flag.IntVar(&c.URLCacheSize, "cs", c.URLCacheSize, "URL cache size, 0 disables cache")
+	flag.StringVar(&c.AdminAddress, "admin", c.AdminAddress, "Admin server address (metrics)")
+	flag.BoolVar(&c.AdminTrustedOnly, "admin-trusted", c.AdminTrustedOnly, "Allow admin server only from trusted subnet")
+	flag.StringVar(&c.TracingExporter, "trace", c.TracingExporter, "Tracing exporter: none, otlp or stdout")
+	flag.StringVar(&c.OTLPEndpoint, "otlp", c.OTLPEndpoint, "OTLP collector endpoint")
+	flag.BoolVar(&c.OTLPInsecure, "otlp-insecure", c.OTLPInsecure, "Connect to OTLP collector without TLS")
+	flag.StringVar(&c.LogLevel, "l", c.LogLevel, "Log level: debug, info, warn, error")
+	flag.StringVar(&c.LogEncoding, "log-encoding", c.LogEncoding, "Log encoding: json or console")
+	flag.StringVar(&c.LogFile, "log-file", c.LogFile, "Log file, empty for stdout")
+	flag.IntVar(&c.LogMaxSizeMB, "log-max-size", c.LogMaxSizeMB, "Log file size in MB before rotation")
+	flag.IntVar(&c.LogMaxBackups, "log-max-backups", c.LogMaxBackups, "Number of rotated log files to keep")
+	flag.StringVar(&c.AccessLogFormat, "access-log", c.AccessLogFormat, "Access log format: json or combined")
+	flag.IntVar(&c.AccessLogSampleEvery, "access-log-sample", c.AccessLogSampleEvery, "Log every N-th successful redirect")
+	flag.IntVar(&c.ShutdownDelaySec, "shutdown-delay", c.ShutdownDelaySec, "Seconds between readiness off and shutdown")
+	flag.StringVar(&c.DebugAddress, "debug", c.DebugAddress, "Debug server address (pprof, expvar)")
+	flag.StringVar(&c.DebugUser, "debug-user", c.DebugUser, "Debug server basic auth user")
+	flag.StringVar(&c.DebugPassword, "debug-password", c.DebugPassword, "Debug server basic auth password")
+	flag.StringVar(&c.DebugToken, "debug-token", c.DebugToken, "Debug server bearer token")
+	flag.StringVar(&c.ProfilesDir, "profiles-dir", c.ProfilesDir, "Directory for captured profiles")
+	flag.StringVar(&c.TLSCertFile, "tls-cert", c.TLSCertFile, "TLS certificate file")
+	flag.StringVar(&c.TLSKeyFile, "tls-key", c.TLSKeyFile, "TLS key file")
+	flag.Func("autocert-hosts", "Autocert hosts, comma separated", func(flagValue string) error {
+		c.AutocertHosts = splitList(flagValue)
+		return nil
+	})
+	flag.StringVar(&c.AutocertCacheDir, "autocert-cache", c.AutocertCacheDir, "Autocert cache directory")
+	flag.StringVar(&c.TLSMinVersion, "tls-min-version", c.TLSMinVersion, "Minimum TLS version: 1.2 or 1.3")
+	flag.StringVar(&c.HTTPRedirectAddress, "http-redirect", c.HTTPRedirectAddress, "HTTP to HTTPS redirect server address")
+	flag.StringVar(&c.GRPCClientCAFile, "grpc-client-ca", c.GRPCClientCAFile, "CA file for gRPC client certificates (mTLS)")
+	flag.StringVar(&c.JWTKeysFile, "jwt-keys", c.JWTKeysFile, "JWT signing keys file")
+	flag.IntVar(&c.TokenTTLMin, "token-ttl", c.TokenTTLMin, "User token lifetime in minutes")
+	flag.IntVar(&c.TokenRefreshGraceMin, "token-refresh-grace", c.TokenRefreshGraceMin, "Expired token refresh window in minutes")
+	flag.BoolVar(&c.CookieSecure, "cookie-secure", c.CookieSecure, "Send token cookie over HTTPS only")
+	flag.StringVar(&c.CookieSameSite, "cookie-samesite", c.CookieSameSite, "Token cookie SameSite mode: lax, strict or none")
+	flag.StringVar(&c.UsersFilePath, "users-file", c.UsersFilePath, "Registered users file for file storage")
+	flag.StringVar(&c.APIKeysFilePath, "api-keys-file", c.APIKeysFilePath, "API keys file for file storage")
+	flag.StringVar(&c.OIDCIssuer, "oidc-issuer", c.OIDCIssuer, "OpenID Connect issuer URL for SSO login")
+	flag.StringVar(&c.OIDCClientID, "oidc-client-id", c.OIDCClientID, "OpenID Connect client ID")
+	flag.StringVar(&c.OIDCRedirectURL, "oidc-redirect-url", c.OIDCRedirectURL, "OpenID Connect redirect URL")
+	flag.Func("admin-logins", "Logins granted the admin role on login, comma separated", func(flagValue string) error {
+		c.AdminLogins = splitList(flagValue)
+		return nil
+	})
+	flag.Func("trusted-proxies", "Trusted proxy CIDRs for X-Forwarded-For, comma separated", func(flagValue string) error {
+		c.TrustedProxies = splitList(flagValue)
+		return nil
+	})
+	flag.StringVar(&c.RateLimitStore, "rate-limit-store", c.RateLimitStore, "Rate limit buckets storage: memory or postgres")
+	flag.IntVar(&c.RateLimitShorten, "rate-limit-shorten", c.RateLimitShorten, "Shorten requests per minute per client, 0 disables")
+	flag.IntVar(&c.RateLimitRedirect, "rate-limit-redirect", c.RateLimitRedirect, "Redirects per minute per client, 0 disables")
+	flag.IntVar(&c.RateLimitDelete, "rate-limit-delete", c.RateLimitDelete, "Delete requests per minute per client, 0 disables")
+	flag.IntVar(&c.MaxUserLinks, "max-user-links", c.MaxUserLinks, "Maximum active links per user, 0 disables")
+	flag.IntVar(&c.MaxBatchSize, "max-batch-size", c.MaxBatchSize, "Maximum links in a batch request, 0 disables")
+	flag.IntVar(&c.MaxURLLength, "max-url-length", c.MaxURLLength, "Maximum original URL length in bytes, 0 disables")
 	flag.Parse()
 	// Считаем переменные окружения более приоритетными перед флагами
 	if envRunAddr := os.Getenv("SERVER_ADDRESS"); envRunAddr != "" {
@@ -88,17 +285,230 @@ func NewConfig() (c *Config) {
 	}
 
 	if trustedSubnet, ok := os.LookupEnv("TRUSTED_SUBNET"); ok {
-		c.TrustedSubnet = trustedSubnet
+		c.TrustedSubnet = splitList(trustedSubnet)
 	}
 	if GRPCAddress, ok := os.LookupEnv("GRPC_ADDRESS"); ok {
 		c.GRPCAddress = GRPCAddress
 	}
+	if replicaDSN, ok := os.LookupEnv("DATABASE_REPLICA_DSN"); ok {
+		c.DataBaseReplicaDSN = splitList(replicaDSN)
+	}
+	if readYourWrites, ok := os.LookupEnv("READ_YOUR_WRITES_SEC"); ok {
+		if sec, err := strconv.Atoi(readYourWrites); err == nil {
+			c.ReadYourWritesSec = sec
+		}
+	}
+
+	if cacheSize, ok := os.LookupEnv("URL_CACHE_SIZE"); ok {
+		if size, err := strconv.Atoi(cacheSize); err == nil {
+			c.URLCacheSize = size
+		}
+	}
+	if adminAddress, ok := os.LookupEnv("ADMIN_ADDRESS"); ok {
+		c.AdminAddress = adminAddress
+	}
+	if adminTrusted, ok := os.LookupEnv("ADMIN_TRUSTED_ONLY"); ok {
+		if trusted, err := strconv.ParseBool(adminTrusted); err == nil {
+			c.AdminTrustedOnly = trusted
+		}
+	}
+	if tracingExporter, ok := os.LookupEnv("TRACING_EXPORTER"); ok {
+		c.TracingExporter = tracingExporter
+	}
+	if otlpEndpoint, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT"); ok {
+		c.OTLPEndpoint = otlpEndpoint
+	}
+	if otlpInsecure, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_INSECURE"); ok {
+		c.OTLPInsecure = otlpInsecure == "true"
+	}
+	if logLevel, ok := os.LookupEnv("LOG_LEVEL"); ok {
+		c.LogLevel = logLevel
+	}
+	if logEncoding, ok := os.LookupEnv("LOG_ENCODING"); ok {
+		c.LogEncoding = logEncoding
+	}
+	if logFile, ok := os.LookupEnv("LOG_FILE"); ok {
+		c.LogFile = logFile
+	}
+	if logMaxSize, ok := os.LookupEnv("LOG_MAX_SIZE_MB"); ok {
+		if size, err := strconv.Atoi(logMaxSize); err == nil {
+			c.LogMaxSizeMB = size
+		}
+	}
+	if logMaxBackups, ok := os.LookupEnv("LOG_MAX_BACKUPS"); ok {
+		if n, err := strconv.Atoi(logMaxBackups); err == nil {
+			c.LogMaxBackups = n
+		}
+	}
+	if accessLogFormat, ok := os.LookupEnv("ACCESS_LOG_FORMAT"); ok {
+		c.AccessLogFormat = accessLogFormat
+	}
+	if accessLogSample, ok := os.LookupEnv("ACCESS_LOG_SAMPLE_EVERY"); ok {
+		if n, err := strconv.Atoi(accessLogSample); err == nil {
+			c.AccessLogSampleEvery = n
+		}
+	}
+	if shutdownDelay, ok := os.LookupEnv("SHUTDOWN_DELAY_SEC"); ok {
+		if sec, err := strconv.Atoi(shutdownDelay); err == nil {
+			c.ShutdownDelaySec = sec
+		}
+	}
+	if debugAddress, ok := os.LookupEnv("DEBUG_ADDRESS"); ok {
+		c.DebugAddress = debugAddress
+	}
+	if debugUser, ok := os.LookupEnv("DEBUG_USER"); ok {
+		c.DebugUser = debugUser
+	}
+	if debugPassword, ok := os.LookupEnv("DEBUG_PASSWORD"); ok {
+		c.DebugPassword = debugPassword
+	}
+	if debugToken, ok := os.LookupEnv("DEBUG_TOKEN"); ok {
+		c.DebugToken = debugToken
+	}
+	if profilesDir, ok := os.LookupEnv("PROFILES_DIR"); ok {
+		c.ProfilesDir = profilesDir
+	}
+	if certFile, ok := os.LookupEnv("TLS_CERT_FILE"); ok {
+		c.TLSCertFile = certFile
+	}
+	if keyFile, ok := os.LookupEnv("TLS_KEY_FILE"); ok {
+		c.TLSKeyFile = keyFile
+	}
+	if autocertHosts, ok := os.LookupEnv("AUTOCERT_HOSTS"); ok {
+		c.AutocertHosts = splitList(autocertHosts)
+	}
+	if autocertCache, ok := os.LookupEnv("AUTOCERT_CACHE_DIR"); ok {
+		c.AutocertCacheDir = autocertCache
+	}
+	if minVersion, ok := os.LookupEnv("TLS_MIN_VERSION"); ok {
+		c.TLSMinVersion = minVersion
+	}
+	if redirectAddress, ok := os.LookupEnv("HTTP_REDIRECT_ADDRESS"); ok {
+		c.HTTPRedirectAddress = redirectAddress
+	}
+	if clientCAFile, ok := os.LookupEnv("GRPC_CLIENT_CA_FILE"); ok {
+		c.GRPCClientCAFile = clientCAFile
+	}
+	if jwtSecret, ok := os.LookupEnv("JWT_SECRET"); ok {
+		c.JWTSecret = jwtSecret
+	}
+	if jwtKeysFile, ok := os.LookupEnv("JWT_KEYS_FILE"); ok {
+		c.JWTKeysFile = jwtKeysFile
+	}
+	if tokenTTL, ok := os.LookupEnv("TOKEN_TTL_MIN"); ok {
+		if n, err := strconv.Atoi(tokenTTL); err == nil {
+			c.TokenTTLMin = n
+		}
+	}
+	if refreshGrace, ok := os.LookupEnv("TOKEN_REFRESH_GRACE_MIN"); ok {
+		if n, err := strconv.Atoi(refreshGrace); err == nil {
+			c.TokenRefreshGraceMin = n
+		}
+	}
+	if cookiePath, ok := os.LookupEnv("COOKIE_PATH"); ok {
+		c.CookiePath = cookiePath
+	}
+	if cookieDomain, ok := os.LookupEnv("COOKIE_DOMAIN"); ok {
+		c.CookieDomain = cookieDomain
+	}
+	if cookieSecure, ok := os.LookupEnv("COOKIE_SECURE"); ok {
+		c.CookieSecure = cookieSecure == "true"
+	}
+	if sameSite, ok := os.LookupEnv("COOKIE_SAME_SITE"); ok {
+		c.CookieSameSite = sameSite
+	}
+	if usersFile, ok := os.LookupEnv("USERS_FILE_PATH"); ok {
+		c.UsersFilePath = usersFile
+	}
+	if apiKeysFile, ok := os.LookupEnv("API_KEYS_FILE_PATH"); ok {
+		c.APIKeysFilePath = apiKeysFile
+	}
+	if issuer, ok := os.LookupEnv("OIDC_ISSUER"); ok {
+		c.OIDCIssuer = issuer
+	}
+	if clientID, ok := os.LookupEnv("OIDC_CLIENT_ID"); ok {
+		c.OIDCClientID = clientID
+	}
+	if clientSecret, ok := os.LookupEnv("OIDC_CLIENT_SECRET"); ok {
+		c.OIDCClientSecret = clientSecret
+	}
+	if redirectURL, ok := os.LookupEnv("OIDC_REDIRECT_URL"); ok {
+		c.OIDCRedirectURL = redirectURL
+	}
+	if adminLogins, ok := os.LookupEnv("ADMIN_LOGINS"); ok {
+		c.AdminLogins = splitList(adminLogins)
+	}
+	if proxies, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		c.TrustedProxies = splitList(proxies)
+	}
+	if store, ok := os.LookupEnv("RATE_LIMIT_STORE"); ok {
+		c.RateLimitStore = store
+	}
+	if limit, ok := os.LookupEnv("RATE_LIMIT_SHORTEN"); ok {
+		if n, err := strconv.Atoi(limit); err == nil {
+			c.RateLimitShorten = n
+		}
+	}
+	if limit, ok := os.LookupEnv("RATE_LIMIT_REDIRECT"); ok {
+		if n, err := strconv.Atoi(limit); err == nil {
+			c.RateLimitRedirect = n
+		}
+	}
+	if limit, ok := os.LookupEnv("RATE_LIMIT_DELETE"); ok {
+		if n, err := strconv.Atoi(limit); err == nil {
+			c.RateLimitDelete = n
+		}
+	}
+	if limit, ok := os.LookupEnv("MAX_USER_LINKS"); ok {
+		if n, err := strconv.Atoi(limit); err == nil {
+			c.MaxUserLinks = n
+		}
+	}
+	if limit, ok := os.LookupEnv("MAX_BATCH_SIZE"); ok {
+		if n, err := strconv.Atoi(limit); err == nil {
+			c.MaxBatchSize = n
+		}
+	}
+	if limit, ok := os.LookupEnv("MAX_URL_LENGTH"); ok {
+		if n, err := strconv.Atoi(limit); err == nil {
+			c.MaxURLLength = n
+		}
+	}
 
 	return c
 }
 
-// loadConfigFromFile чтение JSON файла конфигурации
-func loadConfigFromFile() error {
+// List список значений, в JSON - массив или строка через запятую
+type List []string
+
+// UnmarshalJSON разбор массива или строки через запятую, как в прежнем формате trusted_subnet
+func (l *List) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*l = splitList(value)
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*l = values
+	return nil
+}
+
+// splitList разбивает список значений через запятую, пустые элементы пропускаются
+func splitList(value string) []string {
+	var result []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// loadConfigFromFile чтение JSON файла конфигурации в c, отсутствующие в файле поля не меняются
+func loadConfigFromFile(c *Config) error {
 	if ConfigPath == "" {
 		log.Println("the path to the configuration file is empty")
 		return nil
@@ -109,7 +519,7 @@ func loadConfigFromFile() error {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
-	if err = json.Unmarshal(data, &Cfg); err != nil {
+	if err = json.Unmarshal(data, c); err != nil {
 		return fmt.Errorf("failed to parse configuration: %w", err)
 	}
 	return nil
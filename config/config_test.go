@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig файл конфигурации во временном каталоге
+func writeConfig(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	return path
+}
+
+func TestLoadTrustedSubnet(t *testing.T) {
+	defer func(path string) { ConfigPath = path }(ConfigPath)
+
+	tests := []struct {
+		name string
+		json string
+		want List
+	}{
+		{name: "legacy string", json: `{"trusted_subnet": "10.0.0.0/8"}`, want: List{"10.0.0.0/8"}},
+		{name: "comma separated", json: `{"trusted_subnet": "10.0.0.0/8, fd00::/8"}`, want: List{"10.0.0.0/8", "fd00::/8"}},
+		{name: "array", json: `{"trusted_subnet": ["10.0.0.0/8", "fd00::/8"]}`, want: List{"10.0.0.0/8", "fd00::/8"}},
+		{name: "empty string", json: `{"trusted_subnet": ""}`, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ConfigPath = writeConfig(t, tt.json)
+			c := Config{}
+			require.NoError(t, loadConfigFromFile(&c))
+			assert.Equal(t, tt.want, c.TrustedSubnet)
+		})
+	}
+
+	ConfigPath = writeConfig(t, `{"trusted_subnet": 8}`)
+	assert.Error(t, loadConfigFromFile(&Config{}))
+}
+
+func TestNewConfigFromFile(t *testing.T) {
+	defer func(path string) { ConfigPath = path }(ConfigPath)
+	t.Setenv("CONFIG_PATH", writeConfig(t, `{"trusted_subnet": "10.0.0.0/8", "grpc_address": ":3300"}`))
+	for _, env := range []string{"TRUSTED_SUBNET", "GRPC_ADDRESS", "ADMIN_ADDRESS", "URL_CACHE_SIZE",
+		"RATE_LIMIT_SHORTEN", "RATE_LIMIT_REDIRECT", "RATE_LIMIT_DELETE", "MAX_USER_LINKS", "MAX_BATCH_SIZE", "MAX_URL_LENGTH"} {
+		if value, ok := os.LookupEnv(env); ok {
+			t.Setenv(env, value)
+			require.NoError(t, os.Unsetenv(env))
+		}
+	}
+
+	//Значения из файла попадают в конфигурацию, остальные остаются по умолчанию
+	c := NewConfig()
+	assert.Equal(t, List{"10.0.0.0/8"}, c.TrustedSubnet)
+	assert.Equal(t, ":3300", c.GRPCAddress)
+	assert.Equal(t, ":8080", c.FlagAddress)
+
+	//Служебный сервер, лимиты запросов и квоты по умолчанию выключены
+	assert.Empty(t, c.AdminAddress)
+	assert.Zero(t, c.URLCacheSize)
+	assert.Zero(t, c.RateLimitShorten+c.RateLimitRedirect+c.RateLimitDelete)
+	assert.Zero(t, c.MaxUserLinks+c.MaxBatchSize+c.MaxURLLength)
+}
@@ -9,9 +9,9 @@ func ExampleNewConfig() {
 		FlagFilePath:    "/tmp/short-url-db.json",
 		DataBaseDSN:     "user:password@/dbname",
 		EnableHTTPS:     false,
-		TrustedSubnet:   "",
+		TrustedSubnet:   []string{},
 	}
 	fmt.Printf("%+v\n", config)
 	// Output:
-	// &{FlagAddress::8080 FlagBaseAddress:http://localhost:8080 FlagFilePath:/tmp/short-url-db.json DataBaseDSN:user:password@/dbname EnableHTTPS:false TrustedSubnet:}
+	// &{FlagAddress::8080 FlagBaseAddress:http://localhost:8080 FlagFilePath:/tmp/short-url-db.json DataBaseDSN:user:password@/dbname EnableHTTPS:false TrustedSubnet:[] GRPCAddress: DataBaseReplicaDSN:[] ReadYourWritesSec:0 URLCacheSize:0 AdminAddress: AdminTrustedOnly:false TracingExporter: OTLPEndpoint: OTLPInsecure:false LogLevel: LogEncoding: LogFile: LogMaxSizeMB:0 LogMaxBackups:0 AccessLogFormat: AccessLogSampleEvery:0 ShutdownDelaySec:0 DebugAddress: DebugUser: DebugPassword: DebugToken: ProfilesDir: TLSCertFile: TLSKeyFile: AutocertHosts:[] AutocertCacheDir: TLSMinVersion: HTTPRedirectAddress: GRPCClientCAFile: JWTSecret: JWTKeysFile: TokenTTLMin:0 TokenRefreshGraceMin:0 CookiePath: CookieDomain: CookieSecure:false CookieSameSite: UsersFilePath: APIKeysFilePath: OIDCIssuer: OIDCClientID: OIDCClientSecret: OIDCRedirectURL: AdminLogins:[] TrustedProxies:[] RateLimitStore: RateLimitShorten:0 RateLimitRedirect:0 RateLimitDelete:0 MaxUserLinks:0 MaxBatchSize:0 MaxURLLength:0}
 }
@@ -0,0 +1,193 @@
+// Package accounts регистрация и вход пользователей по логину и паролю.
+package accounts
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/internalerrors"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+	"github.com/SversusN/shortener/internal/storage/storage"
+)
+
+// Ограничения логина и пароля
+const (
+	minLoginLen    = 3
+	maxLoginLen    = 64
+	minPasswordLen = 8
+	maxPasswordLen = 72 // bcrypt учитывает только первые 72 байта
+)
+
+// Ошибки регистрации и входа
+var (
+	ErrInvalidLogin       = errors.New("login must be 3-64 letters, digits or ._-@")
+	ErrWeakPassword       = errors.New("password must be 8-72 bytes")
+	ErrLoginTaken         = internalerrors.ErrLoginTaken
+	ErrInvalidCredentials = errors.New("invalid login or password")
+	ErrUserDisabled       = auth.ErrUserDisabled
+)
+
+// Session пользователь и выданный ему токен
+type Session struct {
+	UserID string
+	Token  string
+}
+
+// Service регистрация и вход пользователей
+type Service struct {
+	users  storage.UserStore
+	tokens *auth.Manager
+	cost   int
+	admins map[string]struct{} // логины, получающие роль администратора при входе
+	// dummyHash сравнивается при неизвестном логине, чтобы время ответа не выдавало наличие пользователя
+	dummyHash []byte
+}
+
+// New создает сервис учетных записей
+//
+// cost - стоимость bcrypt, 0 - bcrypt.DefaultCost.
+func New(users storage.UserStore, tokens *auth.Manager, cost int) (*Service, error) {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), cost)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{users: users, tokens: tokens, cost: cost, dummyHash: dummyHash}, nil
+}
+
+// Register регистрация пользователя с новым ИД и выдача токена
+func (s *Service) Register(ctx context.Context, login, password string) (Session, error) {
+	login, err := normalizeLogin(login)
+	if err != nil {
+		return Session{}, err
+	}
+	if len(password) < minPasswordLen || len(password) > maxPasswordLen {
+		return Session{}, ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.cost)
+	if err != nil {
+		return Session{}, err
+	}
+	u := entity.User{ID: uuid.NewString(), Login: login, PasswordHash: string(hash)}
+	if err := s.users.CreateUser(ctx, u); err != nil {
+		return Session{}, err
+	}
+	return s.session(ctx, u.ID, u.Login)
+}
+
+// Login проверка пароля и выдача токена пользователю
+func (s *Service) Login(ctx context.Context, login, password string) (Session, error) {
+	login, err := normalizeLogin(login)
+	if err != nil {
+		return Session{}, ErrInvalidCredentials
+	}
+	u, err := s.users.GetUserByLogin(ctx, login)
+	if errors.Is(err, internalerrors.ErrUserNotFound) {
+		_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return Session{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return Session{}, ErrInvalidCredentials
+	}
+	return s.session(ctx, u.ID, u.Login)
+}
+
+// LoginIdentity вход пользователя внешнего провайдера, при первом входе создается новый ИД
+func (s *Service) LoginIdentity(ctx context.Context, issuer, subject string) (Session, error) {
+	userID, err := s.users.GetOrCreateIdentity(ctx, entity.Identity{Issuer: issuer, Subject: subject, UserID: uuid.NewString()})
+	if err != nil {
+		return Session{}, err
+	}
+	return s.session(ctx, userID, "")
+}
+
+// Claim перенос ссылок анонимного пользователя из токена anonymousToken пользователю userID
+//
+// Переносить нечего, если токена нет, он невалиден, принадлежит самому пользователю
+// или другому зарегистрированному пользователю, поэтому повтор безопасен.
+func (s *Service) Claim(ctx context.Context, anonymousToken, userID string) (int, error) {
+	if anonymousToken == "" {
+		return 0, nil
+	}
+	//Маршруты входа не продлевают куку, истекший в окне продления токен еще принадлежит клиенту
+	claims, err := s.tokens.ParseWithGrace(anonymousToken)
+	if err != nil || claims.UserID == userID {
+		return 0, nil
+	}
+	claimed, err := s.users.ClaimURLs(ctx, claims.UserID, userID)
+	if errors.Is(err, internalerrors.ErrNotAnonymous) {
+		return 0, nil
+	}
+	return claimed, err
+}
+
+// SetAdminLogins логины администраторов: при входе или регистрации им сохраняется роль auth.RoleAdmin
+//
+// Так назначается первый администратор, остальные роли выдаются через административный API.
+func (s *Service) SetAdminLogins(logins []string) {
+	s.admins = make(map[string]struct{}, len(logins))
+	for _, login := range logins {
+		if login, err := normalizeLogin(login); err == nil {
+			s.admins[login] = struct{}{}
+		}
+	}
+}
+
+// UserRole текущая роль пользователя, для заблокированного - auth.ErrUserDisabled
+func (s *Service) UserRole(ctx context.Context, userID string) (string, error) {
+	access, err := s.users.GetUserAccess(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if access.Disabled {
+		return "", auth.ErrUserDisabled
+	}
+	if access.Role == "" {
+		return auth.RoleUser, nil
+	}
+	return access.Role, nil
+}
+
+// session выдача токена пользователю с его текущей ролью
+func (s *Service) session(ctx context.Context, userID, login string) (Session, error) {
+	role, err := s.UserRole(ctx, userID)
+	if err != nil {
+		return Session{}, err
+	}
+	if _, ok := s.admins[login]; ok && login != "" && role != auth.RoleAdmin {
+		role = auth.RoleAdmin
+		if err := s.users.SetUserAccess(ctx, entity.UserAccess{UserID: userID, Role: role}); err != nil {
+			return Session{}, err
+		}
+	}
+	token, err := s.tokens.BuildAccountToken(userID, role)
+	if err != nil {
+		return Session{}, err
+	}
+	return Session{UserID: userID, Token: token}, nil
+}
+
+// normalizeLogin проверка логина и приведение к нижнему регистру
+func normalizeLogin(login string) (string, error) {
+	login = strings.ToLower(strings.TrimSpace(login))
+	if len(login) < minLoginLen || len(login) > maxLoginLen {
+		return "", ErrInvalidLogin
+	}
+	for _, c := range login {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && !strings.ContainsRune("._-@", c) {
+			return "", ErrInvalidLogin
+		}
+	}
+	return login, nil
+}
@@ -0,0 +1,97 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/SversusN/shortener/internal/auth"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+	"github.com/SversusN/shortener/internal/storage/primitivestorage"
+)
+
+// newService сервис над хранилищем в памяти с файлом пользователей
+func newService(t *testing.T, usersFile string) *Service {
+	st := primitivestorage.NewStorage(nil, errors.New("no file"))
+	require.NoError(t, st.OpenUsers(usersFile))
+	tokens, err := auth.New(auth.Options{TTL: time.Hour})
+	require.NoError(t, err)
+	s, err := New(st, tokens, bcrypt.MinCost)
+	require.NoError(t, err)
+	return s
+}
+
+func TestRegisterLogin(t *testing.T) {
+	ctx := context.Background()
+	usersFile := filepath.Join(t.TempDir(), "users.json")
+	s := newService(t, usersFile)
+
+	reg, err := s.Register(ctx, " Alice ", "correct horse")
+	require.NoError(t, err)
+	assert.NotEmpty(t, reg.Token)
+	userID, err := s.tokens.GetUserID(reg.Token)
+	require.NoError(t, err)
+	assert.Equal(t, reg.UserID, userID)
+
+	//Логин без учета регистра, ИД тот же
+	login, err := s.Login(ctx, "alice", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, reg.UserID, login.UserID)
+
+	_, err = s.Register(ctx, "ALICE", "another password")
+	assert.ErrorIs(t, err, ErrLoginTaken)
+	_, err = s.Login(ctx, "alice", "wrong password")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = s.Login(ctx, "bob", "correct horse")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	//После перезапуска пользователь читается из файла
+	restarted := newService(t, usersFile)
+	login, err = restarted.Login(ctx, "alice", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, reg.UserID, login.UserID)
+}
+
+func TestRegisterValidation(t *testing.T) {
+	ctx := context.Background()
+	s := newService(t, filepath.Join(t.TempDir(), "users.json"))
+	_, err := s.Register(ctx, "al", "correct horse")
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+	_, err = s.Register(ctx, "al ice", "correct horse")
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+	_, err = s.Register(ctx, "alice", "short")
+	assert.ErrorIs(t, err, ErrWeakPassword)
+}
+
+func TestClaimExpiredAnonymous(t *testing.T) {
+	ctx := context.Background()
+	const secret = "0123456789abcdef0123456789abcdef"
+	st := primitivestorage.NewStorage(nil, errors.New("no file"))
+	require.NoError(t, st.OpenUsers(filepath.Join(t.TempDir(), "users.json")))
+	tokens, err := auth.New(auth.Options{Secret: secret, TTL: time.Hour, Grace: time.Hour})
+	require.NoError(t, err)
+	s, err := New(st, tokens, bcrypt.MinCost)
+	require.NoError(t, err)
+
+	//Анонимный токен истек, но еще в окне продления
+	issuer, err := auth.New(auth.Options{Secret: secret, TTL: time.Nanosecond})
+	require.NoError(t, err)
+	anonID := uuid.NewString()
+	expired, err := issuer.BuildNewToken(anonID)
+	require.NoError(t, err)
+	_, err = st.SetURL(ctx, "anon1", "https://example.com/anon", entity.Owner{UserID: anonID})
+	require.NoError(t, err)
+
+	reg, err := s.Register(ctx, "alice", "correct horse")
+	require.NoError(t, err)
+	claimed, err := s.Claim(ctx, expired, reg.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+}
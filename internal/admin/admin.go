@@ -0,0 +1,134 @@
+// Package admin административные операции над ссылками и пользователями.
+//
+// Права проверяются до вызова сервиса: в HTTP middleware и в gRPC интерцепторе,
+// сервис лишь не дает администратору заблокировать, удалить или понизить самого себя.
+package admin
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/internalerrors"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+	"github.com/SversusN/shortener/internal/storage/storage"
+)
+
+// Размер страницы списков
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Ошибки административных операций
+var (
+	ErrInvalidRole = errors.New("role must be one of user, auditor, admin")
+	ErrSelf        = errors.New("administrators cannot disable, delete or demote themselves")
+	ErrNotFound    = internalerrors.ErrNotFound
+)
+
+// Service административные операции
+type Service struct {
+	store storage.AdminStore
+}
+
+// New создает административный сервис
+func New(store storage.AdminStore) *Service {
+	return &Service{store: store}
+}
+
+// ListURLs ссылки всех пользователей по фильтру
+func (s *Service) ListURLs(ctx context.Context, filter entity.URLFilter) ([]entity.URLInfo, error) {
+	filter.Limit, filter.Offset = clamp(filter.Limit, filter.Offset)
+	if filter.UserID != "" {
+		if _, err := uuid.Parse(filter.UserID); err != nil {
+			return []entity.URLInfo{}, nil
+		}
+	}
+	return s.store.ListURLs(ctx, filter)
+}
+
+// ListUsers зарегистрированные пользователи по фильтру
+func (s *Service) ListUsers(ctx context.Context, filter entity.UserFilter) ([]entity.UserInfo, error) {
+	filter.Limit, filter.Offset = clamp(filter.Limit, filter.Offset)
+	users, err := s.store.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if users[i].Role == "" {
+			users[i].Role = auth.RoleUser
+		}
+	}
+	return users, nil
+}
+
+// SetURLDisabled блокировка или разблокировка ссылки, заблокированная ссылка отвечает 410
+func (s *Service) SetURLDisabled(ctx context.Context, shortURL string, disabled bool) error {
+	return s.store.SetURLDisabled(ctx, shortURL, disabled)
+}
+
+// DeleteURL удаление ссылки любого пользователя
+func (s *Service) DeleteURL(ctx context.Context, shortURL string) error {
+	return s.store.DeleteURL(ctx, shortURL)
+}
+
+// SetUserDisabled блокировка или разблокировка пользователя, в том числе анонимного
+//
+// Заблокированный пользователь не может войти и продлить токен, его ключи API не принимаются.
+func (s *Service) SetUserDisabled(ctx context.Context, userID string, disabled bool) error {
+	access, err := s.access(ctx, userID)
+	if err != nil {
+		return err
+	}
+	access.Disabled = disabled
+	return s.store.SetUserAccess(ctx, access)
+}
+
+// SetUserRole смена роли пользователя, действует с продления его токена
+func (s *Service) SetUserRole(ctx context.Context, userID, role string) error {
+	if !auth.ValidRole(role) {
+		return ErrInvalidRole
+	}
+	access, err := s.access(ctx, userID)
+	if err != nil {
+		return err
+	}
+	access.Role = role
+	return s.store.SetUserAccess(ctx, access)
+}
+
+// DeleteUser удаление пользователя с его ссылками и ключами API, возвращает число удаленных ссылок
+func (s *Service) DeleteUser(ctx context.Context, userID string) (int, error) {
+	if _, err := s.access(ctx, userID); err != nil {
+		return 0, err
+	}
+	return s.store.DeleteUser(ctx, userID)
+}
+
+// access текущие роль и блокировка другого пользователя
+func (s *Service) access(ctx context.Context, userID string) (entity.UserAccess, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return entity.UserAccess{}, ErrNotFound
+	}
+	if self, err := auth.UserIDFromCtx(ctx); err == nil && self == userID {
+		return entity.UserAccess{}, ErrSelf
+	}
+	return s.store.GetUserAccess(ctx, userID)
+}
+
+// clamp размер страницы в пределах MaxLimit, по умолчанию DefaultLimit
+func clamp(limit, offset int) (int, int) {
+	switch {
+	case limit <= 0:
+		limit = DefaultLimit
+	case limit > MaxLimit:
+		limit = MaxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
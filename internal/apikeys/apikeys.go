@@ -0,0 +1,155 @@
+// Package apikeys ключи API для межсервисных клиентов.
+//
+// Ключ имеет вид sk_<id>_<secret>: по id ключ находится в хранилище,
+// секрет сверяется с сохраненным sha256 и в открытом виде не хранится.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/internalerrors"
+	"github.com/SversusN/shortener/internal/logger"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+	"github.com/SversusN/shortener/internal/storage/storage"
+)
+
+// Передача ключа в запросе, кроме Authorization: Bearer
+const (
+	Header  = "X-API-Key" // заголовок HTTP
+	MetaKey = "x-api-key" // ключ метаданных gRPC
+)
+
+// Prefix начало каждого ключа API, отличает его от JWT
+const Prefix = "sk_"
+
+// touchInterval как часто обновляется время использования ключа
+const touchInterval = time.Minute
+
+// maxNameLen ограничение длины имени ключа
+const maxNameLen = 100
+
+// Ошибки ключей API
+var (
+	ErrInvalidKey   = errors.New("invalid api key")
+	ErrInvalidScope = errors.New("scopes must be a non-empty subset of shorten, read, delete")
+	ErrInvalidName  = errors.New("key name must be 1-100 characters")
+	ErrNotFound     = internalerrors.ErrNotFound
+)
+
+// Service выпуск, отзыв и проверка ключей API
+type Service struct {
+	store  storage.APIKeyStore
+	access storage.AccessStore
+}
+
+// New создает сервис ключей API
+//
+// access - блокировки пользователей: ключи заблокированного владельца не принимаются.
+func New(store storage.APIKeyStore, access storage.AccessStore) *Service {
+	return &Service{store: store, access: access}
+}
+
+// IsAPIKey значение похоже на ключ API, а не на JWT
+func IsAPIKey(raw string) bool {
+	return strings.HasPrefix(raw, Prefix)
+}
+
+// Create выпуск ключа пользователю, секрет возвращается только здесь
+func (s *Service) Create(ctx context.Context, userID, name string, scopes []string) (entity.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLen {
+		return entity.APIKey{}, "", ErrInvalidName
+	}
+	if len(scopes) == 0 {
+		return entity.APIKey{}, "", ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(auth.Scopes, scope) {
+			return entity.APIKey{}, "", ErrInvalidScope
+		}
+	}
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return entity.APIKey{}, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return entity.APIKey{}, "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+	k := entity.APIKey{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		Name:      name,
+		Hash:      hashSecret(encoded),
+		Scopes:    slices.Compact(scopes),
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.CreateAPIKey(ctx, k); err != nil {
+		return entity.APIKey{}, "", err
+	}
+	return k, Prefix + k.ID + "_" + encoded, nil
+}
+
+// List ключи пользователя
+func (s *Service) List(ctx context.Context, userID string) ([]entity.APIKey, error) {
+	return s.store.ListAPIKeys(ctx, userID)
+}
+
+// Revoke отзыв ключа пользователя
+func (s *Service) Revoke(ctx context.Context, userID, id string) error {
+	return s.store.DeleteAPIKey(ctx, userID, id)
+}
+
+// Authenticate проверка ключа и учет его использования
+func (s *Service) Authenticate(ctx context.Context, raw string) (entity.APIKey, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(raw, Prefix), "_")
+	if !IsAPIKey(raw) || !ok {
+		return entity.APIKey{}, ErrInvalidKey
+	}
+	k, err := s.store.GetAPIKey(ctx, id)
+	if errors.Is(err, internalerrors.ErrNotFound) {
+		return entity.APIKey{}, ErrInvalidKey
+	}
+	if err != nil {
+		return entity.APIKey{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(k.Hash)) != 1 {
+		return entity.APIKey{}, ErrInvalidKey
+	}
+	owner, err := s.access.GetUserAccess(ctx, k.UserID)
+	if err != nil {
+		return entity.APIKey{}, err
+	}
+	if owner.Disabled {
+		return entity.APIKey{}, ErrInvalidKey
+	}
+	//Время использования пишется не чаще раза в минуту, чтобы не нагружать хранилище
+	if now := time.Now(); now.Sub(k.LastUsedAt) >= touchInterval {
+		if err := s.store.TouchAPIKey(ctx, k.ID, now); err != nil {
+			logger.FromCtx(ctx).Warn("failed to update api key usage", zap.Error(err))
+		}
+		k.LastUsedAt = now
+	}
+	return k, nil
+}
+
+// hashSecret sha256 секрета в hex: у случайного секрета достаточно энтропии без медленного хэша
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
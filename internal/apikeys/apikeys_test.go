@@ -0,0 +1,55 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/storage/primitivestorage"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	st := primitivestorage.NewStorage(nil, errors.New("dont need file"))
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, st.OpenAPIKeys(path))
+	s := New(st, st)
+
+	_, _, err := s.Create(ctx, "user", "ci", []string{"admin"})
+	assert.ErrorIs(t, err, ErrInvalidScope)
+	_, _, err = s.Create(ctx, "user", " ", []string{auth.ScopeRead})
+	assert.ErrorIs(t, err, ErrInvalidName)
+
+	k, secret, err := s.Create(ctx, "user", "ci", []string{auth.ScopeRead, auth.ScopeShorten, auth.ScopeRead})
+	require.NoError(t, err)
+	assert.Equal(t, []string{auth.ScopeRead, auth.ScopeShorten}, k.Scopes)
+	assert.NotContains(t, k.Hash, secret)
+
+	got, err := s.Authenticate(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, "user", got.UserID)
+	assert.False(t, got.LastUsedAt.IsZero())
+	for _, bad := range []string{secret + "x", Prefix + k.ID, Prefix + "unknown_secret", "token"} {
+		_, err = s.Authenticate(ctx, bad)
+		assert.ErrorIs(t, err, ErrInvalidKey, bad)
+	}
+
+	//Ключи переживают перезапуск
+	restarted := primitivestorage.NewStorage(nil, errors.New("dont need file"))
+	require.NoError(t, restarted.OpenAPIKeys(path))
+	_, err = New(restarted, restarted).Authenticate(ctx, secret)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.Revoke(ctx, "other", k.ID), ErrNotFound)
+	require.NoError(t, s.Revoke(ctx, "user", k.ID))
+	_, err = s.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	keys, err := s.List(ctx, "user")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
@@ -4,11 +4,12 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"log"
 	"net"
 	"net/http"
-	_ "net/http/pprof"
 	"os"
 	"os/signal"
 	"sync"
@@ -20,15 +21,29 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/SversusN/shortener/config"
+	"github.com/SversusN/shortener/internal/accounts"
+	"github.com/SversusN/shortener/internal/admin"
+	"github.com/SversusN/shortener/internal/apikeys"
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/buildinfo"
+	"github.com/SversusN/shortener/internal/clientip"
+	"github.com/SversusN/shortener/internal/debugsrv"
 	"github.com/SversusN/shortener/internal/grpcsrv"
 	"github.com/SversusN/shortener/internal/handlers"
+	"github.com/SversusN/shortener/internal/health"
 	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/metrics"
 	mw "github.com/SversusN/shortener/internal/middleware"
+	"github.com/SversusN/shortener/internal/oidc"
 	"github.com/SversusN/shortener/internal/pkg/utils"
+	"github.com/SversusN/shortener/internal/quota"
+	"github.com/SversusN/shortener/internal/ratelimit"
 	"github.com/SversusN/shortener/internal/storage/dbstorage"
 	"github.com/SversusN/shortener/internal/storage/primitivestorage"
 	"github.com/SversusN/shortener/internal/storage/storage"
-	"golang.org/x/crypto/acme/autocert"
+	"github.com/SversusN/shortener/internal/tlsconf"
+	"github.com/SversusN/shortener/internal/tracing"
+	"github.com/SversusN/shortener/internal/workspaces"
 )
 
 // GrpcNotRunning позволяет получить статус запуска из горутины сервера GRPC
@@ -40,75 +55,435 @@ type App struct {
 	Storage    storage.Storage      // Интерфейс хранилища
 	Handlers   *handlers.Handlers   //Объект http обработчиков
 	Logger     *logger.ServerLogger //Внедорение логера
+	Metrics    *metrics.Metrics     //Метрики Prometheus
+	Health     *health.Checker      //Проверки готовности
+	BuildInfo  buildinfo.Info       //Сведения о сборке
+	TLS        *tlsconf.Provider    //Конфигурация TLS, nil без HTTPS
+	Tokens     *auth.Manager        //Выпуск и проверка токенов пользователей
+	Accounts   *accounts.Service    //Регистрация и вход по паролю
+	APIKeys    *apikeys.Service     //Ключи API межсервисных клиентов
+	OIDC       *oidc.Provider       //Вход через OpenID Connect, nil если не настроен
+	Admin      *admin.Service       //Административные операции над ссылками и пользователями
+	Workspaces *workspaces.Service  //Общие пространства с ссылками команды
+	ClientIP   *clientip.Resolver   //Адрес клиента с учетом доверенных прокси
+	Trusted    *clientip.Gate       //Доступ из доверенных подсетей, nil - доступ закрыт
+	RateLimit  *ratelimit.Limiter   //Ограничение частоты запросов, nil - без ограничения
+	Quota      *quota.Service       //Квоты ссылок пользователей, nil - без квот
 	FileHelper *utils.FileHelper    //Работа с файлом
 	Context    context.Context      //Контекст приложения
 	wg         *sync.WaitGroup      //waitgroup для всех зависимых компонентов
 	gs         *grpc.Server         //сервер grpc
+	//остановка трассировки с отправкой накопленных спанов
+	shutdownTracing func(context.Context) error
 }
 
+// Пороги проверок готовности
+const (
+	maxDeleteQueue = 10000     // ключей в очереди на удаление
+	minFreeDisk    = 100 << 20 // свободного места под файлы хранилища и логов, байт
+)
+
 // App Конструктор пакета, создает целевой объект приложения с нужными зависимостями
-func New() *App {
+//
+// info - сведения о сборке, хранилище и возможности дописываются по конфигурации.
+func New(info buildinfo.Info) *App {
 	var ns storage.Storage
+	var us storage.UserStore      //хранилище пользователей без оберток метрик и трассировки
+	var ks storage.APIKeyStore    //хранилище ключей API без оберток
+	var as storage.AdminStore     //административные операции без оберток
+	var ws storage.WorkspaceStore //пространства и участники без оберток
+	var rs storage.RateLimitStore //корзины ограничения запросов в БД
+	var qs storage.QuotaStore     //подсчет ссылок для квот без оберток
 	wg := &sync.WaitGroup{}
 	cfg := config.NewConfig()
 	ctx := context.Background()
+	lg, err := logger.NewLogger(logger.Options{
+		Level:      cfg.LogLevel,
+		Encoding:   cfg.LogEncoding,
+		File:       cfg.LogFile,
+		MaxSizeMB:  cfg.LogMaxSizeMB,
+		MaxBackups: cfg.LogMaxBackups,
+	})
+	if err != nil {
+		//Логера еще нет, сообщаем через стандартный log
+		log.Fatalln("Failed to create logger", err)
+	}
+	//Логер по умолчанию для кода без логера запроса
+	zap.ReplaceGlobals(lg.Logger)
+	m := metrics.New()
+	hc := health.New()
+	shutdownTracing, err := tracing.Setup(ctx, cfg.TracingExporter, cfg.OTLPEndpoint, cfg.OTLPInsecure)
+	if err != nil {
+		lg.Logger.Fatal("Failed to setup tracing", zap.Error(err))
+	}
 	fh, err := utils.NewFileHelper(cfg.FlagFilePath)
+	backend := "postgres"
 	if cfg.DataBaseDSN == "" {
-		ns = primitivestorage.NewStorage(fh, err)
+		backend = "file"
+		if err != nil {
+			backend = "memory"
+		}
+		ms := primitivestorage.NewStorage(fh, err)
+		if err == nil && cfg.UsersFilePath != "" {
+			if err := ms.OpenUsers(cfg.UsersFilePath); err != nil {
+				lg.Logger.Warn("Users are kept in memory only", zap.Error(err))
+			}
+		}
+		if err == nil && cfg.APIKeysFilePath != "" {
+			if err := ms.OpenAPIKeys(cfg.APIKeysFilePath); err != nil {
+				lg.Logger.Warn("API keys are kept in memory only", zap.Error(err))
+			}
+		}
+		ns, us, ks, as, ws, qs = ms, ms, ms, ms, ms, ms
 	} else {
-		ns, err = dbstorage.NewDB(ctx, cfg.DataBaseDSN)
+		pg, err := dbstorage.NewDB(ctx, cfg.DataBaseDSN, cfg.DataBaseReplicaDSN,
+			time.Duration(cfg.ReadYourWritesSec)*time.Second, cfg.URLCacheSize, lg.Logger)
+		if err != nil {
+			lg.Logger.Fatal("Failed to connect to database", zap.Error(err))
+		}
+		m.RegisterPool(pg)
+		m.RegisterCache(pg)
+		if cfg.URLCacheSize > 0 {
+			hc.Add("cache", pg.CheckCache)
+		}
+		ns, us, ks, as, ws, rs, qs = pg, pg, pg, pg, pg, pg, pg
+	}
+	quotas := quota.New(qs, quota.Limits{MaxLinks: cfg.MaxUserLinks, MaxBatchSize: cfg.MaxBatchSize, MaxURLLength: cfg.MaxURLLength})
+	ns = tracing.NewStorage(metrics.NewStorage(quota.NewStorage(ns, quotas), m))
+	if p, ok := ns.(storage.Pinger); ok {
+		hc.Add("storage", p.Ping)
+	}
+	hc.Add("delete_worker", health.QueueDepth(m.DeleteQueueDepth, maxDeleteQueue))
+	hc.Add("disk", health.DiskSpace(minFreeDisk, cfg.FlagFilePath, cfg.LogFile))
+
+	info = info.WithRuntime(backend, features(cfg)...)
+	var tp *tlsconf.Provider
+	if cfg.EnableHTTPS {
+		tp, err = tlsconf.New(tlsconf.Options{
+			CertFile:         cfg.TLSCertFile,
+			KeyFile:          cfg.TLSKeyFile,
+			AutocertHosts:    cfg.AutocertHosts,
+			AutocertCacheDir: cfg.AutocertCacheDir,
+			MinVersion:       cfg.TLSMinVersion,
+		})
+		if err != nil {
+			lg.Logger.Fatal("Failed to configure TLS", zap.Error(err))
+		}
+	}
+
+	tokens, err := auth.New(auth.Options{
+		KeysFile: cfg.JWTKeysFile,
+		Secret:   cfg.JWTSecret,
+		TTL:      time.Duration(cfg.TokenTTLMin) * time.Minute,
+		Grace:    time.Duration(cfg.TokenRefreshGraceMin) * time.Minute,
+		//Блокировка и смена роли действуют на выданные токены не позже чем через это время
+		RoleCacheTTL: auth.DefaultRoleCacheTTL,
+	})
+	if err != nil {
+		lg.Logger.Fatal("Failed to load JWT keys", zap.Error(err))
+	}
+	if _, err := cookieOptions(cfg); err != nil {
+		lg.Logger.Fatal("Invalid cookie settings", zap.Error(err))
+	}
+	if tokens.Ephemeral() {
+		lg.Logger.Warn("JWT secret is not configured, tokens will be invalid after restart")
+	}
+
+	accts, err := accounts.New(us, tokens, 0)
+	if err != nil {
+		lg.Logger.Fatal("Failed to create accounts service", zap.Error(err))
+	}
+	accts.SetAdminLogins(cfg.AdminLogins)
+	//Роль и блокировка пользователя перечитываются при продлении токена
+	tokens.SetRoleSource(accts)
+	adm := admin.New(as)
+	spaces := workspaces.New(ws)
+	ips, err := clientip.New(cfg.TrustedProxies)
+	if err != nil {
+		lg.Logger.Fatal("Failed to parse trusted proxies", zap.Error(err))
+	}
+	trusted, err := clientip.NewGate(cfg.TrustedSubnet, ips)
+	if err != nil {
+		lg.Logger.Fatal("Failed to parse trusted subnets", zap.Error(err))
+	}
+	limiter := newRateLimiter(cfg, rs, lg)
+
+	keys := apikeys.New(ks, us)
+	var op *oidc.Provider
+	if cfg.OIDCIssuer != "" {
+		op, err = oidc.New(oidc.Config{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+		}, nil)
 		if err != nil {
-			log.Fatalln("Failed to connect to database", err)
+			lg.Logger.Fatal("Failed to configure OpenID Connect", zap.Error(err))
+		}
+	}
+
+	nh := handlers.NewHandlers(cfg, ns, wg, info)
+	nh.SetTrustedGate(trusted)
+	//gRPC использует тот же сертификат, что и HTTPS
+	var grpcOpts []grpc.ServerOption
+	if tp != nil {
+		grpcTLS, err := tp.GRPCConfig(cfg.GRPCClientCAFile)
+		if err != nil {
+			lg.Logger.Fatal("Failed to configure gRPC TLS", zap.Error(err))
+		}
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(grpcTLS)))
+	}
+	gs := grpcsrv.NewGRPCServer(&ctx, ns, cfg, wg, grpcsrv.Options{
+		Metrics:    m,
+		Logger:     lg,
+		Health:     hc,
+		BuildInfo:  info,
+		Tokens:     tokens,
+		Accounts:   accts,
+		APIKeys:    keys,
+		Admin:      adm,
+		Workspaces: spaces,
+		RateLimit:  limiter,
+		ClientIP:   ips,
+		Trusted:    trusted,
+	}, grpcOpts...)
+
+	return &App{cfg, ns, nh, lg, m, hc, info, tp, tokens, accts, keys, op, adm, spaces, ips, trusted, limiter, quotas, fh, ctx, wg, gs, shutdownTracing}
+}
+
+// features включенные в конфигурации возможности для сведений о сборке
+func features(cfg *config.Config) []string {
+	var f []string
+	if cfg.DataBaseDSN != "" && len(cfg.DataBaseReplicaDSN) > 0 {
+		f = append(f, "read_replicas")
+	}
+	if cfg.DataBaseDSN != "" && cfg.URLCacheSize > 0 {
+		f = append(f, "url_cache")
+	}
+	if cfg.EnableHTTPS {
+		f = append(f, "https")
+		if cfg.GRPCClientCAFile != "" {
+			f = append(f, "grpc_mtls")
 		}
 	}
-	nh := handlers.NewHandlers(cfg, ns, wg)
-	gs := grpcsrv.NewGRPCServer(&ctx, ns, cfg, wg)
+	if cfg.GRPCAddress != "" {
+		f = append(f, "grpc")
+	}
+	if cfg.AdminAddress != "" {
+		f = append(f, "admin_server")
+	}
+	if cfg.TracingExporter != "" && cfg.TracingExporter != "none" {
+		f = append(f, "tracing_"+cfg.TracingExporter)
+	}
+	if len(cfg.TrustedSubnet) > 0 {
+		f = append(f, "trusted_subnet")
+	}
+	if cfg.RateLimitShorten > 0 || cfg.RateLimitRedirect > 0 || cfg.RateLimitDelete > 0 {
+		f = append(f, "rate_limit_"+cfg.RateLimitStore)
+	}
+	return f
+}
 
-	lg := logger.CreateLogger(zap.NewAtomicLevelAt(zap.InfoLevel))
+// newRateLimiter ограничитель запросов из конфигурации, nil если все лимиты выключены
+//
+// db - хранилище корзин в БД, nil без DATABASE_DSN.
+func newRateLimiter(cfg *config.Config, db storage.RateLimitStore, lg *logger.ServerLogger) *ratelimit.Limiter {
+	if cfg.RateLimitShorten <= 0 && cfg.RateLimitRedirect <= 0 && cfg.RateLimitDelete <= 0 {
+		return nil
+	}
+	limits := map[string]int{
+		ratelimit.ActionShorten:  cfg.RateLimitShorten,
+		ratelimit.ActionRedirect: cfg.RateLimitRedirect,
+		ratelimit.ActionDelete:   cfg.RateLimitDelete,
+	}
+	switch cfg.RateLimitStore {
+	case "", "memory":
+		return ratelimit.New(ratelimit.NewMemoryStore(), limits)
+	case "postgres":
+		if db == nil {
+			lg.Logger.Fatal("Rate limit store postgres requires DATABASE_DSN")
+		}
+		return ratelimit.New(db, limits)
+	}
+	lg.Logger.Fatal("Unknown rate limit store", zap.String("store", cfg.RateLimitStore))
+	return nil
+}
 
-	return &App{cfg, ns, nh, lg, fh, ctx, wg, gs}
+// cookieOptions атрибуты куки с токеном из конфигурации
+func cookieOptions(cfg *config.Config) (mw.CookieOptions, error) {
+	sameSite, err := mw.ParseSameSite(cfg.CookieSameSite)
+	if err != nil {
+		return mw.CookieOptions{}, err
+	}
+	secure := cfg.CookieSecure || cfg.EnableHTTPS
+	//Браузеры отбрасывают куку SameSite=None без Secure
+	if sameSite == http.SameSiteNoneMode && !secure {
+		return mw.CookieOptions{}, errors.New("cookie SameSite=None requires secure cookie")
+	}
+	//Без пути браузер привяжет куку к каталогу запроса, например /api/auth
+	path := cfg.CookiePath
+	if path == "" {
+		path = "/"
+	}
+	return mw.CookieOptions{Path: path, Domain: cfg.CookieDomain, Secure: secure, SameSite: sameSite}, nil
 }
 
 // CreateRouter Создание роутера Chi
 func (a App) CreateRouter(hnd handlers.Handlers) chi.Router {
 	r := chi.NewRouter()
-	r.Use(a.Logger.LoggingMW())
+	r.Use(tracing.HTTPMW)
+	r.Use(a.Logger.RequestIDMW)
+	r.Use(a.Logger.LoggingMW(logger.AccessLog{
+		Format:      a.Config.AccessLogFormat,
+		SampleEvery: a.Config.AccessLogSampleEvery,
+	}))
+	r.Use(a.Metrics.HTTPMW)
 	r.Use(mw.GzipMiddleware)
-	r.Use(mw.NewAuthMW().AuthMWfunc)
+	//Настройки куки проверены при создании приложения
+	cookie, _ := cookieOptions(a.Config)
+	authMW := mw.NewAuthMW(a.Tokens, a.APIKeys, cookie)
+	ah := handlers.NewAccountHandlers(a.Accounts, authMW)
+	kh := handlers.NewAPIKeyHandlers(a.APIKeys)
 	//Инициализация маршрута для роутера Chi
 	r.Route("/", func(r chi.Router) {
-		r.Post("/", hnd.HandlerPost)
+		//Проверки балансировщика и ключи токенов без выдачи анонимного токена
 		r.Get("/ping", hnd.HandlerDBPing)
-		r.Get("/{shortKey}", hnd.HandlerGet)
-		r.Route("/api", func(r chi.Router) {
-			r.Post("/shorten", hnd.HandlerJSONPost)
-			r.Post("/shorten/batch", hnd.HandlerJSONPostBatch)
-			r.Group(func(r chi.Router) { //secure
-				r.Get("/user/urls", hnd.HandlerGetUserURLs)
-				r.Delete("/user/urls", hnd.HandlerDeleteUserURLs)
-			})
-			r.Group(func(r chi.Router) {
-				r.Get("/internal/stats", hnd.HandlerGetStats)
-			})
+		r.Get("/healthz", a.Health.LivenessHandler)
+		r.Get("/readyz", a.Health.PublicReadinessHandler)
+		r.Get("/.well-known/jwks.json", a.Tokens.JWKSHandler)
+		//Вход выдает свой токен, анонимный здесь не нужен
+		r.Route("/api/auth", func(r chi.Router) {
+			r.Post("/register", ah.HandlerRegister)
+			r.Post("/login", ah.HandlerLogin)
+			r.Post("/logout", ah.HandlerLogout)
+			if a.OIDC != nil {
+				oh := handlers.NewOIDCHandlers(a.OIDC, ah)
+				r.Get("/oidc/login", oh.HandlerLogin)
+				r.Get("/oidc/callback", oh.HandlerCallback)
+			}
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(authMW.AuthMWfunc)
+			//Заголовок X-Workspace-ID переключает ссылки запроса на пространство
+			if a.Workspaces != nil {
+				r.Use(mw.WorkspaceMW(a.Workspaces))
+			}
+			shortenLimit := mw.RateLimit(a.RateLimit, a.ClientIP, ratelimit.ActionShorten)
+			r.With(mw.RequireScope(auth.ScopeShorten), mw.RequireWorkspaceWrite, shortenLimit).Post("/", hnd.HandlerPost)
+			r.With(mw.RateLimit(a.RateLimit, a.ClientIP, ratelimit.ActionRedirect)).Get("/{shortKey}", hnd.HandlerGet)
+			r.Route("/api", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(mw.RequireScope(auth.ScopeShorten), mw.RequireWorkspaceWrite, shortenLimit)
+					r.Post("/shorten", hnd.HandlerJSONPost)
+					r.Post("/shorten/batch", hnd.HandlerJSONPostBatch)
+				})
+				r.Group(func(r chi.Router) { //secure
+					r.With(mw.RequireScope(auth.ScopeRead)).Get("/user/urls", hnd.HandlerGetUserURLs)
+					if a.Quota != nil {
+						r.With(mw.RequireScope(auth.ScopeRead)).Get("/user/quota", handlers.NewQuotaHandlers(a.Quota).HandlerUsage)
+					}
+					r.With(mw.RequireScope(auth.ScopeDelete), mw.RequireWorkspaceWrite,
+						mw.RateLimit(a.RateLimit, a.ClientIP, ratelimit.ActionDelete)).Delete("/user/urls", hnd.HandlerDeleteUserURLs)
+				})
+				//Ключами управляет только сам пользователь, не другой ключ
+				r.Route("/user/keys", func(r chi.Router) {
+					r.Use(mw.RequireSession)
+					r.Post("/", kh.HandlerCreateKey)
+					r.Get("/", kh.HandlerListKeys)
+					r.Delete("/{keyID}", kh.HandlerRevokeKey)
+				})
+				//Участниками пространств управляет только сам пользователь
+				if a.Workspaces != nil {
+					r.Route("/workspaces", func(r chi.Router) {
+						wh := handlers.NewWorkspaceHandlers(a.Workspaces)
+						r.Use(mw.RequireSession)
+						r.Post("/", wh.HandlerCreate)
+						r.Get("/", wh.HandlerList)
+						r.Get("/{workspaceID}/members", wh.HandlerMembers)
+						r.Put("/{workspaceID}/members/{userID}", wh.HandlerSetMember)
+						r.Delete("/{workspaceID}/members/{userID}", wh.HandlerRemoveMember)
+					})
+				}
+				//Административный API по ролям из токена, ключам API недоступен
+				if a.Admin != nil {
+					r.Route("/admin", func(r chi.Router) {
+						adh := handlers.NewAdminHandlers(a.Admin, &hnd)
+						r.Use(mw.RequirePermission(auth.PermAdminRead))
+						r.Get("/stats", adh.HandlerStats)
+						r.Get("/urls", adh.HandlerListURLs)
+						r.Get("/users", adh.HandlerListUsers)
+						r.Group(func(r chi.Router) {
+							r.Use(mw.RequirePermission(auth.PermAdminWrite))
+							r.Put("/urls/{shortKey}/disabled", adh.HandlerSetURLDisabled)
+							r.Delete("/urls/{shortKey}", adh.HandlerDeleteURL)
+							r.Put("/users/{userID}/disabled", adh.HandlerSetUserDisabled)
+							r.Put("/users/{userID}/role", adh.HandlerSetUserRole)
+							r.Delete("/users/{userID}", adh.HandlerDeleteUser)
+						})
+					})
+				}
+				r.Group(func(r chi.Router) {
+					r.Get("/internal/stats", hnd.HandlerGetStats)
+					r.Get("/internal/stats/timeseries", hnd.HandlerGetStatsTimeSeries)
+					r.Get("/internal/version", hnd.HandlerGetVersion)
+				})
 
+			})
 		})
 	})
 	return r
 }
 
+// CreateAdminRouter Создание роутера служебного сервера
+func (a App) CreateAdminRouter() chi.Router {
+	r := chi.NewRouter()
+	if a.Config.AdminTrustedOnly {
+		r.Use(mw.TrustedSubnetMW(a.Trusted))
+	}
+	r.Handle("/metrics", a.Metrics.Handler())
+	r.Get("/healthz", a.Health.LivenessHandler)
+	r.Get("/readyz", a.Health.ReadinessHandler)
+	r.Method(http.MethodGet, "/admin/loglevel", a.Logger.LevelHandler())
+	r.Method(http.MethodPut, "/admin/loglevel", a.Logger.LevelHandler())
+	return r
+}
+
+// isLoopbackAddress адрес host:port слушает только loopback интерфейс
+func isLoopbackAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// CreateDebugRouter Создание роутера отладочного сервера
+//
+// Доступ только с авторизацией и, если заданы доверенные подсети, только из них.
+func (a App) CreateDebugRouter() chi.Router {
+	r := chi.NewRouter()
+	if a.Trusted.Enabled() {
+		r.Use(mw.TrustedSubnetMW(a.Trusted))
+	}
+	r.Use(mw.AdminAuthMW(a.Config.DebugUser, a.Config.DebugPassword, a.Config.DebugToken))
+	debugsrv.New(a.Config.ProfilesDir, a.Logger.Logger).Routes(r)
+	return r
+}
+
 // Run Создание роутера веб сервера и запуск веб сервера
 func (a App) Run() {
 	r := a.CreateRouter(*a.Handlers)
+	lg := a.Logger.Logger
 
 	//Переменные для завершения
 	idleConnsClosed := make(chan struct{})
 	sigint := make(chan os.Signal, 1)
 	signal.Notify(sigint, os.Interrupt, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
-	go func() {
-		log.Println(http.ListenAndServe("localhost:90", nil))
-	}()
-
 	//Запуск grpc сервера
 	go func() {
 		//Проверяем, не подкинули ли нам свинью с конфигом
@@ -116,16 +491,16 @@ func (a App) Run() {
 			listen, err := net.Listen("tcp", a.Config.GRPCAddress)
 			if err != nil {
 				GrpcNotRunning.Store(true)
-				log.Printf("listen tcp has failed: %v", err)
+				lg.Error("listen tcp has failed", zap.Error(err))
 				return
 			}
 			err = a.gs.Serve(listen)
 			if err != nil {
-				log.Printf("listen tcp has failed: %v", err)
+				lg.Error("grpc serve has failed", zap.Error(err))
 				return
 			}
 		} else {
-			log.Printf("grpc was not running. Bad address = %s\n", a.Config.GRPCAddress)
+			lg.Warn("grpc was not running. Bad address", zap.String("address", a.Config.GRPCAddress))
 			return
 		}
 	}()
@@ -135,57 +510,119 @@ func (a App) Run() {
 		Handler: r,
 	}
 
+	//Перенаправление с HTTP на HTTPS, для autocert он же проходит проверку HTTP-01
+	var redirectServer *http.Server
+	if a.TLS != nil {
+		server.TLSConfig = a.TLS.Config
+		if a.Config.HTTPRedirectAddress != "" {
+			redirectServer = &http.Server{
+				Addr:              a.Config.HTTPRedirectAddress,
+				Handler:           a.TLS.RedirectHandler(a.Config.FlagAddress),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			go func() {
+				if err := redirectServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+					lg.Error("redirect server ListenAndServe", zap.Error(err))
+				}
+			}()
+		}
+	}
+
+	//Служебный сервер на отдельном адресе
+	var adminServer *http.Server
+	if a.Config.AdminAddress != "" {
+		//Без авторизации служебный сервер доступен только локально или из доверенной подсети
+		if !a.Config.AdminTrustedOnly && !isLoopbackAddress(a.Config.AdminAddress) {
+			lg.Error("admin server was not running: bind it to loopback or enable admin trusted only")
+		} else {
+			adminServer = &http.Server{
+				Addr:    a.Config.AdminAddress,
+				Handler: a.CreateAdminRouter(),
+			}
+			go func() {
+				if err := adminServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+					lg.Error("admin server ListenAndServe", zap.Error(err))
+				}
+			}()
+		}
+	}
+
+	//Отладочный сервер только с настроенной авторизацией
+	var debugServer *http.Server
+	if a.Config.DebugAddress != "" {
+		if a.Config.DebugToken == "" && (a.Config.DebugUser == "" || a.Config.DebugPassword == "") {
+			lg.Error("debug server was not running: set debug token or user and password")
+		} else {
+			debugServer = &http.Server{
+				Addr:    a.Config.DebugAddress,
+				Handler: a.CreateDebugRouter(),
+			}
+			go func() {
+				if err := debugServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+					lg.Error("debug server ListenAndServe", zap.Error(err))
+				}
+			}()
+		}
+	}
+
 	//Ждем сигнала завершения gracefull
 	go func() {
 		<-sigint
-		log.Println("try shutting down api servers...")
+		//Сначала снимаем готовность, чтобы балансировщик убрал экземпляр
+		a.Health.SetShuttingDown()
+		if delay := time.Duration(a.Config.ShutdownDelaySec) * time.Second; delay > 0 {
+			lg.Info("readiness is off, waiting before shutdown", zap.Duration("delay", delay))
+			time.Sleep(delay)
+		}
+		lg.Info("try shutting down api servers...")
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
 		if err := server.Shutdown(ctx); err != nil {
-			log.Println("HTTP server Shutdown:", err)
+			lg.Error("HTTP server Shutdown", zap.Error(err))
+		}
+		if redirectServer != nil {
+			if err := redirectServer.Shutdown(ctx); err != nil {
+				lg.Error("redirect server Shutdown", zap.Error(err))
+			}
+		}
+		if adminServer != nil {
+			if err := adminServer.Shutdown(ctx); err != nil {
+				lg.Error("admin server Shutdown", zap.Error(err))
+			}
+		}
+		if debugServer != nil {
+			if err := debugServer.Shutdown(ctx); err != nil {
+				lg.Error("debug server Shutdown", zap.Error(err))
+			}
 		}
 		//Завершаем, если состояние переменной было изменено
 		if !GrpcNotRunning.Load() {
 			a.gs.GracefulStop()
-			log.Println("grpc server shutdown")
+			lg.Info("grpc server shutdown")
+		}
+		if err := a.shutdownTracing(ctx); err != nil {
+			lg.Error("tracing Shutdown", zap.Error(err))
 		}
 		close(idleConnsClosed)
 	}()
 
 	//Основной поток http сервера
-	if a.Config.EnableHTTPS {
-		manager := &autocert.Manager{
-			// директория для хранения сертификатов
-			Cache: autocert.DirCache("cache-dir"),
-			// функция, принимающая Terms of Service издателя сертификатов
-			Prompt: autocert.AcceptTOS,
-			// перечень доменов, для которых будут поддерживаться сертификаты
-			HostPolicy: autocert.HostWhitelist("example.com"),
-		}
-		// конструируем сервер с поддержкой TLS
-		server = &http.Server{
-			Addr:    ":443",
-			Handler: r,
-			// для TLS-конфигурации используем менеджер сертификатов
-			TLSConfig: manager.TLSConfig(),
-		}
-		<-idleConnsClosed
-		log.Println("Server Shutdown gracefully")
-
-		//запуск https
-		if err := server.ListenAndServeTLS("", ""); !errors.Is(err, http.ErrServerClosed) {
-			// ошибки старта или остановки Listener
-			log.Fatalf("HTTP server ListenAndServe: %v", err)
-		}
-		<-idleConnsClosed
-		log.Println("Server Shutdown gracefully")
+	var err error
+	if a.TLS != nil {
+		//Сертификат берется из server.TLSConfig
+		err = server.ListenAndServeTLS("", "")
 	} else {
-		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-			// ошибки старта или остановки Listener
-			log.Fatalf("HTTP server ListenAndServe: %v", err)
-		}
+		err = server.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		// ошибки старта или остановки Listener
+		lg.Fatal("HTTP server ListenAndServe", zap.Error(err))
 	}
 	<-idleConnsClosed
-	log.Println("Server Shutdown gracefully")
+	lg.Info("Server Shutdown gracefully")
+	//Дописываем буфер логера и закрываем файл логов
+	if err := a.Logger.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "logger Close:", err)
+	}
 }
@@ -0,0 +1,109 @@
+// Текущая роль и блокировка пользователя для действующих токенов
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultRoleCacheTTL сколько действует прочитанная роль: смена роли и блокировка вступают в силу не позже
+const DefaultRoleCacheTTL = 30 * time.Second
+
+// roleCacheLimit размер кэша ролей, после которого из него удаляются устаревшие записи
+const roleCacheLimit = 10000
+
+// cachedRole роль или ErrUserDisabled из источника ролей
+type cachedRole struct {
+	role    string
+	err     error
+	expires time.Time
+}
+
+// roleCache кэш ролей пользователей на ttl
+type roleCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedRole
+}
+
+// newRoleCache создает кэш, ttl 0 - без кэша
+func newRoleCache(ttl time.Duration) *roleCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &roleCache{ttl: ttl, entries: make(map[string]cachedRole)}
+}
+
+// get роль из кэша, ok=false если записи нет или она устарела
+func (c *roleCache) get(userID string, now time.Time) (cachedRole, bool) {
+	if c == nil {
+		return cachedRole{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[userID]
+	if !ok || !now.Before(e.expires) {
+		return cachedRole{}, false
+	}
+	return e, true
+}
+
+// put сохранение роли, при переполнении сначала удаляются устаревшие записи
+func (c *roleCache) put(userID string, e cachedRole, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= roleCacheLimit {
+		for k, v := range c.entries {
+			if !now.Before(v.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= roleCacheLimit {
+			c.entries = make(map[string]cachedRole)
+		}
+	}
+	e.expires = now.Add(c.ttl)
+	c.entries[userID] = e
+}
+
+// CurrentClaims утверждения проверенного токена с текущей ролью пользователя
+//
+// Без источника ролей роль берется из токена. Для заблокированного пользователя - ErrUserDisabled,
+// удаленный или пониженный пользователь получает текущую роль, а не ту, с которой выпущен токен.
+func (m *Manager) CurrentClaims(ctx context.Context, tokenString string) (Claims, error) {
+	claims, err := m.ParseToken(tokenString)
+	if err != nil || m.roles == nil {
+		return claims, err
+	}
+	role, err := m.UserRole(ctx, claims.UserID)
+	if err != nil {
+		return Claims{}, err
+	}
+	claims.Role = role
+	return claims, nil
+}
+
+// UserRole текущая роль пользователя из источника ролей с кэшем, без источника - RoleUser
+//
+// Так проверяются пользователи без токена, например клиенты с сертификатом mTLS.
+// Для заблокированного пользователя - ErrUserDisabled.
+func (m *Manager) UserRole(ctx context.Context, userID string) (string, error) {
+	if m.roles == nil {
+		return RoleUser, nil
+	}
+	now := time.Now()
+	e, ok := m.roleCache.get(userID, now)
+	if !ok {
+		e.role, e.err = m.roles.UserRole(ctx, userID)
+		if e.err != nil && !errors.Is(e.err, ErrUserDisabled) {
+			return "", e.err
+		}
+		m.roleCache.put(userID, e, now)
+	}
+	return e.role, e.err
+}
@@ -0,0 +1,68 @@
+// Package auth содержит выпуск и проверку JWT пользователей
+// и общий для HTTP и gRPC пользователь в контексте запроса.
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/SversusN/shortener/internal/logger"
+)
+
+// Ошибки авторизации
+var (
+	ErrNoUser       = errors.New("user ID is missing")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Claims тиа для указания UserID
+type Claims struct {
+	jwt.RegisteredClaims
+	UserID  string
+	Role    string `json:"role,omitempty"` // пусто - RoleUser
+	Account bool   `json:"acc,omitempty"`  // токен выдан входом в учетную запись
+}
+
+// ctxKey ключ пользователя в контексте, доступен только через функции пакета
+type ctxKey struct{}
+
+// accountKey признак пользователя с учетной записью
+type accountKey struct{}
+
+// BearerToken токен из значения заголовка Authorization, пустая строка без токена
+func BearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// WithUserID сохраняет проверенного пользователя в контексте и в логере запроса
+func WithUserID(ctx context.Context, userID string) context.Context {
+	ctx = context.WithValue(ctx, ctxKey{}, userID)
+	return logger.WithUserID(ctx, userID)
+}
+
+// WithAccount отмечает пользователя запроса как вошедшего в учетную запись или по сертификату
+func WithAccount(ctx context.Context) context.Context {
+	return context.WithValue(ctx, accountKey{}, true)
+}
+
+// IsAccount пользователь запроса подтвержден учетной записью, а не анонимным токеном
+func IsAccount(ctx context.Context) bool {
+	isAccount, _ := ctx.Value(accountKey{}).(bool)
+	return isAccount
+}
+
+// UserIDFromCtx пользователь запроса, сохраненный WithUserID
+func UserIDFromCtx(ctx context.Context) (string, error) {
+	userID, ok := ctx.Value(ctxKey{}).(string)
+	if !ok || userID == "" {
+		return "", ErrNoUser
+	}
+	return userID, nil
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToken(t *testing.T) {
+	m, err := New(Options{TTL: time.Hour})
+	require.NoError(t, err)
+	assert.True(t, m.Ephemeral())
+	token, err := m.BuildNewToken("user1")
+	require.NoError(t, err)
+	userID, err := m.GetUserID(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+
+	_, err = m.GetUserID(token + "x")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	//Токен без подписи не принимается
+	_, err = m.GetUserID("eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0.eyJVc2VySUQiOiJ1c2VyMSJ9.")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestBearerToken(t *testing.T) {
+	assert.Equal(t, "abc", BearerToken("Bearer abc"))
+	assert.Equal(t, "abc", BearerToken("bearer abc"))
+	assert.Empty(t, BearerToken("Basic abc"))
+	assert.Empty(t, BearerToken("abc"))
+}
+
+func TestUserIDFromCtx(t *testing.T) {
+	_, err := UserIDFromCtx(context.Background())
+	assert.ErrorIs(t, err, ErrNoUser)
+	//Строковый ключ из метаданных не подменяет пользователя
+	ctx := context.WithValue(context.Background(), "user_id", "intruder")
+	_, err = UserIDFromCtx(ctx)
+	assert.ErrorIs(t, err, ErrNoUser)
+
+	userID, err := UserIDFromCtx(WithUserID(ctx, "user1"))
+	require.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+}
@@ -0,0 +1,116 @@
+// Публикация открытых ключей в формате JWKS
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+)
+
+// JWK открытый ключ в формате RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`   // модуль RSA
+	E         string `json:"e,omitempty"`   // экспонента RSA
+	Curve     string `json:"crv,omitempty"` // кривая OKP или EC
+	X         string `json:"x,omitempty"`   // открытый ключ OKP или координата x EC
+	Y         string `json:"y,omitempty"`   // координата y EC
+}
+
+// PublicKey открытый ключ из JWK стороннего издателя: RSA, EC P-256 или Ed25519
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	dec := base64.RawURLEncoding.DecodeString
+	switch k.KeyType {
+	case "RSA":
+		n, err := dec(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("auth: jwk %s: bad modulus: %w", k.KeyID, err)
+		}
+		e, err := dec(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("auth: jwk %s: bad exponent: %w", k.KeyID, err)
+		}
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("auth: jwk %s: bad rsa key", k.KeyID)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, fmt.Errorf("auth: jwk %s: unsupported curve %q", k.KeyID, k.Curve)
+		}
+		x, errX := dec(k.X)
+		y, errY := dec(k.Y)
+		if err := errors.Join(errX, errY); err != nil || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("auth: jwk %s: bad ec point", k.KeyID)
+		}
+		//Проверка, что точка лежит на кривой
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, fmt.Errorf("auth: jwk %s: %w", k.KeyID, err)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := dec(k.X)
+		if k.Curve != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("auth: jwk %s: bad ed25519 key", k.KeyID)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("auth: jwk %s: unsupported key type %q", k.KeyID, k.KeyType)
+}
+
+// JWKSet набор открытых ключей
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS открытые ключи для проверки токенов сторонними сервисами
+//
+// Секреты HS256 не публикуются.
+func (m *Manager) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range m.keys {
+		b64 := base64.RawURLEncoding.EncodeToString
+		switch pub := k.public().(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     k.ID,
+				Algorithm: AlgRS256,
+				Use:       "sig",
+				N:         b64(pub.N.Bytes()),
+				E:         b64(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     k.ID,
+				Algorithm: AlgEdDSA,
+				Use:       "sig",
+				Curve:     "Ed25519",
+				X:         b64(pub),
+			})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+// JWKSHandler обработчик /.well-known/jwks.json
+func (m *Manager) JWKSHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_ = json.NewEncoder(w).Encode(m.JWKS())
+}
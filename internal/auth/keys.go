@@ -0,0 +1,142 @@
+// Ключи подписи токенов
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Поддерживаемые алгоритмы подписи
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minSecretLen минимальная длина секрета HS256 в байтах
+const minSecretLen = 32
+
+// KeyConfig описание ключа в файле ключей
+type KeyConfig struct {
+	ID             string `json:"kid"`
+	Algorithm      string `json:"alg"`
+	Secret         string `json:"secret,omitempty"`           // секрет HS256
+	PrivateKeyFile string `json:"private_key_file,omitempty"` // PEM закрытого ключа RS256/EdDSA
+	PublicKeyFile  string `json:"public_key_file,omitempty"`  // PEM открытого ключа, только для проверки
+}
+
+// KeysFile файл ключей: active подписывает новые токены, остальные только проверяют
+//
+// Для ротации новый ключ добавляется в файл и становится active, старый
+// остается в списке, пока не истекут подписанные им токены.
+type KeysFile struct {
+	Active string      `json:"active"`
+	Keys   []KeyConfig `json:"keys"`
+}
+
+// Key ключ подписи или проверки токенов
+type Key struct {
+	ID     string
+	method jwt.SigningMethod
+	sign   interface{} // nil для ключа только для проверки
+	verify interface{}
+}
+
+// LoadKeysFile чтение файла ключей
+func LoadKeysFile(path string) (KeysFile, error) {
+	var kf KeysFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return kf, fmt.Errorf("auth: read keys file: %w", err)
+	}
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return kf, fmt.Errorf("auth: parse keys file: %w", err)
+	}
+	return kf, nil
+}
+
+// NewKey создает ключ по описанию
+func NewKey(kc KeyConfig) (*Key, error) {
+	if kc.ID == "" {
+		return nil, errors.New("auth: key without kid")
+	}
+	k := &Key{ID: kc.ID}
+	switch kc.Algorithm {
+	case AlgHS256, "":
+		if len(kc.Secret) < minSecretLen {
+			return nil, fmt.Errorf("auth: key %s: secret must be at least %d bytes", kc.ID, minSecretLen)
+		}
+		k.method = jwt.SigningMethodHS256
+		k.sign, k.verify = []byte(kc.Secret), []byte(kc.Secret)
+		return k, nil
+	case AlgRS256:
+		k.method = jwt.SigningMethodRS256
+	case AlgEdDSA:
+		k.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("auth: key %s: unsupported algorithm %q", kc.ID, kc.Algorithm)
+	}
+	if err := k.loadAsymmetric(kc); err != nil {
+		return nil, fmt.Errorf("auth: key %s: %w", kc.ID, err)
+	}
+	return k, nil
+}
+
+// loadAsymmetric чтение PEM ключей RS256/EdDSA
+func (k *Key) loadAsymmetric(kc KeyConfig) error {
+	rs := k.method == jwt.SigningMethodRS256
+	switch {
+	case kc.PrivateKeyFile != "":
+		data, err := os.ReadFile(kc.PrivateKeyFile)
+		if err != nil {
+			return err
+		}
+		if rs {
+			priv, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return err
+			}
+			k.sign, k.verify = priv, &priv.PublicKey
+			return nil
+		}
+		priv, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return err
+		}
+		k.sign, k.verify = priv, priv.(crypto.Signer).Public()
+		return nil
+	case kc.PublicKeyFile != "":
+		data, err := os.ReadFile(kc.PublicKeyFile)
+		if err != nil {
+			return err
+		}
+		if rs {
+			k.verify, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		} else {
+			k.verify, err = jwt.ParseEdPublicKeyFromPEM(data)
+		}
+		return err
+	}
+	return errors.New("private_key_file or public_key_file is required")
+}
+
+// canSign ключ содержит закрытую часть
+func (k *Key) canSign() bool {
+	return k.sign != nil
+}
+
+// public открытый ключ для JWKS, nil для HS256
+func (k *Key) public() crypto.PublicKey {
+	switch pub := k.verify.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return pub
+	}
+	return nil
+}
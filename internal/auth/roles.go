@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// Роли пользователей, передаются в токене
+const (
+	RoleUser    = "user"    // обычный пользователь, роль по умолчанию
+	RoleAuditor = "auditor" // чтение административного API
+	RoleAdmin   = "admin"   // полный доступ к административному API
+)
+
+// Roles все роли
+var Roles = []string{RoleUser, RoleAuditor, RoleAdmin}
+
+// Права административного API
+const (
+	PermAdminRead  = "admin:read"  // просмотр ссылок, пользователей и статистики
+	PermAdminWrite = "admin:write" // блокировка и удаление ссылок и пользователей, смена ролей
+)
+
+// rolePermissions права ролей, у RoleUser прав нет
+var rolePermissions = map[string][]string{
+	RoleAuditor: {PermAdminRead},
+	RoleAdmin:   {PermAdminRead, PermAdminWrite},
+}
+
+// ErrUserDisabled пользователь заблокирован администратором
+var ErrUserDisabled = errors.New("user is disabled")
+
+// RoleSource текущая роль пользователя при продлении токена,
+// для заблокированного пользователя - ErrUserDisabled
+type RoleSource interface {
+	UserRole(ctx context.Context, userID string) (string, error)
+}
+
+// roleKey ключ роли в контексте
+type roleKey struct{}
+
+// ValidRole известная роль
+func ValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+// WithRole сохраняет роль пользователя из токена в контексте
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFromCtx роль пользователя запроса, без роли - RoleUser
+func RoleFromCtx(ctx context.Context) string {
+	if role, ok := ctx.Value(roleKey{}).(string); ok && role != "" {
+		return role
+	}
+	return RoleUser
+}
+
+// Can запросу разрешено право perm
+//
+// Ключи API действуют только в своих областях доступа и административных прав не получают.
+func Can(ctx context.Context, perm string) bool {
+	if IsAPIKey(ctx) {
+		return false
+	}
+	return slices.Contains(rolePermissions[RoleFromCtx(ctx)], perm)
+}
+
+// WithClaims сохраняет пользователя, роль и признак учетной записи из проверенного токена в контексте
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	if claims.Account {
+		ctx = WithAccount(ctx)
+	}
+	return WithRole(WithUserID(ctx, claims.UserID), claims.Role)
+}
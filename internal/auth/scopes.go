@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Области доступа ключей API
+const (
+	ScopeShorten = "shorten" // сокращение ссылок
+	ScopeRead    = "read"    // чтение ссылок пользователя
+	ScopeDelete  = "delete"  // удаление ссылок пользователя
+)
+
+// Scopes все области доступа
+var Scopes = []string{ScopeShorten, ScopeRead, ScopeDelete}
+
+// scopesKey ключ областей доступа в контексте
+type scopesKey struct{}
+
+// apiKeyIDKey ключ ИД ключа API в контексте
+type apiKeyIDKey struct{}
+
+// WithScopes ограничивает запрос областями доступа ключа API
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// WithAPIKeyID сохраняет ИД ключа API запроса
+func WithAPIKeyID(ctx context.Context, keyID string) context.Context {
+	return context.WithValue(ctx, apiKeyIDKey{}, keyID)
+}
+
+// APIKeyIDFromCtx ИД ключа API запроса, пустая строка для токена пользователя
+func APIKeyIDFromCtx(ctx context.Context) string {
+	keyID, _ := ctx.Value(apiKeyIDKey{}).(string)
+	return keyID
+}
+
+// IsAPIKey запрос выполнен по ключу API, а не по токену пользователя
+func IsAPIKey(ctx context.Context) bool {
+	_, ok := ctx.Value(scopesKey{}).([]string)
+	return ok
+}
+
+// HasScope запросу разрешена область scope: токену пользователя - всегда, ключу API - по списку
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := ctx.Value(scopesKey{}).([]string)
+	return !ok || slices.Contains(scopes, scope)
+}
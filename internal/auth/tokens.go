@@ -0,0 +1,236 @@
+// Выпуск и проверка токенов пользователей
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// defaultKeyID kid ключа из секрета конфигурации
+const defaultKeyID = "default"
+
+// Options источники ключей и время жизни токенов
+type Options struct {
+	KeysFile     string        // файл ключей, см. KeysFile
+	Secret       string        // секрет HS256, если файл не задан
+	TTL          time.Duration // время жизни токена
+	Grace        time.Duration // окно продления истекшего токена, 0 - без продления
+	RoleCacheTTL time.Duration // кэш текущей роли пользователя, 0 - роль читается при каждом запросе
+}
+
+// Manager выпускает токены активным ключом и проверяет их любым из известных
+type Manager struct {
+	keys      map[string]*Key
+	active    *Key
+	ttl       time.Duration
+	grace     time.Duration
+	ephemeral bool
+	roles     RoleSource // nil - роль переносится из истекшего токена
+	roleCache *roleCache // nil - без кэша ролей
+}
+
+// New создает менеджер токенов
+//
+// Без файла и секрета генерируется случайный секрет: подделать токен нельзя,
+// но токены перестают действовать после перезапуска, см. Ephemeral.
+func New(opts Options) (*Manager, error) {
+	if opts.TTL <= 0 {
+		return nil, errors.New("auth: token lifetime must be positive")
+	}
+	m := &Manager{keys: make(map[string]*Key), ttl: opts.TTL, grace: opts.Grace, roleCache: newRoleCache(opts.RoleCacheTTL)}
+	switch {
+	case opts.KeysFile != "":
+		kf, err := LoadKeysFile(opts.KeysFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.addKeys(kf); err != nil {
+			return nil, err
+		}
+	default:
+		secret := opts.Secret
+		if secret == "" {
+			buf := make([]byte, minSecretLen)
+			if _, err := rand.Read(buf); err != nil {
+				return nil, err
+			}
+			secret = hex.EncodeToString(buf)
+			m.ephemeral = true
+		}
+		if err := m.addKeys(KeysFile{
+			Active: defaultKeyID,
+			Keys:   []KeyConfig{{ID: defaultKeyID, Algorithm: AlgHS256, Secret: secret}},
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// addKeys добавление ключей из файла и выбор активного
+func (m *Manager) addKeys(kf KeysFile) error {
+	for _, kc := range kf.Keys {
+		k, err := NewKey(kc)
+		if err != nil {
+			return err
+		}
+		if _, ok := m.keys[k.ID]; ok {
+			return fmt.Errorf("auth: duplicate kid %s", k.ID)
+		}
+		m.keys[k.ID] = k
+	}
+	active, ok := m.keys[kf.Active]
+	if !ok {
+		return fmt.Errorf("auth: active key %q not found", kf.Active)
+	}
+	if !active.canSign() {
+		return fmt.Errorf("auth: active key %q has no private key", kf.Active)
+	}
+	m.active = active
+	return nil
+}
+
+// Ephemeral ключ сгенерирован при старте и не переживет перезапуск
+func (m *Manager) Ephemeral() bool {
+	return m.ephemeral
+}
+
+// TTL время жизни выпускаемых токенов
+func (m *Manager) TTL() time.Duration {
+	return m.ttl
+}
+
+// CookieLifetime срок хранения куки: токен должен дожить в браузере до конца окна продления
+func (m *Manager) CookieLifetime() time.Duration {
+	return m.ttl + m.grace
+}
+
+// BuildNewToken функция генерации токена пользователю активным ключом
+func (m *Manager) BuildNewToken(userID string) (string, error) {
+	return m.BuildToken(userID, RoleUser)
+}
+
+// BuildToken токен пользователя с ролью
+func (m *Manager) BuildToken(userID, role string) (string, error) {
+	return m.buildToken(userID, role, false)
+}
+
+// BuildAccountToken токен пользователя, вошедшего в учетную запись
+func (m *Manager) BuildAccountToken(userID, role string) (string, error) {
+	return m.buildToken(userID, role, true)
+}
+
+// buildToken подпись утверждений активным ключом
+func (m *Manager) buildToken(userID, role string, account bool) (string, error) {
+	claims := Claims{RegisteredClaims: jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.ttl)),
+	},
+		UserID:  userID,
+		Account: account,
+	}
+	if role != RoleUser {
+		claims.Role = role
+	}
+
+	token := jwt.NewWithClaims(m.active.method, claims)
+	token.Header["kid"] = m.active.ID
+
+	stringToken, err := token.SignedString(m.active.sign)
+	if err != nil {
+		return "", errors.New("error signing token")
+	}
+	return stringToken, nil
+}
+
+// GetUserID получение ИД пользователя из проверенного токена
+func (m *Manager) GetUserID(tokenString string) (string, error) {
+	claims, err := m.ParseToken(tokenString)
+	if err != nil {
+		return "", err
+	}
+	return claims.UserID, nil
+}
+
+// ParseToken утверждения проверенного токена, роль заполнена всегда
+//
+// Ключ выбирается по kid, алгоритм токена должен совпадать с алгоритмом ключа.
+func (m *Manager) ParseToken(tokenString string) (Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, m.keyFunc)
+	if err != nil || !token.Valid || claims.UserID == "" {
+		return Claims{}, ErrInvalidToken
+	}
+	if claims.Role == "" {
+		claims.Role = RoleUser
+	}
+	return *claims, nil
+}
+
+// ParseWithGrace утверждения проверенного токена, истекший принимается до конца окна продления
+//
+// Так читается токен, который AuthMW еще продлил бы, см. Refresh.
+func (m *Manager) ParseWithGrace(tokenString string) (Claims, error) {
+	claims := &Claims{}
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	if _, err := parser.ParseWithClaims(tokenString, claims, m.keyFunc); err != nil || claims.UserID == "" {
+		return Claims{}, ErrInvalidToken
+	}
+	if claims.ExpiresAt == nil || time.Now().After(claims.ExpiresAt.Add(m.grace)) {
+		return Claims{}, ErrInvalidToken
+	}
+	if claims.Role == "" {
+		claims.Role = RoleUser
+	}
+	return *claims, nil
+}
+
+// SetRoleSource источник текущих ролей для продления токенов, CurrentClaims и UserRole
+//
+// Без него продленный токен сохраняет роль истекшего.
+func (m *Manager) SetRoleSource(rs RoleSource) {
+	m.roles = rs
+}
+
+// keyFunc ключ проверки для токена
+func (m *Manager) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	k, ok := m.keys[kid]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if t.Method.Alg() != k.method.Alg() {
+		return nil, ErrInvalidToken
+	}
+	return k.verify, nil
+}
+
+// Refresh выпуск нового токена вместо истекшего с тем же пользователем
+//
+// Подпись проверяется как обычно, срок действия - с учетом окна продления.
+// Роль берется из источника ролей, признак учетной записи сохраняется: смена роли и блокировка вступают в силу при продлении.
+// Новый токен подписывается активным ключом, что заодно переводит клиентов на него при ротации.
+func (m *Manager) Refresh(ctx context.Context, tokenString string) (Claims, string, error) {
+	claims, err := m.ParseWithGrace(tokenString)
+	if err != nil {
+		return Claims{}, "", err
+	}
+	if m.roles != nil {
+		role, err := m.roles.UserRole(ctx, claims.UserID)
+		if err != nil {
+			return Claims{}, "", err
+		}
+		claims.Role = role
+	}
+	token, err := m.buildToken(claims.UserID, claims.Role, claims.Account)
+	if err != nil {
+		return Claims{}, "", err
+	}
+	return claims, token, nil
+}
@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeys записывает файл ключей во временный каталог
+func writeKeys(t *testing.T, kf KeysFile) string {
+	data, err := json.Marshal(kf)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// writePEM записывает закрытый ключ в PKCS8 PEM
+func writePEM(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	f, err := os.CreateTemp(t.TempDir(), "key-*.pem")
+	require.NoError(t, err)
+	require.NoError(t, pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, f.Close())
+	return f.Name()
+}
+
+const (
+	secretA = "0123456789abcdef0123456789abcdef"
+	secretB = "fedcba9876543210fedcba9876543210"
+)
+
+func TestRotation(t *testing.T) {
+	oldKeys := writeKeys(t, KeysFile{Active: "a", Keys: []KeyConfig{{ID: "a", Algorithm: AlgHS256, Secret: secretA}}})
+	old, err := New(Options{KeysFile: oldKeys, TTL: time.Hour})
+	require.NoError(t, err)
+	oldToken, err := old.BuildNewToken("user1")
+	require.NoError(t, err)
+
+	//Новый активный ключ, старый остается для проверки
+	rotated, err := New(Options{KeysFile: writeKeys(t, KeysFile{Active: "b", Keys: []KeyConfig{
+		{ID: "a", Algorithm: AlgHS256, Secret: secretA},
+		{ID: "b", Algorithm: AlgHS256, Secret: secretB},
+	}}), TTL: time.Hour})
+	require.NoError(t, err)
+	userID, err := rotated.GetUserID(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+
+	newToken, err := rotated.BuildNewToken("user2")
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, "b", parsed.Header["kid"])
+
+	//После удаления ключа a его токены не принимаются
+	onlyB, err := New(Options{KeysFile: writeKeys(t, KeysFile{Active: "b", Keys: []KeyConfig{
+		{ID: "b", Algorithm: AlgHS256, Secret: secretB},
+	}}), TTL: time.Hour})
+	require.NoError(t, err)
+	_, err = onlyB.GetUserID(oldToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestRefresh(t *testing.T) {
+	//Срок округляется до секунд вниз, токен с наносекундным сроком истекает сразу
+	issuer, err := New(Options{Secret: secretA, TTL: time.Nanosecond})
+	require.NoError(t, err)
+	expired, err := issuer.BuildNewToken("user1")
+	require.NoError(t, err)
+
+	m, err := New(Options{Secret: secretA, TTL: time.Hour, Grace: time.Hour})
+	require.NoError(t, err)
+	_, err = m.GetUserID(expired)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	claims, token, err := m.Refresh(context.Background(), expired)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", claims.UserID)
+	userID, err := m.GetUserID(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+	assert.Equal(t, 2*time.Hour, m.CookieLifetime())
+
+	//Вне окна и с чужой подписью токен не продлевается
+	noGrace, err := New(Options{Secret: secretA, TTL: time.Hour})
+	require.NoError(t, err)
+	_, _, err = noGrace.Refresh(context.Background(), expired)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	other, err := New(Options{Secret: secretB, TTL: time.Hour, Grace: time.Hour})
+	require.NoError(t, err)
+	_, _, err = other.Refresh(context.Background(), expired)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	//Роль при продлении берется из источника ролей
+	roles := roleSourceFunc(func(_ context.Context, userID string) (string, error) {
+		if userID == "disabled" {
+			return "", ErrUserDisabled
+		}
+		return RoleAuditor, nil
+	})
+	m.SetRoleSource(roles)
+	_, token, err = m.Refresh(context.Background(), expired)
+	require.NoError(t, err)
+	parsed, err := m.ParseToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, RoleAuditor, parsed.Role)
+	assert.False(t, parsed.Account)
+
+	//Признак учетной записи переживает продление
+	account, err := issuer.BuildAccountToken("user1", RoleUser)
+	require.NoError(t, err)
+	_, token, err = m.Refresh(context.Background(), account)
+	require.NoError(t, err)
+	parsed, err = m.ParseToken(token)
+	require.NoError(t, err)
+	assert.True(t, parsed.Account)
+	disabled, err := issuer.BuildToken("disabled", RoleAdmin)
+	require.NoError(t, err)
+	_, _, err = m.Refresh(context.Background(), disabled)
+	assert.ErrorIs(t, err, ErrUserDisabled)
+}
+
+// roleSourceFunc источник ролей из функции
+type roleSourceFunc func(ctx context.Context, userID string) (string, error)
+
+// UserRole роль пользователя
+func (f roleSourceFunc) UserRole(ctx context.Context, userID string) (string, error) {
+	return f(ctx, userID)
+}
+
+func TestCurrentClaims(t *testing.T) {
+	ctx := context.Background()
+	m, err := New(Options{Secret: secretA, TTL: time.Hour, RoleCacheTTL: time.Hour})
+	require.NoError(t, err)
+	admin, err := m.BuildToken("user1", RoleAdmin)
+	require.NoError(t, err)
+
+	//Без источника ролей роль из токена
+	claims, err := m.CurrentClaims(ctx, admin)
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, claims.Role)
+
+	role, calls := RoleUser, 0
+	m.SetRoleSource(roleSourceFunc(func(_ context.Context, userID string) (string, error) {
+		calls++
+		if role == "" {
+			return "", ErrUserDisabled
+		}
+		return role, nil
+	}))
+	//Пониженный администратор получает текущую роль, она кэшируется
+	claims, err = m.CurrentClaims(ctx, admin)
+	require.NoError(t, err)
+	assert.Equal(t, RoleUser, claims.Role)
+	role = RoleAdmin
+	claims, err = m.CurrentClaims(ctx, admin)
+	require.NoError(t, err)
+	assert.Equal(t, RoleUser, claims.Role)
+	assert.Equal(t, 1, calls)
+
+	//Без кэша блокировка действует сразу
+	uncached, err := New(Options{Secret: secretA, TTL: time.Hour})
+	require.NoError(t, err)
+	uncached.SetRoleSource(m.roles)
+	role = ""
+	_, err = uncached.CurrentClaims(ctx, admin)
+	assert.ErrorIs(t, err, ErrUserDisabled)
+	_, err = uncached.CurrentClaims(ctx, "broken")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestBadKeys(t *testing.T) {
+	_, err := New(Options{Secret: "short", TTL: time.Hour})
+	assert.Error(t, err)
+	_, err = New(Options{KeysFile: writeKeys(t, KeysFile{Active: "x", Keys: []KeyConfig{
+		{ID: "a", Algorithm: AlgHS256, Secret: secretA},
+	}}), TTL: time.Hour})
+	assert.Error(t, err)
+	_, err = New(Options{KeysFile: writeKeys(t, KeysFile{Active: "a", Keys: []KeyConfig{
+		{ID: "a", Algorithm: "none"},
+	}}), TTL: time.Hour})
+	assert.Error(t, err)
+}
+
+func TestAsymmetricKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keys := writeKeys(t, KeysFile{Active: "rsa", Keys: []KeyConfig{
+		{ID: "rsa", Algorithm: AlgRS256, PrivateKeyFile: writePEM(t, rsaKey)},
+		{ID: "ed", Algorithm: AlgEdDSA, PrivateKeyFile: writePEM(t, edKey)},
+		{ID: "hs", Algorithm: AlgHS256, Secret: secretA},
+	}})
+	m, err := New(Options{KeysFile: keys, TTL: time.Hour})
+	require.NoError(t, err)
+
+	token, err := m.BuildNewToken("user1")
+	require.NoError(t, err)
+	userID, err := m.GetUserID(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+
+	//Токен без kid не принимается, с kid ed - проверяется ключом EdDSA
+	edToken, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, Claims{UserID: "user2"}).SignedString(edKey)
+	require.NoError(t, err)
+	_, err = m.GetUserID(edToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	edJWT := jwt.NewWithClaims(jwt.SigningMethodEdDSA, Claims{UserID: "user2"})
+	edJWT.Header["kid"] = "ed"
+	edToken, err = edJWT.SignedString(edKey)
+	require.NoError(t, err)
+	userID, err = m.GetUserID(edToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user2", userID)
+
+	//Подпись HS256 открытым ключом RSA под kid rsa отклоняется
+	pubDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: "intruder"})
+	forged.Header["kid"] = "rsa"
+	forgedToken, err := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	require.NoError(t, err)
+	_, err = m.GetUserID(forgedToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	//В JWKS только открытые ключи, секрет HS256 не публикуется
+	rec := httptest.NewRecorder()
+	m.JWKSHandler(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	var set JWKSet
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 2)
+	assert.Equal(t, "ed", set.Keys[0].KeyID)
+	assert.Equal(t, "OKP", set.Keys[0].KeyType)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey)), set.Keys[0].X)
+	assert.Equal(t, "rsa", set.Keys[1].KeyID)
+	n, err := base64.RawURLEncoding.DecodeString(set.Keys[1].N)
+	require.NoError(t, err)
+	assert.Equal(t, 0, new(big.Int).SetBytes(n).Cmp(rsaKey.N))
+	assert.Equal(t, "AQAB", set.Keys[1].E)
+	assert.NotContains(t, rec.Body.String(), secretA)
+}
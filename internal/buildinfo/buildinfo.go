@@ -0,0 +1,59 @@
+// Package buildinfo описывает сборку и конфигурацию запущенного сервиса.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Info сведения о сборке для поддержки
+type Info struct {
+	Version     string   `json:"version"`                // версия из флагов линковщика
+	BuildDate   string   `json:"build_date"`             // дата сборки из флагов линковщика
+	Commit      string   `json:"commit"`                 // коммит из флагов линковщика
+	GoVersion   string   `json:"go_version"`             // версия компилятора
+	VCSRevision string   `json:"vcs_revision,omitempty"` // ревизия, записанная go build
+	VCSTime     string   `json:"vcs_time,omitempty"`     // время ревизии
+	VCSModified bool     `json:"vcs_modified"`           // сборка из измененного дерева
+	Storage     string   `json:"storage"`                // используемое хранилище
+	Features    []string `json:"features"`               // включенные возможности
+}
+
+// New собирает сведения о сборке
+//
+// Значения линковщика дополняются данными debug.ReadBuildInfo, которые go build
+// записывает сам и которые есть даже в сборке без -ldflags.
+func New(version, date, commit string) Info {
+	info := Info{
+		Version:   version,
+		BuildDate: date,
+		Commit:    commit,
+		GoVersion: runtime.Version(),
+		Features:  []string{},
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if bi.GoVersion != "" {
+		info.GoVersion = bi.GoVersion
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.VCSRevision = s.Value
+		case "vcs.time":
+			info.VCSTime = s.Value
+		case "vcs.modified":
+			info.VCSModified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// WithRuntime копия сведений с хранилищем и включенными возможностями
+func (i Info) WithRuntime(storage string, features ...string) Info {
+	i.Storage = storage
+	i.Features = append([]string{}, features...)
+	return i
+}
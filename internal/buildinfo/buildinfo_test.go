@@ -0,0 +1,22 @@
+package buildinfo
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	info := New("v1.2.3", "2024-01-01", "abc123")
+	assert.Equal(t, "v1.2.3", info.Version)
+	assert.Equal(t, "abc123", info.Commit)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+
+	features := []string{"grpc"}
+	withRuntime := info.WithRuntime("memory", features...)
+	features[0] = "changed"
+	assert.Equal(t, "memory", withRuntime.Storage)
+	assert.Equal(t, []string{"grpc"}, withRuntime.Features)
+	assert.Empty(t, info.Storage)
+}
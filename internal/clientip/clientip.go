@@ -0,0 +1,174 @@
+// Package clientip определяет адрес клиента запроса с учетом доверенных прокси.
+//
+// Заголовки X-Forwarded-For и X-Real-IP учитываются, только если соединение пришло
+// от доверенного прокси, иначе адресом клиента считается адрес соединения.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Заголовки с адресом клиента, в gRPC - ключи метаданных в нижнем регистре
+const (
+	ForwardedForHeader = "X-Forwarded-For"
+	RealIPHeader       = "X-Real-IP"
+)
+
+// Nets список подсетей IPv4 и IPv6
+type Nets []*net.IPNet
+
+// ParseNets разбор подсетей CIDR, одиночный адрес считается подсетью из одного адреса
+func ParseNets(cidrs []string) (Nets, error) {
+	nets := make(Nets, 0, len(cidrs))
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", c)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet %q: %w", c, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// Contains адрес входит в одну из подсетей
+func (n Nets) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range n {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolver определение адреса клиента, nil - без доверенных прокси
+type Resolver struct {
+	proxies Nets
+}
+
+// New создает определитель адреса с подсетями доверенных прокси
+func New(trustedProxies []string) (*Resolver, error) {
+	proxies, err := ParseNets(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{proxies: proxies}, nil
+}
+
+// FromRequest адрес клиента HTTP запроса, nil если адрес соединения не разобран
+func (r *Resolver) FromRequest(req *http.Request) net.IP {
+	return r.resolve(parseHostPort(req.RemoteAddr), req.Header.Values(ForwardedForHeader), req.Header.Get(RealIPHeader))
+}
+
+// FromContext адрес клиента вызова gRPC по peer.FromContext и метаданным
+func (r *Resolver) FromContext(ctx context.Context) net.IP {
+	var remote net.IP
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remote = parseHostPort(p.Addr.String())
+	}
+	var forwarded []string
+	var realIP string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		forwarded = md.Get(ForwardedForHeader)
+		if values := md.Get(RealIPHeader); len(values) > 0 {
+			realIP = values[0]
+		}
+	}
+	return r.resolve(remote, forwarded, realIP)
+}
+
+// resolve адрес клиента: цепочка X-Forwarded-For справа налево до первого недоверенного адреса,
+// затем X-Real-IP, если соединение от доверенного прокси
+func (r *Resolver) resolve(remote net.IP, forwarded []string, realIP string) net.IP {
+	if remote == nil || r == nil || !r.proxies.Contains(remote) {
+		return remote
+	}
+	var chain []string
+	for _, v := range forwarded {
+		chain = append(chain, strings.Split(v, ",")...)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(chain[i]))
+		if ip == nil {
+			//Испорченную цепочку дальше не разбираем
+			break
+		}
+		if !r.proxies.Contains(ip) {
+			return ip
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(realIP)); ip != nil {
+		return ip
+	}
+	return remote
+}
+
+// parseHostPort адрес из host:port или из адреса без порта
+func parseHostPort(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return net.ParseIP(host)
+}
+
+// Gate доступ только для клиентов из доверенных подсетей, nil или пустой список подсетей закрывает доступ
+type Gate struct {
+	subnets Nets
+	ips     *Resolver
+}
+
+// NewGate создает проверку доверенных подсетей, адрес клиента определяет ips
+func NewGate(subnets []string, ips *Resolver) (*Gate, error) {
+	nets, err := ParseNets(subnets)
+	if err != nil {
+		return nil, err
+	}
+	return &Gate{subnets: nets, ips: ips}, nil
+}
+
+// Enabled задана хотя бы одна доверенная подсеть
+func (g *Gate) Enabled() bool {
+	return g != nil && len(g.subnets) > 0
+}
+
+// AllowRequest адрес клиента HTTP запроса входит в доверенные подсети
+func (g *Gate) AllowRequest(r *http.Request) bool {
+	if !g.Enabled() {
+		return false
+	}
+	return g.subnets.Contains(g.ips.FromRequest(r))
+}
+
+// AllowContext адрес клиента вызова gRPC входит в доверенные подсети
+func (g *Gate) AllowContext(ctx context.Context) bool {
+	if !g.Enabled() {
+		return false
+	}
+	return g.subnets.Contains(g.ips.FromContext(ctx))
+}
@@ -0,0 +1,88 @@
+package clientip
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestFromRequest(t *testing.T) {
+	r, err := New([]string{"10.0.0.0/8", "fd00::/8", "192.168.1.1"})
+	require.NoError(t, err)
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		realIP    string
+		want      string
+	}{
+		{name: "direct client", remote: "203.0.113.5:1234", want: "203.0.113.5"},
+		{name: "untrusted peer headers ignored", remote: "203.0.113.5:1234", forwarded: "198.51.100.1", realIP: "198.51.100.2", want: "203.0.113.5"},
+		{name: "trusted proxy", remote: "10.1.2.3:80", forwarded: "198.51.100.1", want: "198.51.100.1"},
+		{name: "spoofed left entry", remote: "10.1.2.3:80", forwarded: "1.1.1.1, 198.51.100.1, 10.0.0.2", want: "198.51.100.1"},
+		{name: "real ip from proxy", remote: "[fd00::1]:80", realIP: "2001:db8::1", want: "2001:db8::1"},
+		{name: "single address proxy", remote: "192.168.1.1:80", forwarded: "198.51.100.7", want: "198.51.100.7"},
+		{name: "proxy without headers", remote: "10.1.2.3:80", want: "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				req.Header.Set(ForwardedForHeader, tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set(RealIPHeader, tt.realIP)
+			}
+			assert.Equal(t, tt.want, r.FromRequest(req).String())
+		})
+	}
+	_, err = New([]string{"not-a-subnet"})
+	assert.Error(t, err)
+}
+
+func TestGate(t *testing.T) {
+	ips, err := New([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	g, err := NewGate([]string{"192.168.0.0/16", "2001:db8::/32"}, ips)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.168.1.10:1234"
+	assert.True(t, g.AllowRequest(req))
+	req.RemoteAddr = "[2001:db8::5]:443"
+	assert.True(t, g.AllowRequest(req))
+
+	//Заголовок от недоверенного клиента не дает доступа
+	req.RemoteAddr = "203.0.113.5:1234"
+	req.Header.Set(RealIPHeader, "192.168.1.10")
+	assert.False(t, g.AllowRequest(req))
+
+	//Через доверенный прокси учитывается адрес клиента
+	req.RemoteAddr = "10.0.0.1:80"
+	req.Header.Set(ForwardedForHeader, "192.168.1.10")
+	assert.True(t, g.AllowRequest(req))
+
+	//gRPC без адреса соединения
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-real-ip", "192.168.1.10"))
+	assert.False(t, g.AllowContext(ctx))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 80}})
+	assert.True(t, g.AllowContext(ctx))
+
+	//Пустой список подсетей закрывает доступ
+	empty, err := NewGate(nil, ips)
+	require.NoError(t, err)
+	assert.False(t, empty.Enabled())
+	assert.False(t, empty.AllowContext(ctx))
+	var none *Gate
+	assert.False(t, none.AllowRequest(req))
+
+	_, err = NewGate([]string{"bad"}, ips)
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+// Package debugsrv содержит обработчики отладочного сервера: pprof, expvar,
+// статистику рантайма и снятие CPU профиля по запросу.
+package debugsrv
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Ограничения снятия CPU профиля
+const (
+	defaultProfileDuration = 30 * time.Second
+	maxProfileDuration     = 2 * time.Minute
+)
+
+// ErrProfileInProgress профиль уже снимается
+var ErrProfileInProgress = errors.New("cpu profile is already in progress")
+
+// Debug обработчики отладочного сервера
+type Debug struct {
+	profilesDir string
+	lg          *zap.Logger
+	mu          sync.Mutex // одновременно снимается только один CPU профиль
+}
+
+// New создает обработчики, профили сохраняются в profilesDir
+func New(profilesDir string, lg *zap.Logger) *Debug {
+	return &Debug{profilesDir: profilesDir, lg: lg}
+}
+
+// Routes маршруты отладочного сервера
+func (d *Debug) Routes(r chi.Router) {
+	r.HandleFunc("/debug/pprof/", pprof.Index)
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	r.HandleFunc("/debug/pprof/{name}", pprof.Index)
+	r.Handle("/debug/vars", expvar.Handler())
+	r.Get("/debug/runtime", d.HandlerRuntime)
+	r.Post("/debug/profile/cpu", d.HandlerCPUProfile)
+}
+
+// runtimeStats статистика рантайма и сборщика мусора
+type runtimeStats struct {
+	Goroutines   int    `json:"goroutines"`
+	GOMAXPROCS   int    `json:"gomaxprocs"`
+	NumCPU       int    `json:"num_cpu"`
+	HeapAlloc    uint64 `json:"heap_alloc_bytes"`
+	HeapSys      uint64 `json:"heap_sys_bytes"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	NextGC       uint64 `json:"next_gc_bytes"`
+	NumGC        uint32 `json:"num_gc"`
+	PauseTotalNs uint64 `json:"gc_pause_total_ns"`
+	LastGC       string `json:"last_gc,omitempty"`
+}
+
+// HandlerRuntime статистика горутин, памяти и сборщика мусора
+func (d *Debug) HandlerRuntime(w http.ResponseWriter, _ *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	stats := runtimeStats{
+		Goroutines:   runtime.NumGoroutine(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		NumCPU:       runtime.NumCPU(),
+		HeapAlloc:    ms.HeapAlloc,
+		HeapSys:      ms.HeapSys,
+		HeapObjects:  ms.HeapObjects,
+		NextGC:       ms.NextGC,
+		NumGC:        ms.NumGC,
+		PauseTotalNs: ms.PauseTotalNs,
+	}
+	if ms.LastGC > 0 {
+		stats.LastGC = time.Unix(0, int64(ms.LastGC)).UTC().Format(time.RFC3339Nano)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+// cpuProfileResponse ответ на снятие профиля
+type cpuProfileResponse struct {
+	File     string `json:"file"`
+	Duration string `json:"duration"`
+}
+
+// HandlerCPUProfile снимает CPU профиль за seconds секунд и сохраняет его в каталог профилей
+//
+// Файл можно сравнить с базовым: go tool pprof -diff_base=profiles/base.pprof <файл>.
+func (d *Debug) HandlerCPUProfile(w http.ResponseWriter, r *http.Request) {
+	duration := defaultProfileDuration
+	if v := r.URL.Query().Get("seconds"); v != "" {
+		sec, err := strconv.Atoi(v)
+		if err != nil || sec <= 0 || time.Duration(sec)*time.Second > maxProfileDuration {
+			http.Error(w, fmt.Sprintf("seconds must be from 1 to %d", int(maxProfileDuration.Seconds())), http.StatusBadRequest)
+			return
+		}
+		duration = time.Duration(sec) * time.Second
+	}
+	file, err := d.CaptureCPUProfile(r, duration)
+	switch {
+	case errors.Is(err, ErrProfileInProgress):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		d.lg.Error("cpu profile capture failed", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cpuProfileResponse{File: file, Duration: duration.String()})
+}
+
+// CaptureCPUProfile снимает профиль, отмена запроса завершает его досрочно
+func (d *Debug) CaptureCPUProfile(r *http.Request, duration time.Duration) (string, error) {
+	if !d.mu.TryLock() {
+		return "", ErrProfileInProgress
+	}
+	defer d.mu.Unlock()
+
+	if err := os.MkdirAll(d.profilesDir, 0o755); err != nil {
+		return "", err
+	}
+	name := filepath.Join(d.profilesDir, "cpu-"+time.Now().UTC().Format("20060102T150405")+".pprof")
+	f, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	//Профиль мог запустить и /debug/pprof/profile
+	if err := runtimepprof.StartCPUProfile(f); err != nil {
+		_ = os.Remove(name)
+		return "", ErrProfileInProgress
+	}
+	timer := time.NewTimer(duration)
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+		timer.Stop()
+	}
+	runtimepprof.StopCPUProfile()
+	d.lg.Info("cpu profile captured", zap.String("file", name))
+	return name, f.Close()
+}
@@ -0,0 +1,50 @@
+package debugsrv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newRouter(dir string) chi.Router {
+	r := chi.NewRouter()
+	New(dir, zap.NewNop()).Routes(r)
+	return r
+}
+
+func TestRuntime(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newRouter(t.TempDir()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/runtime", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var stats runtimeStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Positive(t, stats.Goroutines)
+	assert.Positive(t, stats.HeapAlloc)
+}
+
+func TestCPUProfile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	r := newRouter(dir)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/profile/cpu?seconds=1000", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/profile/cpu?seconds=1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var res cpuProfileResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, dir, filepath.Dir(res.File))
+	info, err := os.Stat(res.File)
+	require.NoError(t, err)
+	assert.Positive(t, info.Size())
+}
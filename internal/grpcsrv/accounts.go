@@ -0,0 +1,69 @@
+package grpcsrv
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/SversusN/shortener/internal/accounts"
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/grpcsrv/interceptors"
+	pb "github.com/SversusN/shortener/internal/grpcsrv/proto"
+	"github.com/SversusN/shortener/internal/logger"
+)
+
+// Register регистрирует пользователя и возвращает его токен.
+func (s *ShortenerServer) Register(ctx context.Context, in *pb.CredentialsReq) (*pb.SessionRes, error) {
+	session, err := s.accounts.Register(ctx, in.GetLogin(), in.GetPassword())
+	switch {
+	case errors.Is(err, accounts.ErrInvalidLogin), errors.Is(err, accounts.ErrWeakPassword):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, accounts.ErrLoginTaken):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case err != nil:
+		logger.FromCtx(ctx).Error("register failed", zap.Error(err))
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+	return s.sessionRes(ctx, in, session)
+}
+
+// Login проверяет пароль и возвращает токен пользователя.
+//
+// Выхода в gRPC нет: токен хранится у клиента, и клиент просто перестает его передавать.
+func (s *ShortenerServer) Login(ctx context.Context, in *pb.CredentialsReq) (*pb.SessionRes, error) {
+	session, err := s.accounts.Login(ctx, in.GetLogin(), in.GetPassword())
+	switch {
+	case errors.Is(err, accounts.ErrInvalidCredentials):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, accounts.ErrUserDisabled):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		logger.FromCtx(ctx).Error("login failed", zap.Error(err))
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+	return s.sessionRes(ctx, in, session)
+}
+
+// sessionRes перенос анонимных ссылок по запросу и ответ с токеном
+func (s *ShortenerServer) sessionRes(ctx context.Context, in *pb.CredentialsReq, session accounts.Session) (*pb.SessionRes, error) {
+	var claimed int
+	if in.GetClaimAnonymous() {
+		var token string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(interceptors.AuthorizationMetaKey); len(values) > 0 {
+				token = auth.BearerToken(values[0])
+			}
+		}
+		var err error
+		claimed, err = s.accounts.Claim(ctx, token, session.UserID)
+		if err != nil {
+			logger.FromCtx(ctx).Error("claim failed", zap.Error(err))
+			return nil, status.Error(codes.Internal, "Internal server error")
+		}
+	}
+	return &pb.SessionRes{UserId: session.UserID, Token: session.Token, Claimed: int64(claimed)}, nil
+}
@@ -0,0 +1,136 @@
+package grpcsrv
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/SversusN/shortener/internal/admin"
+	pb "github.com/SversusN/shortener/internal/grpcsrv/proto"
+	"github.com/SversusN/shortener/internal/logger"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+	"github.com/SversusN/shortener/internal/storage/storage"
+)
+
+// AdminServer административный сервис, права по ролям проверяет AuthInterceptor.
+type AdminServer struct {
+	pb.UnimplementedAdminServer
+	admin   *admin.Service
+	storage storage.Storage
+}
+
+// GetStats возвращает статистику ссылок и пользователей.
+func (s *AdminServer) GetStats(ctx context.Context, _ *pb.GetStatsReq) (*pb.GetStatsRes, error) {
+	return statsRes(ctx, s.storage)
+}
+
+// ListURLs возвращает ссылки всех пользователей по фильтру.
+func (s *AdminServer) ListURLs(ctx context.Context, in *pb.ListURLsReq) (*pb.ListURLsRes, error) {
+	urls, err := s.admin.ListURLs(ctx, entity.URLFilter{
+		Query:  in.GetQuery(),
+		UserID: in.GetUserId(),
+		Limit:  int(in.GetLimit()),
+		Offset: int(in.GetOffset()),
+	})
+	if err != nil {
+		return nil, adminError(ctx, err)
+	}
+	res := &pb.ListURLsRes{Urls: make([]*pb.AdminURL, 0, len(urls))}
+	for _, u := range urls {
+		res.Urls = append(res.Urls, &pb.AdminURL{
+			ShortUrl:    u.ShortURL,
+			OriginalUrl: u.OriginalURL,
+			UserId:      u.UserID,
+			WorkspaceId: u.WorkspaceID,
+			CreatedAt:   timestamppb.New(u.CreatedAt),
+			Deleted:     u.IsDeleted,
+			Disabled:    u.IsDisabled,
+		})
+	}
+	return res, nil
+}
+
+// ListUsers возвращает зарегистрированных пользователей по фильтру.
+func (s *AdminServer) ListUsers(ctx context.Context, in *pb.ListUsersReq) (*pb.ListUsersRes, error) {
+	users, err := s.admin.ListUsers(ctx, entity.UserFilter{
+		Query:  in.GetQuery(),
+		Limit:  int(in.GetLimit()),
+		Offset: int(in.GetOffset()),
+	})
+	if err != nil {
+		return nil, adminError(ctx, err)
+	}
+	res := &pb.ListUsersRes{Users: make([]*pb.AdminUser, 0, len(users))}
+	for _, u := range users {
+		res.Users = append(res.Users, &pb.AdminUser{
+			Id:        u.ID,
+			Login:     u.Login,
+			Issuer:    u.Issuer,
+			Subject:   u.Subject,
+			Role:      u.Role,
+			Disabled:  u.Disabled,
+			CreatedAt: timestamppb.New(u.CreatedAt),
+		})
+	}
+	return res, nil
+}
+
+// SetURLDisabled блокирует или разблокирует ссылку.
+func (s *AdminServer) SetURLDisabled(ctx context.Context, in *pb.SetURLDisabledReq) (*pb.AdminRes, error) {
+	if err := s.admin.SetURLDisabled(ctx, in.GetShortUrl(), in.GetDisabled()); err != nil {
+		return nil, adminError(ctx, err)
+	}
+	return &pb.AdminRes{}, nil
+}
+
+// DeleteURL удаляет ссылку любого пользователя.
+func (s *AdminServer) DeleteURL(ctx context.Context, in *pb.DeleteURLReq) (*pb.AdminRes, error) {
+	if err := s.admin.DeleteURL(ctx, in.GetShortUrl()); err != nil {
+		return nil, adminError(ctx, err)
+	}
+	return &pb.AdminRes{}, nil
+}
+
+// SetUserDisabled блокирует или разблокирует пользователя.
+func (s *AdminServer) SetUserDisabled(ctx context.Context, in *pb.SetUserDisabledReq) (*pb.AdminRes, error) {
+	if err := s.admin.SetUserDisabled(ctx, in.GetUserId(), in.GetDisabled()); err != nil {
+		return nil, adminError(ctx, err)
+	}
+	return &pb.AdminRes{}, nil
+}
+
+// SetUserRole меняет роль пользователя.
+func (s *AdminServer) SetUserRole(ctx context.Context, in *pb.SetUserRoleReq) (*pb.AdminRes, error) {
+	if err := s.admin.SetUserRole(ctx, in.GetUserId(), in.GetRole()); err != nil {
+		return nil, adminError(ctx, err)
+	}
+	return &pb.AdminRes{}, nil
+}
+
+// DeleteUser удаляет пользователя с его ссылками и ключами API.
+func (s *AdminServer) DeleteUser(ctx context.Context, in *pb.DeleteUserReq) (*pb.DeleteUserRes, error) {
+	deleted, err := s.admin.DeleteUser(ctx, in.GetUserId())
+	if err != nil {
+		return nil, adminError(ctx, err)
+	}
+	return &pb.DeleteUserRes{DeletedUrls: int64(deleted)}, nil
+}
+
+// adminError код gRPC для ошибки административного сервиса
+func adminError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, admin.ErrNotFound):
+		return status.Error(codes.NotFound, "Not found")
+	case errors.Is(err, admin.ErrInvalidRole):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, admin.ErrSelf):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		logger.FromCtx(ctx).Error("admin operation failed", zap.Error(err))
+		return status.Error(codes.Internal, "Internal server error")
+	}
+}
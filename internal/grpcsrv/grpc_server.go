@@ -4,57 +4,109 @@ package grpcsrv
 import (
 	"context"
 	"errors"
-	"google.golang.org/grpc/metadata"
-	"net"
 	"sync"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/SversusN/shortener/config"
+	"github.com/SversusN/shortener/internal/accounts"
+	"github.com/SversusN/shortener/internal/admin"
+	"github.com/SversusN/shortener/internal/apikeys"
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/buildinfo"
+	"github.com/SversusN/shortener/internal/clientip"
 	"github.com/SversusN/shortener/internal/grpcsrv/interceptors"
 	pb "github.com/SversusN/shortener/internal/grpcsrv/proto"
+	"github.com/SversusN/shortener/internal/health"
 	"github.com/SversusN/shortener/internal/internalerrors"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/metrics"
 	"github.com/SversusN/shortener/internal/pkg/utils"
+	"github.com/SversusN/shortener/internal/ratelimit"
 	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
 	"github.com/SversusN/shortener/internal/storage/storage"
+	"github.com/SversusN/shortener/internal/workspaces"
 )
 
 // ShortenerServer описывает тип gRPC сервера.
 type ShortenerServer struct {
 	pb.UnimplementedShortenerServer
-	ctx     *context.Context
-	storage storage.Storage
-	cfg     *config.Config
-	wg      *sync.WaitGroup
+	ctx      *context.Context
+	storage  storage.Storage
+	cfg      *config.Config
+	wg       *sync.WaitGroup
+	info     buildinfo.Info
+	accounts *accounts.Service
+	trusted  *clientip.Gate
+}
+
+// Options зависимости сервера
+type Options struct {
+	Metrics    *metrics.Metrics
+	Logger     *logger.ServerLogger
+	Health     *health.Checker
+	BuildInfo  buildinfo.Info
+	Tokens     *auth.Manager       // JWT пользователей
+	Accounts   *accounts.Service   // регистрация и вход по паролю
+	APIKeys    *apikeys.Service    // ключи API, nil - ключи не принимаются
+	Admin      *admin.Service      // nil - сервис Admin не регистрируется
+	Workspaces *workspaces.Service // nil - метаданные x-workspace-id не учитываются
+	RateLimit  *ratelimit.Limiter  // nil - без ограничения частоты вызовов
+	ClientIP   *clientip.Resolver  // адрес клиента для лимитов
+	Trusted    *clientip.Gate      // доверенные подсети, общие с HTTP; nil - доступ закрыт
 }
 
 // NewGRPCServer создает и возвращает новый сервер.
-func NewGRPCServer(ctx *context.Context, storage storage.Storage, cfg *config.Config, wg *sync.WaitGroup) *grpc.Server {
-	authInterceptor := interceptors.NewAuthInterceptor(*ctx)
-	s := grpc.NewServer(
-		grpc.ChainUnaryInterceptor(interceptors.LoggerInterceptor, authInterceptor.AuthenticateUser),
-	)
-	pb.RegisterShortenerServer(s, &ShortenerServer{ctx: ctx, storage: storage, cfg: cfg, wg: wg})
+//
+// o - зависимости сервера, opts - дополнительные опции сервера, например grpc.Creds для TLS.
+func NewGRPCServer(ctx *context.Context, storage storage.Storage, cfg *config.Config, wg *sync.WaitGroup, o Options, opts ...grpc.ServerOption) *grpc.Server {
+	authInterceptor := interceptors.NewAuthInterceptor(*ctx, o.Tokens, o.APIKeys)
+	chain := []grpc.UnaryServerInterceptor{
+		interceptors.TracingInterceptor,
+		interceptors.NewRequestIDInterceptor(o.Logger),
+		interceptors.NewMetricsInterceptor(o.Metrics),
+		interceptors.LoggerInterceptor,
+		authInterceptor.AuthenticateUser,
+	}
+	if o.Workspaces != nil {
+		chain = append(chain, interceptors.NewWorkspaceInterceptor(o.Workspaces))
+	}
+	if o.RateLimit != nil {
+		chain = append(chain, interceptors.NewRateLimitInterceptor(o.RateLimit, o.ClientIP))
+	}
+	s := grpc.NewServer(append(opts, grpc.ChainUnaryInterceptor(chain...))...)
+	pb.RegisterShortenerServer(s, &ShortenerServer{ctx: ctx, storage: storage, cfg: cfg, wg: wg, info: o.BuildInfo, accounts: o.Accounts, trusted: o.Trusted})
+	if o.Admin != nil {
+		pb.RegisterAdminServer(s, &AdminServer{admin: o.Admin, storage: storage})
+	}
+	healthpb.RegisterHealthServer(s, NewHealthServer(o.Health))
 	return s
 }
 
 // ShortenURL обрабатывает запрос на сокращение ссылки.
 func (s *ShortenerServer) ShortenURL(ctx context.Context, in *pb.URLRequest) (*pb.URLResponse, error) {
 	var response pb.URLResponse
-	userID, err := utils.GetUserIDFromCtx(ctx, "user_id")
-	if errors.Is(err, internalerrors.ErrUserTypeError) {
+	owner, err := workspaces.OwnerFromCtx(ctx)
+	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 	var shortURL string
 	key := utils.GenerateShortKey()
 
-	shortURL, err = s.storage.SetURL(key, in.GetOriginalUrl(), userID)
+	shortURL, err = s.storage.SetURL(ctx, key, in.GetOriginalUrl(), owner)
 	if err != nil {
 		switch {
 		case errors.Is(err, internalerrors.ErrKeyAlreadyExists):
 			return nil, status.Error(codes.InvalidArgument, "Некорректная ключ для сокращения")
+		case errors.Is(err, internalerrors.ErrURLTooLong):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, internalerrors.ErrQuotaExceeded):
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
 		case errors.Is(err, internalerrors.ErrOriginalURLAlreadyExists):
 			return nil, status.Error(codes.AlreadyExists, "Ссылыка уже была сохранена")
 		default:
@@ -68,21 +120,25 @@ func (s *ShortenerServer) ShortenURL(ctx context.Context, in *pb.URLRequest) (*p
 // ShortenBatchURL обрабатывает пакетный запрос на сокращение ссылок.
 func (s *ShortenerServer) ShortenBatchURL(ctx context.Context, in *pb.BatchURLRequest) (*pb.BatchURLResponse, error) {
 
-	userID, err := utils.GetUserIDFromCtx(ctx, "user_id")
-	if errors.Is(err, internalerrors.ErrUserTypeError) {
+	owner, err := workspaces.OwnerFromCtx(ctx)
+	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 	saveUrls := make(map[string]entity.UserURL)
 	for _, url := range in.GetUrls() {
 		newkey := utils.GenerateShortKey()
-		saveUrls[newkey] = entity.UserURL{UserID: userID, OriginalURL: url.OriginalUrl}
+		saveUrls[newkey] = entity.UserURL{UserID: owner.UserID, OriginalURL: url.OriginalUrl, WorkspaceID: owner.WorkspaceID}
 	}
-	savedBatch, err := s.storage.SetURLBatch(saveUrls)
+	savedBatch, err := s.storage.SetURLBatch(ctx, saveUrls)
 
 	if err != nil {
 		switch {
 		case errors.Is(err, internalerrors.ErrKeyAlreadyExists):
 			return nil, status.Error(codes.AlreadyExists, "В запросе ссылки, которые уже были ранее сохранены")
+		case errors.Is(err, internalerrors.ErrURLTooLong), errors.Is(err, internalerrors.ErrBatchTooLarge):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, internalerrors.ErrQuotaExceeded):
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
 		default:
 			return nil, status.Error(codes.Internal, "Internal server error")
 		}
@@ -100,9 +156,11 @@ func (s *ShortenerServer) ShortenBatchURL(ctx context.Context, in *pb.BatchURLRe
 // GetURL обрабатывает запрос на получение полной ссылки по сокращенному id.
 func (s *ShortenerServer) GetURL(ctx context.Context, in *pb.GetURLReq) (*pb.GetURLRes, error) {
 	var response pb.GetURLRes
-	url, err := s.storage.GetURL(in.GetUrlId())
+	url, err := s.storage.GetURL(ctx, in.GetUrlId())
 	if err != nil {
 		switch {
+		case errors.Is(err, internalerrors.ErrDisabled):
+			return nil, status.Error(codes.NotFound, "Ссылка заблокирована")
 		case errors.Is(err, internalerrors.ErrDeleted):
 			return nil, status.Error(codes.NotFound, "Ссылка удалена")
 		case errors.Is(err, internalerrors.ErrNotFound):
@@ -120,12 +178,12 @@ func (s *ShortenerServer) GetUserURLs(ctx context.Context, _ *pb.GetUsersURLsReq
 	response := pb.GetUsersURLsRes{
 		Urls: []*pb.GetUsersURLsRes_UserURL{},
 	}
-	userID, err := utils.GetUserIDFromCtx(ctx, "user_id")
-	if errors.Is(err, internalerrors.ErrUserTypeError) {
+	owner, err := workspaces.OwnerFromCtx(ctx)
+	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 
-	userURLs, err := s.storage.GetUserUrls(userID)
+	userURLs, err := s.storage.GetUserUrls(ctx, owner)
 	if err != nil {
 		return nil, status.Error(codes.Internal, "Internal server error")
 	}
@@ -145,12 +203,12 @@ func (s *ShortenerServer) GetUserURLs(ctx context.Context, _ *pb.GetUsersURLsReq
 // DeleteUserURLs обрабатывает запрос на удаление ссылок пользователя.
 func (s *ShortenerServer) DeleteUserURLs(ctx context.Context, in *pb.DeleteUserURLsReq) (*pb.DeleteUserURLsRes, error) {
 	var response pb.DeleteUserURLsRes
-	userID, err := utils.GetUserIDFromCtx(ctx, "user_id")
-	if errors.Is(err, internalerrors.ErrUserTypeError) {
+	owner, err := workspaces.OwnerFromCtx(ctx)
+	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
 
-	deleteCh, err := s.storage.DeleteUserURLs(userID, s.wg)
+	deleteCh, err := s.storage.DeleteUserURLs(ctx, owner, s.wg)
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
@@ -170,37 +228,104 @@ func (s *ShortenerServer) DeleteUserURLs(ctx context.Context, in *pb.DeleteUserU
 
 // GetStats обрабатывает запрос на получение статистики хранилища.
 func (s *ShortenerServer) GetStats(ctx context.Context, _ *pb.GetStatsReq) (*pb.GetStatsRes, error) {
-	ts, err := utils.GetCIDR(s.cfg.TrustedSubnet)
+	if err := s.checkTrusted(ctx); err != nil {
+		return nil, err
+	}
+	return statsRes(ctx, s.storage)
+}
+
+// statsRes ответ со статистикой для доверенной подсети и административного сервиса
+func statsRes(ctx context.Context, st storage.Storage) (*pb.GetStatsRes, error) {
+	stats, err := st.GetStats(ctx)
 	if err != nil {
-		ts = nil
-	}
-	if ts == nil {
-		return nil, status.Error(codes.PermissionDenied, "Forbidden")
-	}
-	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		values := md.Get("X-Real-IP")
-		if len(values) > 0 {
-			ip := net.ParseIP(values[0])
-			if ip != nil && !ts.Contains(ip) {
-				return nil, status.Error(codes.PermissionDenied, "Forbidden")
-			}
-		}
+		return nil, status.Error(codes.Internal, "Internal server error")
 	}
-	var response pb.GetStatsRes
-	urls, users, err := s.storage.GetStats()
+	response := pb.GetStatsRes{
+		Urls:           int32(stats.Active),
+		Users:          int32(stats.Users),
+		Total:          int32(stats.Total),
+		Deleted:        int32(stats.Deleted),
+		CreatedLastDay: int32(stats.CreatedLastDay),
+		TopUsers:       make([]*pb.GetStatsRes_UserStat, 0, len(stats.TopUsers)),
+	}
+	for _, u := range stats.TopUsers {
+		response.TopUsers = append(response.TopUsers, &pb.GetStatsRes_UserStat{UserId: u.UserID, Urls: int32(u.URLs)})
+	}
+	return &response, nil
+}
+
+// GetStatsTimeSeries обрабатывает запрос временного ряда статистики.
+func (s *ShortenerServer) GetStatsTimeSeries(ctx context.Context, in *pb.GetStatsTimeSeriesReq) (*pb.GetStatsTimeSeriesRes, error) {
+	if err := s.checkTrusted(ctx); err != nil {
+		return nil, err
+	}
+	to := time.Now()
+	if in.GetTo() != nil {
+		to = in.GetTo().AsTime()
+	}
+	from := to.Add(-entity.IntervalDay)
+	if in.GetFrom() != nil {
+		from = in.GetFrom().AsTime()
+	}
+	interval := entity.IntervalHour
+	if in.GetInterval() == pb.GetStatsTimeSeriesReq_DAY {
+		interval = entity.IntervalDay
+	}
+	buckets, err := s.storage.GetTimeSeries(ctx, from, to, interval)
 	if err != nil {
-		response.Urls = int32(urls)
-		response.Users = int32(users)
-		return &response, nil
-	} else {
-		return nil, status.Error(codes.Internal, "forbidden")
+		if errors.Is(err, entity.ErrBadTimeRange) {
+			return nil, status.Error(codes.InvalidArgument, "Некорректный период")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
 	}
+	response := pb.GetStatsTimeSeriesRes{Buckets: make([]*pb.GetStatsTimeSeriesRes_Bucket, 0, len(buckets))}
+	for _, b := range buckets {
+		response.Buckets = append(response.Buckets, &pb.GetStatsTimeSeriesRes_Bucket{
+			Start:   timestamppb.New(b.Start),
+			Created: int32(b.Created),
+			Deleted: int32(b.Deleted),
+			Clicked: int32(b.Clicked),
+		})
+	}
+	return &response, nil
+}
+
+// checkTrusted проверяет адрес клиента по доверенным подсетям.
+//
+// Адрес берется из соединения, метаданные учитываются только от доверенных прокси.
+func (s *ShortenerServer) checkTrusted(ctx context.Context) error {
+	if !s.trusted.AllowContext(ctx) {
+		return status.Error(codes.PermissionDenied, "Forbidden")
+	}
+	return nil
+}
+
+// GetVersion возвращает сведения о сборке и конфигурации сервера.
+func (s *ShortenerServer) GetVersion(ctx context.Context, _ *pb.GetVersionReq) (*pb.GetVersionRes, error) {
+	if err := s.checkTrusted(ctx); err != nil {
+		return nil, err
+	}
+	return &pb.GetVersionRes{
+		Version:     s.info.Version,
+		BuildDate:   s.info.BuildDate,
+		Commit:      s.info.Commit,
+		GoVersion:   s.info.GoVersion,
+		VcsRevision: s.info.VCSRevision,
+		VcsTime:     s.info.VCSTime,
+		VcsModified: s.info.VCSModified,
+		Storage:     s.info.Storage,
+		Features:    s.info.Features,
+	}, nil
 }
 
 // Ping обрабатывает запрос на проверку соединения с хранилищем данных.
 func (s *ShortenerServer) Ping(ctx context.Context, _ *pb.PingRequest) (*pb.PingResponse, error) {
 	var response pb.PingResponse
-	if err := s.storage.(storage.Pinger).Ping(); err != nil {
+	pinger, ok := s.storage.(storage.Pinger)
+	if !ok {
+		return &response, nil
+	}
+	if err := pinger.Ping(ctx); err != nil {
 		return nil, status.Error(codes.Internal, "Failed to ping database")
 	}
 	return &response, nil
@@ -2,24 +2,392 @@ package grpcsrv
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net"
+	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/SversusN/shortener/config"
+	"github.com/SversusN/shortener/internal/accounts"
+	"github.com/SversusN/shortener/internal/admin"
+	"github.com/SversusN/shortener/internal/apikeys"
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/buildinfo"
+	"github.com/SversusN/shortener/internal/clientip"
+	pb "github.com/SversusN/shortener/internal/grpcsrv/proto"
+	"github.com/SversusN/shortener/internal/health"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/metrics"
+	"github.com/SversusN/shortener/internal/ratelimit"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
 	"github.com/SversusN/shortener/internal/storage/primitivestorage"
+	"github.com/SversusN/shortener/internal/storage/storage"
+	"github.com/SversusN/shortener/internal/tlsconf"
+	"github.com/SversusN/shortener/internal/tlsconf/tlstest"
 )
 
+// newTokens менеджер токенов со случайным ключом
+func newTokens(t *testing.T) *auth.Manager {
+	tokens, err := auth.New(auth.Options{TTL: time.Hour})
+	require.NoError(t, err)
+	return tokens
+}
+
+// newOptions зависимости сервера для тестов, остальные возможности выключены
+func newOptions(tokens *auth.Manager) Options {
+	return Options{
+		Metrics:   metrics.New(),
+		Logger:    &logger.ServerLogger{Logger: zap.NewNop()},
+		Health:    health.New(),
+		BuildInfo: buildinfo.New("test", "N/A", "N/A"),
+		Tokens:    tokens,
+	}
+}
+
 func TestCreateServer(t *testing.T) {
 	storage := primitivestorage.NewStorage(nil, errors.New("filename is empty, no store tempdb"))
 	c := context.Background()
 	cfg := config.Config{
-		TrustedSubnet: "",
-		GRPCAddress:   "3020",
+		GRPCAddress: "3020",
 	}
 	wg := &sync.WaitGroup{}
-	server := NewGRPCServer(&c, storage, &cfg, wg)
+	server := NewGRPCServer(&c, storage, &cfg, wg, newOptions(newTokens(t)))
 	assert.IsType(t, (*grpc.Server)(nil), server)
 }
+
+func TestHealthServer(t *testing.T) {
+	hc := health.New()
+	hs := NewHealthServer(hc)
+	ctx := context.Background()
+
+	res, err := hs.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.GetStatus())
+
+	_, err = hs.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown.Service"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	hc.SetShuttingDown()
+	res, err = hs.Check(ctx, &healthpb.HealthCheckRequest{Service: "shortener.Shortener"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, res.GetStatus())
+}
+
+// uuidOwners хранилище, как в Postgres принимающее только владельцев с ИД uuid (user_id = $1::uuid)
+type uuidOwners struct {
+	storage.Storage
+}
+
+func (s uuidOwners) SetURL(ctx context.Context, id string, targetURL string, owner entity.Owner) (string, error) {
+	if _, err := uuid.Parse(owner.UserID); err != nil {
+		return "", err
+	}
+	return s.Storage.SetURL(ctx, id, targetURL, owner)
+}
+
+func (s uuidOwners) GetUserUrls(ctx context.Context, owner entity.Owner) (any, error) {
+	if _, err := uuid.Parse(owner.UserID); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetUserUrls(ctx, owner)
+}
+
+// startTLSServer запускает сервер с TLS и mTLS по CA клиентов clientCA
+func startTLSServer(t *testing.T, serverCA, clientCA *tlstest.CA) (string, *primitivestorage.MapStorage) {
+	pair := serverCA.Server(t)
+	tp, err := tlsconf.New(tlsconf.Options{CertFile: pair.CertFile, KeyFile: pair.KeyFile})
+	require.NoError(t, err)
+	grpcTLS, err := tp.GRPCConfig(clientCA.WriteCert(t))
+	require.NoError(t, err)
+
+	c := context.Background()
+	ms := primitivestorage.NewStorage(nil, errors.New("filename is empty, no store tempdb"))
+	tokens := newTokens(t)
+	accts, err := accounts.New(ms, tokens, bcrypt.MinCost)
+	require.NoError(t, err)
+	tokens.SetRoleSource(accts)
+	o := newOptions(tokens)
+	o.Accounts = accts
+	server := NewGRPCServer(&c, uuidOwners{ms}, &config.Config{}, &sync.WaitGroup{}, o, grpc.Creds(credentials.NewTLS(grpcTLS)))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String(), ms
+}
+
+func TestMutualTLS(t *testing.T) {
+	serverCA := tlstest.NewCA(t, "server-ca")
+	clientCA := tlstest.NewCA(t, "client-ca")
+	addr, ms := startTLSServer(t, serverCA, clientCA)
+
+	dial := func(certs ...tls.Certificate) pb.ShortenerClient {
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			RootCAs:      serverCA.Pool,
+			Certificates: certs,
+		})))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		return pb.NewShortenerClient(conn)
+	}
+	ctx := context.Background()
+
+	//Пользователь берется из сертификата, метаданные user_id не нужны
+	client := dial(clientCA.Client(t, "service-a").TLS)
+	_, err := client.ShortenURL(ctx, &pb.URLRequest{OriginalUrl: "https://example.com/mtls"})
+	require.NoError(t, err)
+
+	//Новый сертификат с тем же именем - тот же пользователь
+	urls, err := dial(clientCA.Client(t, "service-a").TLS).GetUserURLs(ctx, &pb.GetUsersURLsReq{})
+	require.NoError(t, err)
+	require.Len(t, urls.GetUrls(), 1)
+	assert.Equal(t, "https://example.com/mtls", urls.GetUrls()[0].GetOriginalUrl())
+
+	//Заблокированный пользователь сертификата получает отказ
+	cert := clientCA.Client(t, "service-a").TLS
+	require.NoError(t, ms.SetUserAccess(ctx, entity.UserAccess{UserID: tlsconf.ClientUserID(cert.Leaf), Disabled: true}))
+	_, err = dial(cert).GetUserURLs(ctx, &pb.GetUsersURLsReq{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	//Без сертификата и с сертификатом чужого CA соединение не устанавливается
+	_, err = dial().Ping(ctx, &pb.PingRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	otherCA := tlstest.NewCA(t, "other-ca")
+	_, err = dial(otherCA.Client(t, "service-b").TLS).Ping(ctx, &pb.PingRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestRateLimitGRPC(t *testing.T) {
+	c := context.Background()
+	storage := primitivestorage.NewStorage(nil, errors.New("filename is empty, no store tempdb"))
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), map[string]int{ratelimit.ActionShorten: 1})
+	o := newOptions(newTokens(t))
+	o.RateLimit = limiter
+	server := NewGRPCServer(&c, storage, &config.Config{FlagBaseAddress: "http://localhost:8080"}, &sync.WaitGroup{}, o)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewShortenerClient(conn)
+
+	//Анонимные вызовы без токена считаются по адресу, переходы не ограничены
+	res, err := client.ShortenURL(c, &pb.URLRequest{OriginalUrl: "https://example.com/1"})
+	require.NoError(t, err)
+	var header metadata.MD
+	_, err = client.ShortenURL(c, &pb.URLRequest{OriginalUrl: "https://example.com/2"}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"60"}, header.Get("retry-after"))
+	_, err = client.GetURL(c, &pb.GetURLReq{UrlId: strings.TrimPrefix(res.GetShortUrl(), "http://localhost:8080/")})
+	assert.NoError(t, err)
+}
+
+func TestClientIdentity(t *testing.T) {
+	ca := tlstest.NewCA(t, "client-ca")
+	assert.Equal(t, "service-a", tlsconf.ClientIdentity(ca.Client(t, "service-a", "a.internal").TLS.Leaf))
+	assert.Equal(t, "b.internal", tlsconf.ClientIdentity(ca.Client(t, "", "b.internal").TLS.Leaf))
+
+	//ИД пользователя - uuid, постоянный для имени клиента
+	ID := tlsconf.ClientUserID(ca.Client(t, "service-a").TLS.Leaf)
+	_, err := uuid.Parse(ID)
+	require.NoError(t, err)
+	assert.Equal(t, ID, tlsconf.ClientUserID(ca.Client(t, "service-a", "other.internal").TLS.Leaf))
+	assert.NotEqual(t, ID, tlsconf.ClientUserID(ca.Client(t, "service-b").TLS.Leaf))
+}
+
+func TestTrustedGate(t *testing.T) {
+	c := context.Background()
+	storage := primitivestorage.NewStorage(nil, errors.New("filename is empty, no store tempdb"))
+	version := func(trusted *clientip.Gate) error {
+		o := newOptions(newTokens(t))
+		o.Trusted = trusted
+		//Подсети берутся из общего с HTTP фильтра, а не из конфигурации
+		server := NewGRPCServer(&c, storage, &config.Config{TrustedSubnet: config.List{"10.0.0.0/8"}}, &sync.WaitGroup{}, o)
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go func() { _ = server.Serve(lis) }()
+		defer server.Stop()
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = pb.NewShortenerClient(conn).GetVersion(c, &pb.GetVersionReq{})
+		return err
+	}
+
+	gate, err := clientip.NewGate([]string{"127.0.0.0/8"}, nil)
+	require.NoError(t, err)
+	assert.NoError(t, version(gate))
+	assert.Equal(t, codes.PermissionDenied, status.Code(version(nil)))
+}
+
+func TestJWTAuth(t *testing.T) {
+	c := context.Background()
+	storage := primitivestorage.NewStorage(nil, errors.New("filename is empty, no store tempdb"))
+	server := NewGRPCServer(&c, storage, &config.Config{FlagBaseAddress: "http://localhost:8080"}, &sync.WaitGroup{}, newOptions(newTokens(t)))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewShortenerClient(conn)
+
+	//Новому пользователю токен выдается в заголовке ответа
+	var header metadata.MD
+	_, err = client.ShortenURL(c, &pb.URLRequest{OriginalUrl: "https://example.com/jwt"}, grpc.Header(&header))
+	require.NoError(t, err)
+	values := header.Get("authorization")
+	require.Len(t, values, 1)
+
+	//С этим токеном видны ссылки того же пользователя
+	authCtx := metadata.AppendToOutgoingContext(c, "authorization", values[0])
+	res, err := client.GetUserURLs(authCtx, &pb.GetUsersURLsReq{})
+	require.NoError(t, err)
+	require.Len(t, res.GetUrls(), 1)
+	assert.Equal(t, "https://example.com/jwt", res.GetUrls()[0].GetOriginalUrl())
+
+	//Метаданные user_id больше не задают пользователя, поддельный токен отклоняется
+	spoofCtx := metadata.AppendToOutgoingContext(c, "user_id", "victim", "authorization", "Bearer forged")
+	_, err = client.GetUserURLs(spoofCtx, &pb.GetUsersURLsReq{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAccountsGRPC(t *testing.T) {
+	c := context.Background()
+	storage := primitivestorage.NewStorage(nil, errors.New("filename is empty, no store tempdb"))
+	tokens := newTokens(t)
+	accts, err := accounts.New(storage, tokens, bcrypt.MinCost)
+	require.NoError(t, err)
+	keys := apikeys.New(storage, storage)
+	o := newOptions(tokens)
+	o.Accounts, o.APIKeys = accts, keys
+	server := NewGRPCServer(&c, storage, &config.Config{FlagBaseAddress: "http://localhost:8080"}, &sync.WaitGroup{}, o)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewShortenerClient(conn)
+
+	//Регистрация не выдает анонимный токен, токен приходит в ответе
+	var header metadata.MD
+	reg, err := client.Register(c, &pb.CredentialsReq{Login: "alice", Password: "correct horse"}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Empty(t, header.Get("authorization"))
+	_, err = client.Register(c, &pb.CredentialsReq{Login: "alice", Password: "correct horse"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	authCtx := metadata.AppendToOutgoingContext(c, "authorization", "Bearer "+reg.GetToken())
+	_, err = client.ShortenURL(authCtx, &pb.URLRequest{OriginalUrl: "https://example.com/account"})
+	require.NoError(t, err)
+
+	_, err = client.Login(c, &pb.CredentialsReq{Login: "alice", Password: "wrong password"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	login, err := client.Login(c, &pb.CredentialsReq{Login: "alice", Password: "correct horse"})
+	require.NoError(t, err)
+	assert.Equal(t, reg.GetUserId(), login.GetUserId())
+	res, err := client.GetUserURLs(metadata.AppendToOutgoingContext(c, "authorization", "Bearer "+login.GetToken()), &pb.GetUsersURLsReq{})
+	require.NoError(t, err)
+	require.Len(t, res.GetUrls(), 1)
+
+	//Ссылки анонимного токена переносятся при входе с claim_anonymous
+	header = metadata.MD{}
+	_, err = client.ShortenURL(c, &pb.URLRequest{OriginalUrl: "https://example.com/anonymous"}, grpc.Header(&header))
+	require.NoError(t, err)
+	anonCtx := metadata.AppendToOutgoingContext(c, "authorization", header.Get("authorization")[0])
+	login, err = client.Login(anonCtx, &pb.CredentialsReq{Login: "alice", Password: "correct horse", ClaimAnonymous: true})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, login.GetClaimed())
+	res, err = client.GetUserURLs(metadata.AppendToOutgoingContext(c, "authorization", "Bearer "+login.GetToken()), &pb.GetUsersURLsReq{})
+	require.NoError(t, err)
+	assert.Len(t, res.GetUrls(), 2)
+
+	//Ключ API действует от имени пользователя только в своих областях доступа
+	_, secret, err := keys.Create(c, login.GetUserId(), "reader", []string{auth.ScopeRead})
+	require.NoError(t, err)
+	keyCtx := metadata.AppendToOutgoingContext(c, apikeys.MetaKey, secret)
+	res, err = client.GetUserURLs(keyCtx, &pb.GetUsersURLsReq{})
+	require.NoError(t, err)
+	assert.Len(t, res.GetUrls(), 2)
+	_, err = client.ShortenURL(keyCtx, &pb.URLRequest{OriginalUrl: "https://example.com/key"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.GetUserURLs(metadata.AppendToOutgoingContext(c, apikeys.MetaKey, secret+"x"), &pb.GetUsersURLsReq{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAdminGRPC(t *testing.T) {
+	c := context.Background()
+	storage := primitivestorage.NewStorage(nil, errors.New("filename is empty, no store tempdb"))
+	tokens := newTokens(t)
+	keys := apikeys.New(storage, storage)
+	o := newOptions(tokens)
+	o.APIKeys, o.Admin = keys, admin.New(storage)
+	server := NewGRPCServer(&c, storage, &config.Config{FlagBaseAddress: "http://localhost:8080"}, &sync.WaitGroup{}, o)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client, adm := pb.NewShortenerClient(conn), pb.NewAdminClient(conn)
+
+	withRole := func(userID, role string) context.Context {
+		token, err := tokens.BuildToken(userID, role)
+		require.NoError(t, err)
+		return metadata.AppendToOutgoingContext(c, "authorization", "Bearer "+token)
+	}
+	adminCtx := withRole(uuid.NewString(), auth.RoleAdmin)
+	auditorCtx := withRole(uuid.NewString(), auth.RoleAuditor)
+	ownerID := uuid.NewString()
+	userCtx := withRole(ownerID, auth.RoleUser)
+	res, err := client.ShortenURL(userCtx, &pb.URLRequest{OriginalUrl: "https://example.com/admin"})
+	require.NoError(t, err)
+
+	//Чтение доступно аудитору, изменения только администратору
+	list, err := adm.ListURLs(auditorCtx, &pb.ListURLsReq{UserId: ownerID})
+	require.NoError(t, err)
+	require.Len(t, list.GetUrls(), 1)
+	_, err = adm.SetURLDisabled(auditorCtx, &pb.SetURLDisabledReq{ShortUrl: res.GetShortUrl(), Disabled: true})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = adm.ListURLs(userCtx, &pb.ListURLsReq{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, secret, err := keys.Create(c, ownerID, "reader", []string{auth.ScopeRead})
+	require.NoError(t, err)
+	_, err = adm.GetStats(metadata.AppendToOutgoingContext(c, apikeys.MetaKey, secret), &pb.GetStatsReq{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = adm.SetURLDisabled(adminCtx, &pb.SetURLDisabledReq{ShortUrl: res.GetShortUrl(), Disabled: true})
+	require.NoError(t, err)
+	_, err = client.GetURL(c, &pb.GetURLReq{UrlId: res.GetShortUrl()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	//Заблокированный пользователь теряет доступ по ключу API
+	_, err = adm.SetUserDisabled(adminCtx, &pb.SetUserDisabledReq{UserId: ownerID, Disabled: true})
+	require.NoError(t, err)
+	_, err = client.GetUserURLs(metadata.AppendToOutgoingContext(c, apikeys.MetaKey, secret), &pb.GetUsersURLsReq{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = adm.SetUserRole(adminCtx, &pb.SetUserRoleReq{UserId: ownerID, Role: "root"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,75 @@
+package grpcsrv
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/SversusN/shortener/internal/grpcsrv/proto"
+	"github.com/SversusN/shortener/internal/health"
+)
+
+// healthWatchInterval период проверки готовности для подписчиков Watch
+const healthWatchInterval = 5 * time.Second
+
+// HealthServer реализация grpc.health.v1 поверх проверок готовности
+//
+// Пустое имя сервиса означает сервер целиком и совпадает по состоянию с сервисом Shortener.
+type HealthServer struct {
+	healthpb.UnimplementedHealthServer
+	checker *health.Checker
+}
+
+// NewHealthServer создает сервис проверки состояния
+func NewHealthServer(checker *health.Checker) *HealthServer {
+	return &HealthServer{checker: checker}
+}
+
+// servingStatus состояние сервиса service
+func (h *HealthServer) servingStatus(ctx context.Context, service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	if service != "" && service != pb.Shortener_ServiceDesc.ServiceName {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, status.Error(codes.NotFound, "unknown service")
+	}
+	if h.checker.Ready(ctx).Status != health.StatusUp {
+		return healthpb.HealthCheckResponse_NOT_SERVING, nil
+	}
+	return healthpb.HealthCheckResponse_SERVING, nil
+}
+
+// Check однократная проверка состояния
+func (h *HealthServer) Check(ctx context.Context, in *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, err := h.servingStatus(ctx, in.GetService())
+	if err != nil {
+		return nil, err
+	}
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+// Watch отправляет текущее состояние и затем каждое его изменение
+func (h *HealthServer) Watch(in *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(healthWatchInterval)
+	defer ticker.Stop()
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		st, err := h.servingStatus(ctx, in.GetService())
+		if err != nil {
+			//По протоколу неизвестный сервис не завершает Watch
+			st = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+		if st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -2,39 +2,186 @@ package interceptors
 
 import (
 	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+
+	"github.com/SversusN/shortener/internal/apikeys"
+	"github.com/SversusN/shortener/internal/auth"
+	pb "github.com/SversusN/shortener/internal/grpcsrv/proto"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/tlsconf"
 )
 
-// Ключ с id пользователя.
-const UserIDMetaKey userID = "user_id"
+// AuthorizationMetaKey ключ метаданных с токеном "Bearer <jwt>" в запросе и ответе
+const AuthorizationMetaKey = "authorization"
 
-type userID string
+// publicServicePrefix сервис проверки состояния доступен без пользователя
+const publicServicePrefix = "/grpc.health.v1.Health/"
+
+// credentialMethods методы, выдающие токен сами: анонимный токен им не нужен
+var credentialMethods = map[string]bool{
+	pb.Shortener_Register_FullMethodName: true,
+	pb.Shortener_Login_FullMethodName:    true,
+}
+
+// methodScopes области доступа, которые нужны ключу API для методов с ссылками пользователя
+var methodScopes = map[string]string{
+	pb.Shortener_ShortenURL_FullMethodName:      auth.ScopeShorten,
+	pb.Shortener_ShortenBatchURL_FullMethodName: auth.ScopeShorten,
+	pb.Shortener_GetUserURLs_FullMethodName:     auth.ScopeRead,
+	pb.Shortener_DeleteUserURLs_FullMethodName:  auth.ScopeDelete,
+}
+
+// methodPermissions права, которые нужны роли пользователя для административных методов
+var methodPermissions = map[string]string{
+	pb.Admin_GetStats_FullMethodName:        auth.PermAdminRead,
+	pb.Admin_ListURLs_FullMethodName:        auth.PermAdminRead,
+	pb.Admin_ListUsers_FullMethodName:       auth.PermAdminRead,
+	pb.Admin_SetURLDisabled_FullMethodName:  auth.PermAdminWrite,
+	pb.Admin_DeleteURL_FullMethodName:       auth.PermAdminWrite,
+	pb.Admin_SetUserDisabled_FullMethodName: auth.PermAdminWrite,
+	pb.Admin_SetUserRole_FullMethodName:     auth.PermAdminWrite,
+	pb.Admin_DeleteUser_FullMethodName:      auth.PermAdminWrite,
+}
 
 // AuthInterceptor описывает структуру интерцептора аутентификации
 type AuthInterceptor struct {
+	tokens *auth.Manager
+	keys   *apikeys.Service
 }
 
 // NewAuthInterceptor создает аутентификатор
-func NewAuthInterceptor(ctx context.Context) *AuthInterceptor {
-	return &AuthInterceptor{}
+//
+// keys может быть nil, тогда ключи API не принимаются.
+func NewAuthInterceptor(ctx context.Context, tokens *auth.Manager, keys *apikeys.Service) *AuthInterceptor {
+	return &AuthInterceptor{tokens: tokens, keys: keys}
 }
 
 // AuthenticateUser идентифицирует пользователя в запросе.
-func (i *AuthInterceptor) AuthenticateUser(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+//
+// Пользователь берется из сертификата клиента mTLS или из JWT в метаданных authorization,
+// как в куке HTTP. Текущая роль и блокировка проверяются для обоих.
+// Новому пользователю и при продлении токен выдается в заголовке ответа authorization.
+// Ключ API из метаданных x-api-key или authorization ограничивает вызов своими областями доступа,
+// административные методы доступны по роли из токена, как в HTTP.
+func (i *AuthInterceptor) AuthenticateUser(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if strings.HasPrefix(info.FullMethod, publicServicePrefix) || credentialMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+	ctx, err := i.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if scope, ok := methodScopes[info.FullMethod]; ok && !auth.HasScope(ctx, scope) {
+		return nil, status.Error(codes.PermissionDenied, "api key has no scope "+scope)
+	}
+	if perm, ok := methodPermissions[info.FullMethod]; ok && !auth.Can(ctx, perm) {
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+	return handler(ctx, req)
+}
 
-	var ID string
+// authenticate пользователь вызова: сертификат клиента, ключ API, токен или новый анонимный
+func (i *AuthInterceptor) authenticate(ctx context.Context) (context.Context, error) {
+	//Проверенный сертификат клиента надежнее токена
+	if ID, ok := peerIdentity(ctx); ok {
+		role, err := i.tokens.UserRole(ctx, ID)
+		if errors.Is(err, auth.ErrUserDisabled) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		if err != nil {
+			logger.FromCtx(ctx).Error("user access check failed", zap.Error(err))
+			return nil, status.Error(codes.Internal, "Internal server error")
+		}
+		return auth.WithClaims(ctx, auth.Claims{UserID: ID, Role: role, Account: true}), nil
+	}
+	if raw := apiKeyFromMetadata(ctx); raw != "" && i.keys != nil {
+		key, err := i.keys.Authenticate(ctx, raw)
+		if errors.Is(err, apikeys.ErrInvalidKey) {
+			return nil, status.Error(codes.Unauthenticated, "invalid api key")
+		}
+		if err != nil {
+			logger.FromCtx(ctx).Error("api key check failed", zap.Error(err))
+			return nil, status.Error(codes.Internal, "Internal server error")
+		}
+		return auth.WithAPIKeyID(auth.WithScopes(auth.WithUserID(ctx, key.UserID), key.Scopes), key.ID), nil
+	}
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		values := md.Get("user_id")
-		if len(values) > 0 {
-			idString := values[0]
-			ID = idString
-		} else {
-			return nil, status.Error(codes.Unauthenticated, "wrong user id format")
+		if values := md.Get(AuthorizationMetaKey); len(values) > 0 {
+			token := auth.BearerToken(values[0])
+			claims, err := i.tokens.CurrentClaims(ctx, token)
+			switch {
+			case err == nil:
+				return auth.WithClaims(ctx, claims), nil
+			case errors.Is(err, auth.ErrUserDisabled):
+				return nil, status.Error(codes.PermissionDenied, err.Error())
+			case !errors.Is(err, auth.ErrInvalidToken):
+				logger.FromCtx(ctx).Error("user access check failed", zap.Error(err))
+				return nil, status.Error(codes.Internal, "Internal server error")
+			}
+			//Истекший токен в пределах окна продления заменяется новым
+			claims, token, err = i.tokens.Refresh(ctx, token)
+			if errors.Is(err, auth.ErrUserDisabled) {
+				return nil, status.Error(codes.PermissionDenied, err.Error())
+			}
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, "invalid token")
+			}
+			if err := grpc.SetHeader(ctx, metadata.Pairs(AuthorizationMetaKey, "Bearer "+token)); err != nil {
+				return nil, status.Error(codes.Internal, "Internal server error")
+			}
+			return auth.WithClaims(ctx, claims), nil
 		}
-		ctx = context.WithValue(ctx, UserIDMetaKey, ID)
 	}
-	return handler(ctx, req)
+	//ИД пользователя если обращение происходит первый раз (uuid)
+	ID := uuid.NewString()
+	token, err := i.tokens.BuildNewToken(ID)
+	if err != nil {
+		logger.FromCtx(ctx).Error("err while building new token", zap.Error(err))
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(AuthorizationMetaKey, "Bearer "+token)); err != nil {
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+	return auth.WithUserID(ctx, ID), nil
+}
+
+// apiKeyFromMetadata ключ API из метаданных x-api-key или authorization: Bearer sk_...
+func apiKeyFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(apikeys.MetaKey); len(values) > 0 {
+		return values[0]
+	}
+	if values := md.Get(AuthorizationMetaKey); len(values) > 0 {
+		if token := auth.BearerToken(values[0]); apikeys.IsAPIKey(token) {
+			return token
+		}
+	}
+	return ""
+}
+
+// peerIdentity пользователь из проверенного сертификата клиента mTLS
+func peerIdentity(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.PeerCertificates) == 0 {
+		return "", false
+	}
+	ID := tlsconf.ClientUserID(tlsInfo.State.PeerCertificates[0])
+	return ID, ID != ""
 }
@@ -2,27 +2,28 @@ package interceptors
 
 import (
 	"context"
-	"go.uber.org/zap"
 	"time"
 
-	"github.com/SversusN/shortener/internal/logger"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/status"
+
+	"github.com/SversusN/shortener/internal/logger"
 )
 
 // LoggerInterceptor логирует входящие запросы.
+//
+// Логер берется из контекста запроса, в нем уже есть идентификатор запроса.
 func LoggerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	lg := logger.CreateLogger(zap.NewAtomicLevelAt(zap.InfoLevel))
-	sl := *lg.Logger.Sugar()
 	start := time.Now()
 	resp, err := handler(ctx, req)
 	duration := time.Since(start)
 	status, _ := status.FromError(err)
-	sl.Infoln(
+	logger.FromCtx(ctx).Sugar().Infow(
 		"gRPC request",
 		"method", info.FullMethod,
 		"duration", duration,
 		"code", status.Code(),
+		"user_id", logger.UserIDFromCtx(ctx),
 	)
 	return resp, err
 }
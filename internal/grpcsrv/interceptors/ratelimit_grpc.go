@@ -0,0 +1,54 @@
+package interceptors
+
+import (
+	"context"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/SversusN/shortener/internal/clientip"
+	pb "github.com/SversusN/shortener/internal/grpcsrv/proto"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/ratelimit"
+)
+
+// RetryAfterMetaKey ключ метаданных ответа с секундами до следующей попытки
+const RetryAfterMetaKey = "retry-after"
+
+// methodActions действия с лимитами для методов сервиса
+var methodActions = map[string]string{
+	pb.Shortener_ShortenURL_FullMethodName:      ratelimit.ActionShorten,
+	pb.Shortener_ShortenBatchURL_FullMethodName: ratelimit.ActionShorten,
+	pb.Shortener_GetURL_FullMethodName:          ratelimit.ActionRedirect,
+	pb.Shortener_DeleteUserURLs_FullMethodName:  ratelimit.ActionDelete,
+}
+
+// NewRateLimitInterceptor создает интерцептор ограничения частоты вызовов.
+//
+// Подключается после аутентификации, как RateLimit в HTTP. При превышении возвращает
+// ResourceExhausted и секунды ожидания в заголовке ответа retry-after.
+func NewRateLimitInterceptor(l *ratelimit.Limiter, ips *clientip.Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		action, limited := methodActions[info.FullMethod]
+		if !limited {
+			return handler(ctx, req)
+		}
+		ok, wait, err := l.Allow(ctx, action, ratelimit.ClientKey(ctx, ips.FromContext(ctx)))
+		if err != nil {
+			logger.FromCtx(ctx).Error("rate limit check failed", zap.Error(err))
+			return handler(ctx, req)
+		}
+		if !ok {
+			retryAfter := strconv.Itoa(ratelimit.RetryAfterSeconds(wait))
+			if err := grpc.SetHeader(ctx, metadata.Pairs(RetryAfterMetaKey, retryAfter)); err != nil {
+				logger.FromCtx(ctx).Warn("failed to set retry-after header", zap.Error(err))
+			}
+			return nil, status.Error(codes.ResourceExhausted, "too many requests, retry after "+retryAfter+"s")
+		}
+		return handler(ctx, req)
+	}
+}
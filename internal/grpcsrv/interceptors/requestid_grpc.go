@@ -0,0 +1,30 @@
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/SversusN/shortener/internal/logger"
+)
+
+// NewRequestIDInterceptor создает интерцептор идентификатора запроса.
+//
+// Идентификатор берется из метаданных x-request-id или генерируется,
+// возвращается в заголовке ответа и попадает в логер запроса.
+func NewRequestIDInterceptor(l *logger.ServerLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var requestID string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(logger.RequestIDMetaKey); len(values) > 0 {
+				requestID = values[0]
+			}
+		}
+		requestID = logger.NormalizeRequestID(requestID)
+		if err := grpc.SetHeader(ctx, metadata.Pairs(logger.RequestIDMetaKey, requestID)); err != nil {
+			logger.FromCtx(ctx).Sugar().Warnw("failed to set request id header", "error", err)
+		}
+		return handler(logger.WithRequestID(ctx, l.Logger, requestID), req)
+	}
+}
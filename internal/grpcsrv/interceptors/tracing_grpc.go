@@ -0,0 +1,17 @@
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"github.com/SversusN/shortener/internal/tracing"
+)
+
+// TracingInterceptor создает спан вызова, родитель берется из traceparent в метаданных.
+func TracingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, span := tracing.StartGRPCSpan(ctx, info.FullMethod)
+	resp, err := handler(ctx, req)
+	tracing.EndGRPCSpan(span, err)
+	return resp, err
+}
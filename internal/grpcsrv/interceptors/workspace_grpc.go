@@ -0,0 +1,59 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/SversusN/shortener/internal/auth"
+	pb "github.com/SversusN/shortener/internal/grpcsrv/proto"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/workspaces"
+)
+
+// workspaceWriteMethods методы, меняющие ссылки пространства: недоступны роли viewer
+var workspaceWriteMethods = map[string]bool{
+	pb.Shortener_ShortenURL_FullMethodName:      true,
+	pb.Shortener_ShortenBatchURL_FullMethodName: true,
+	pb.Shortener_DeleteUserURLs_FullMethodName:  true,
+}
+
+// NewWorkspaceInterceptor создает интерцептор выбора пространства.
+//
+// Пространство берется из метаданных x-workspace-id, как заголовок X-Workspace-ID в HTTP.
+// Подключается после аутентификации: участие проверяется для пользователя вызова.
+func NewWorkspaceInterceptor(ws *workspaces.Service) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var workspaceID string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(workspaces.MetaKey); len(values) > 0 {
+				workspaceID = values[0]
+			}
+		}
+		if workspaceID == "" {
+			return handler(ctx, req)
+		}
+		userID, err := auth.UserIDFromCtx(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		member, err := ws.Resolve(ctx, userID, workspaceID)
+		switch {
+		case errors.Is(err, workspaces.ErrNotMember):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		case err != nil:
+			logger.FromCtx(ctx).Error("workspace lookup failed", zap.Error(err))
+			return nil, status.Error(codes.Internal, "Internal server error")
+		}
+		ctx = workspaces.WithMember(ctx, member)
+		if workspaceWriteMethods[info.FullMethod] && !workspaces.CanWrite(ctx) {
+			return nil, status.Error(codes.PermissionDenied, "workspace viewers cannot change links")
+		}
+		return handler(ctx, req)
+	}
+}
@@ -19,13 +19,17 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Shortener_ShortenURL_FullMethodName      = "/shortener.Shortener/ShortenURL"
-	Shortener_ShortenBatchURL_FullMethodName = "/shortener.Shortener/ShortenBatchURL"
-	Shortener_Ping_FullMethodName            = "/shortener.Shortener/Ping"
-	Shortener_GetURL_FullMethodName          = "/shortener.Shortener/GetURL"
-	Shortener_GetUserURLs_FullMethodName     = "/shortener.Shortener/GetUserURLs"
-	Shortener_DeleteUserURLs_FullMethodName  = "/shortener.Shortener/DeleteUserURLs"
-	Shortener_GetStats_FullMethodName        = "/shortener.Shortener/GetStats"
+	Shortener_ShortenURL_FullMethodName         = "/shortener.Shortener/ShortenURL"
+	Shortener_ShortenBatchURL_FullMethodName    = "/shortener.Shortener/ShortenBatchURL"
+	Shortener_Ping_FullMethodName               = "/shortener.Shortener/Ping"
+	Shortener_GetURL_FullMethodName             = "/shortener.Shortener/GetURL"
+	Shortener_GetUserURLs_FullMethodName        = "/shortener.Shortener/GetUserURLs"
+	Shortener_DeleteUserURLs_FullMethodName     = "/shortener.Shortener/DeleteUserURLs"
+	Shortener_GetStats_FullMethodName           = "/shortener.Shortener/GetStats"
+	Shortener_GetStatsTimeSeries_FullMethodName = "/shortener.Shortener/GetStatsTimeSeries"
+	Shortener_GetVersion_FullMethodName         = "/shortener.Shortener/GetVersion"
+	Shortener_Register_FullMethodName           = "/shortener.Shortener/Register"
+	Shortener_Login_FullMethodName              = "/shortener.Shortener/Login"
 )
 
 // ShortenerClient is the client API for Shortener service.
@@ -39,6 +43,10 @@ type ShortenerClient interface {
 	GetUserURLs(ctx context.Context, in *GetUsersURLsReq, opts ...grpc.CallOption) (*GetUsersURLsRes, error)
 	DeleteUserURLs(ctx context.Context, in *DeleteUserURLsReq, opts ...grpc.CallOption) (*DeleteUserURLsRes, error)
 	GetStats(ctx context.Context, in *GetStatsReq, opts ...grpc.CallOption) (*GetStatsRes, error)
+	GetStatsTimeSeries(ctx context.Context, in *GetStatsTimeSeriesReq, opts ...grpc.CallOption) (*GetStatsTimeSeriesRes, error)
+	GetVersion(ctx context.Context, in *GetVersionReq, opts ...grpc.CallOption) (*GetVersionRes, error)
+	Register(ctx context.Context, in *CredentialsReq, opts ...grpc.CallOption) (*SessionRes, error)
+	Login(ctx context.Context, in *CredentialsReq, opts ...grpc.CallOption) (*SessionRes, error)
 }
 
 type shortenerClient struct {
@@ -119,6 +127,46 @@ func (c *shortenerClient) GetStats(ctx context.Context, in *GetStatsReq, opts ..
 	return out, nil
 }
 
+func (c *shortenerClient) GetStatsTimeSeries(ctx context.Context, in *GetStatsTimeSeriesReq, opts ...grpc.CallOption) (*GetStatsTimeSeriesRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatsTimeSeriesRes)
+	err := c.cc.Invoke(ctx, Shortener_GetStatsTimeSeries_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) GetVersion(ctx context.Context, in *GetVersionReq, opts ...grpc.CallOption) (*GetVersionRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetVersionRes)
+	err := c.cc.Invoke(ctx, Shortener_GetVersion_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) Register(ctx context.Context, in *CredentialsReq, opts ...grpc.CallOption) (*SessionRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SessionRes)
+	err := c.cc.Invoke(ctx, Shortener_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) Login(ctx context.Context, in *CredentialsReq, opts ...grpc.CallOption) (*SessionRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SessionRes)
+	err := c.cc.Invoke(ctx, Shortener_Login_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShortenerServer is the server API for Shortener service.
 // All implementations must embed UnimplementedShortenerServer
 // for forward compatibility.
@@ -130,6 +178,10 @@ type ShortenerServer interface {
 	GetUserURLs(context.Context, *GetUsersURLsReq) (*GetUsersURLsRes, error)
 	DeleteUserURLs(context.Context, *DeleteUserURLsReq) (*DeleteUserURLsRes, error)
 	GetStats(context.Context, *GetStatsReq) (*GetStatsRes, error)
+	GetStatsTimeSeries(context.Context, *GetStatsTimeSeriesReq) (*GetStatsTimeSeriesRes, error)
+	GetVersion(context.Context, *GetVersionReq) (*GetVersionRes, error)
+	Register(context.Context, *CredentialsReq) (*SessionRes, error)
+	Login(context.Context, *CredentialsReq) (*SessionRes, error)
 	mustEmbedUnimplementedShortenerServer()
 }
 
@@ -161,6 +213,18 @@ func (UnimplementedShortenerServer) DeleteUserURLs(context.Context, *DeleteUserU
 func (UnimplementedShortenerServer) GetStats(context.Context, *GetStatsReq) (*GetStatsRes, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
 }
+func (UnimplementedShortenerServer) GetStatsTimeSeries(context.Context, *GetStatsTimeSeriesReq) (*GetStatsTimeSeriesRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatsTimeSeries not implemented")
+}
+func (UnimplementedShortenerServer) GetVersion(context.Context, *GetVersionReq) (*GetVersionRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVersion not implemented")
+}
+func (UnimplementedShortenerServer) Register(context.Context, *CredentialsReq) (*SessionRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedShortenerServer) Login(context.Context, *CredentialsReq) (*SessionRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
 func (UnimplementedShortenerServer) mustEmbedUnimplementedShortenerServer() {}
 func (UnimplementedShortenerServer) testEmbeddedByValue()                   {}
 
@@ -308,6 +372,78 @@ func _Shortener_GetStats_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Shortener_GetStatsTimeSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsTimeSeriesReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetStatsTimeSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_GetStatsTimeSeries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetStatsTimeSeries(ctx, req.(*GetStatsTimeSeriesReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_GetVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVersionReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_GetVersion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetVersion(ctx, req.(*GetVersionReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CredentialsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).Register(ctx, req.(*CredentialsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CredentialsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).Login(ctx, req.(*CredentialsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Shortener_ServiceDesc is the grpc.ServiceDesc for Shortener service.
 // It's only intended for direct use with grpcsrv.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -343,6 +479,390 @@ var Shortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetStats",
 			Handler:    _Shortener_GetStats_Handler,
 		},
+		{
+			MethodName: "GetStatsTimeSeries",
+			Handler:    _Shortener_GetStatsTimeSeries_Handler,
+		},
+		{
+			MethodName: "GetVersion",
+			Handler:    _Shortener_GetVersion_Handler,
+		},
+		{
+			MethodName: "Register",
+			Handler:    _Shortener_Register_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _Shortener_Login_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/shortener.proto",
+}
+
+const (
+	Admin_GetStats_FullMethodName        = "/shortener.Admin/GetStats"
+	Admin_ListURLs_FullMethodName        = "/shortener.Admin/ListURLs"
+	Admin_ListUsers_FullMethodName       = "/shortener.Admin/ListUsers"
+	Admin_SetURLDisabled_FullMethodName  = "/shortener.Admin/SetURLDisabled"
+	Admin_DeleteURL_FullMethodName       = "/shortener.Admin/DeleteURL"
+	Admin_SetUserDisabled_FullMethodName = "/shortener.Admin/SetUserDisabled"
+	Admin_SetUserRole_FullMethodName     = "/shortener.Admin/SetUserRole"
+	Admin_DeleteUser_FullMethodName      = "/shortener.Admin/DeleteUser"
+)
+
+// AdminClient is the client API for Admin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminClient interface {
+	GetStats(ctx context.Context, in *GetStatsReq, opts ...grpc.CallOption) (*GetStatsRes, error)
+	ListURLs(ctx context.Context, in *ListURLsReq, opts ...grpc.CallOption) (*ListURLsRes, error)
+	ListUsers(ctx context.Context, in *ListUsersReq, opts ...grpc.CallOption) (*ListUsersRes, error)
+	SetURLDisabled(ctx context.Context, in *SetURLDisabledReq, opts ...grpc.CallOption) (*AdminRes, error)
+	DeleteURL(ctx context.Context, in *DeleteURLReq, opts ...grpc.CallOption) (*AdminRes, error)
+	SetUserDisabled(ctx context.Context, in *SetUserDisabledReq, opts ...grpc.CallOption) (*AdminRes, error)
+	SetUserRole(ctx context.Context, in *SetUserRoleReq, opts ...grpc.CallOption) (*AdminRes, error)
+	DeleteUser(ctx context.Context, in *DeleteUserReq, opts ...grpc.CallOption) (*DeleteUserRes, error)
+}
+
+type adminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminClient(cc grpc.ClientConnInterface) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) GetStats(ctx context.Context, in *GetStatsReq, opts ...grpc.CallOption) (*GetStatsRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatsRes)
+	err := c.cc.Invoke(ctx, Admin_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListURLs(ctx context.Context, in *ListURLsReq, opts ...grpc.CallOption) (*ListURLsRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListURLsRes)
+	err := c.cc.Invoke(ctx, Admin_ListURLs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListUsers(ctx context.Context, in *ListUsersReq, opts ...grpc.CallOption) (*ListUsersRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListUsersRes)
+	err := c.cc.Invoke(ctx, Admin_ListUsers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetURLDisabled(ctx context.Context, in *SetURLDisabledReq, opts ...grpc.CallOption) (*AdminRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AdminRes)
+	err := c.cc.Invoke(ctx, Admin_SetURLDisabled_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DeleteURL(ctx context.Context, in *DeleteURLReq, opts ...grpc.CallOption) (*AdminRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AdminRes)
+	err := c.cc.Invoke(ctx, Admin_DeleteURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetUserDisabled(ctx context.Context, in *SetUserDisabledReq, opts ...grpc.CallOption) (*AdminRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AdminRes)
+	err := c.cc.Invoke(ctx, Admin_SetUserDisabled_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetUserRole(ctx context.Context, in *SetUserRoleReq, opts ...grpc.CallOption) (*AdminRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AdminRes)
+	err := c.cc.Invoke(ctx, Admin_SetUserRole_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DeleteUser(ctx context.Context, in *DeleteUserReq, opts ...grpc.CallOption) (*DeleteUserRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteUserRes)
+	err := c.cc.Invoke(ctx, Admin_DeleteUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServer is the server API for Admin service.
+// All implementations must embed UnimplementedAdminServer
+// for forward compatibility.
+type AdminServer interface {
+	GetStats(context.Context, *GetStatsReq) (*GetStatsRes, error)
+	ListURLs(context.Context, *ListURLsReq) (*ListURLsRes, error)
+	ListUsers(context.Context, *ListUsersReq) (*ListUsersRes, error)
+	SetURLDisabled(context.Context, *SetURLDisabledReq) (*AdminRes, error)
+	DeleteURL(context.Context, *DeleteURLReq) (*AdminRes, error)
+	SetUserDisabled(context.Context, *SetUserDisabledReq) (*AdminRes, error)
+	SetUserRole(context.Context, *SetUserRoleReq) (*AdminRes, error)
+	DeleteUser(context.Context, *DeleteUserReq) (*DeleteUserRes, error)
+	mustEmbedUnimplementedAdminServer()
+}
+
+// UnimplementedAdminServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAdminServer struct{}
+
+func (UnimplementedAdminServer) GetStats(context.Context, *GetStatsReq) (*GetStatsRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedAdminServer) ListURLs(context.Context, *ListURLsReq) (*ListURLsRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListURLs not implemented")
+}
+func (UnimplementedAdminServer) ListUsers(context.Context, *ListUsersReq) (*ListUsersRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUsers not implemented")
+}
+func (UnimplementedAdminServer) SetURLDisabled(context.Context, *SetURLDisabledReq) (*AdminRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetURLDisabled not implemented")
+}
+func (UnimplementedAdminServer) DeleteURL(context.Context, *DeleteURLReq) (*AdminRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteURL not implemented")
+}
+func (UnimplementedAdminServer) SetUserDisabled(context.Context, *SetUserDisabledReq) (*AdminRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetUserDisabled not implemented")
+}
+func (UnimplementedAdminServer) SetUserRole(context.Context, *SetUserRoleReq) (*AdminRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetUserRole not implemented")
+}
+func (UnimplementedAdminServer) DeleteUser(context.Context, *DeleteUserReq) (*DeleteUserRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteUser not implemented")
+}
+func (UnimplementedAdminServer) mustEmbedUnimplementedAdminServer() {}
+func (UnimplementedAdminServer) testEmbeddedByValue()               {}
+
+// UnsafeAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServer will
+// result in compilation errors.
+type UnsafeAdminServer interface {
+	mustEmbedUnimplementedAdminServer()
+}
+
+func RegisterAdminServer(s grpc.ServiceRegistrar, srv AdminServer) {
+	// If the following call pancis, it indicates UnimplementedAdminServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Admin_ServiceDesc, srv)
+}
+
+func _Admin_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetStats(ctx, req.(*GetStatsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListURLsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ListURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListURLs(ctx, req.(*ListURLsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUsersReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ListUsers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListUsers(ctx, req.(*ListUsersReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_SetURLDisabled_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetURLDisabledReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).SetURLDisabled(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_SetURLDisabled_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).SetURLDisabled(ctx, req.(*SetURLDisabledReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_DeleteURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteURLReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).DeleteURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_DeleteURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).DeleteURL(ctx, req.(*DeleteURLReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_SetUserDisabled_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUserDisabledReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).SetUserDisabled(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_SetUserDisabled_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).SetUserDisabled(ctx, req.(*SetUserDisabledReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_SetUserRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUserRoleReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).SetUserRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_SetUserRole_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).SetUserRole(ctx, req.(*SetUserRoleReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_DeleteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteUserReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).DeleteUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_DeleteUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).DeleteUser(ctx, req.(*DeleteUserReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Admin_ServiceDesc is the grpc.ServiceDesc for Admin service.
+// It's only intended for direct use with grpcsrv.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Admin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "shortener.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetStats",
+			Handler:    _Admin_GetStats_Handler,
+		},
+		{
+			MethodName: "ListURLs",
+			Handler:    _Admin_ListURLs_Handler,
+		},
+		{
+			MethodName: "ListUsers",
+			Handler:    _Admin_ListUsers_Handler,
+		},
+		{
+			MethodName: "SetURLDisabled",
+			Handler:    _Admin_SetURLDisabled_Handler,
+		},
+		{
+			MethodName: "DeleteURL",
+			Handler:    _Admin_DeleteURL_Handler,
+		},
+		{
+			MethodName: "SetUserDisabled",
+			Handler:    _Admin_SetUserDisabled_Handler,
+		},
+		{
+			MethodName: "SetUserRole",
+			Handler:    _Admin_SetUserRole_Handler,
+		},
+		{
+			MethodName: "DeleteUser",
+			Handler:    _Admin_DeleteUser_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/shortener.proto",
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/SversusN/shortener/internal/accounts"
+	"github.com/SversusN/shortener/internal/logger"
+	mw "github.com/SversusN/shortener/internal/middleware"
+)
+
+// AccountHandlers обработчики регистрации и входа
+type AccountHandlers struct {
+	accounts *accounts.Service
+	auth     *mw.AuthMW
+}
+
+// credentialsRequest логин и пароль пользователя
+type credentialsRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	// ClaimAnonymous перенести ссылки анонимного пользователя из текущей куки или заголовка Authorization
+	ClaimAnonymous bool `json:"claim_anonymous"`
+}
+
+// sessionResponse пользователь и токен для клиентов API
+type sessionResponse struct {
+	UserID  string `json:"user_id"`
+	Token   string `json:"token"`
+	Claimed int    `json:"claimed"` // перенесено ссылок анонимного пользователя
+}
+
+// NewAccountHandlers инициализация обработчиков учетных записей
+//
+// auth выдает и сбрасывает куку с токеном с теми же атрибутами, что и middleware.
+func NewAccountHandlers(a *accounts.Service, auth *mw.AuthMW) *AccountHandlers {
+	return &AccountHandlers{a, auth}
+}
+
+// HandlerRegister регистрация пользователя
+func (h *AccountHandlers) HandlerRegister(w http.ResponseWriter, r *http.Request) {
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad JSON", http.StatusBadRequest)
+		return
+	}
+	session, err := h.accounts.Register(r.Context(), req.Login, req.Password)
+	switch {
+	case errors.Is(err, accounts.ErrInvalidLogin), errors.Is(err, accounts.ErrWeakPassword):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, accounts.ErrLoginTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		logger.FromCtx(r.Context()).Error("register failed", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.writeSession(w, r, req, session, http.StatusCreated)
+}
+
+// HandlerLogin вход по логину и паролю
+func (h *AccountHandlers) HandlerLogin(w http.ResponseWriter, r *http.Request) {
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad JSON", http.StatusBadRequest)
+		return
+	}
+	session, err := h.accounts.Login(r.Context(), req.Login, req.Password)
+	switch {
+	case errors.Is(err, accounts.ErrInvalidCredentials):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case errors.Is(err, accounts.ErrUserDisabled):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		logger.FromCtx(r.Context()).Error("login failed", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.writeSession(w, r, req, session, http.StatusOK)
+}
+
+// HandlerLogout выход: кука с токеном удаляется
+//
+// Токен не отзывается на сервере, клиент API должен сам забыть его.
+func (h *AccountHandlers) HandlerLogout(w http.ResponseWriter, _ *http.Request) {
+	h.auth.ClearCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeSession перенос анонимных ссылок по запросу, выдача токена в куке и в теле ответа
+//
+// При ошибке переноса токен не выдается: клиент сохраняет анонимную куку и может повторить запрос.
+func (h *AccountHandlers) writeSession(w http.ResponseWriter, r *http.Request, req credentialsRequest, session accounts.Session, status int) {
+	var claimed int
+	if req.ClaimAnonymous {
+		token, _ := mw.TokenFromRequest(r)
+		var err error
+		claimed, err = h.accounts.Claim(r.Context(), token, session.UserID)
+		if err != nil {
+			logger.FromCtx(r.Context()).Error("claim failed", zap.Error(err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	h.auth.SetCookie(w, session.Token)
+	writeJSON(w, status, sessionResponse{UserID: session.UserID, Token: session.Token, Claimed: claimed})
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/SversusN/shortener/internal/admin"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/storage/dbstorage"
+)
+
+// AdminHandlers обработчики административного API, права проверяет mw.RequirePermission
+type AdminHandlers struct {
+	admin *admin.Service
+	h     *Handlers
+}
+
+// adminURLResponse ссылка любого пользователя
+type adminURLResponse struct {
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	UserID      string     `json:"user_id"`
+	WorkspaceID string     `json:"workspace_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Deleted     bool       `json:"deleted"`
+	Disabled    bool       `json:"disabled"`
+}
+
+// adminUserResponse зарегистрированный пользователь
+type adminUserResponse struct {
+	ID        string    `json:"id"`
+	Login     string    `json:"login,omitempty"`
+	Issuer    string    `json:"issuer,omitempty"`
+	Subject   string    `json:"subject,omitempty"`
+	Role      string    `json:"role"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// disabledRequest блокировка или разблокировка
+type disabledRequest struct {
+	Disabled bool `json:"disabled"`
+}
+
+// roleRequest смена роли
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+// NewAdminHandlers инициализация обработчиков административного API
+//
+// Статистика отдается тем же ответом, что и /api/internal/stats, но по роли, а не по подсети.
+func NewAdminHandlers(a *admin.Service, h *Handlers) *AdminHandlers {
+	return &AdminHandlers{a, h}
+}
+
+// HandlerStats статистика ссылок и пользователей
+func (ah *AdminHandlers) HandlerStats(w http.ResponseWriter, r *http.Request) {
+	ah.h.writeStats(w, r)
+}
+
+// HandlerListURLs ссылки всех пользователей
+//
+// Параметры: q - подстрока короткой или оригинальной ссылки, user_id, limit и offset.
+func (ah *AdminHandlers) HandlerListURLs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, offset, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+	urls, err := ah.admin.ListURLs(r.Context(), dbstorage.URLFilter{
+		Query: q.Get("q"), UserID: q.Get("user_id"), Limit: limit, Offset: offset,
+	})
+	if err != nil {
+		logger.FromCtx(r.Context()).Error("list urls failed", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	res := make([]adminURLResponse, 0, len(urls))
+	for _, u := range urls {
+		item := adminURLResponse{
+			ShortURL:    ah.h.getFullURL(u.ShortURL),
+			OriginalURL: u.OriginalURL,
+			UserID:      u.UserID,
+			WorkspaceID: u.WorkspaceID,
+			CreatedAt:   u.CreatedAt,
+			Deleted:     u.IsDeleted,
+			Disabled:    u.IsDisabled,
+		}
+		if u.IsDeleted && !u.DeletedAt.IsZero() {
+			item.DeletedAt = &u.DeletedAt
+		}
+		res = append(res, item)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// HandlerListUsers зарегистрированные пользователи
+//
+// Параметры: q - подстрока логина, субъекта провайдера или ИД, limit и offset.
+func (ah *AdminHandlers) HandlerListUsers(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+	users, err := ah.admin.ListUsers(r.Context(), dbstorage.UserFilter{Query: r.URL.Query().Get("q"), Limit: limit, Offset: offset})
+	if err != nil {
+		logger.FromCtx(r.Context()).Error("list users failed", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	res := make([]adminUserResponse, 0, len(users))
+	for _, u := range users {
+		res = append(res, adminUserResponse{
+			ID:        u.ID,
+			Login:     u.Login,
+			Issuer:    u.Issuer,
+			Subject:   u.Subject,
+			Role:      u.Role,
+			Disabled:  u.Disabled,
+			CreatedAt: u.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// HandlerSetURLDisabled блокировка ссылки, заблокированная ссылка отвечает 410
+func (ah *AdminHandlers) HandlerSetURLDisabled(w http.ResponseWriter, r *http.Request) {
+	var req disabledRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad JSON", http.StatusBadRequest)
+		return
+	}
+	err := ah.admin.SetURLDisabled(r.Context(), chi.URLParam(r, "shortKey"), req.Disabled)
+	writeAdminResult(w, r, err)
+}
+
+// HandlerDeleteURL удаление ссылки любого пользователя
+func (ah *AdminHandlers) HandlerDeleteURL(w http.ResponseWriter, r *http.Request) {
+	writeAdminResult(w, r, ah.admin.DeleteURL(r.Context(), chi.URLParam(r, "shortKey")))
+}
+
+// HandlerSetUserDisabled блокировка пользователя
+func (ah *AdminHandlers) HandlerSetUserDisabled(w http.ResponseWriter, r *http.Request) {
+	var req disabledRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad JSON", http.StatusBadRequest)
+		return
+	}
+	writeAdminResult(w, r, ah.admin.SetUserDisabled(r.Context(), chi.URLParam(r, "userID"), req.Disabled))
+}
+
+// HandlerSetUserRole смена роли пользователя
+func (ah *AdminHandlers) HandlerSetUserRole(w http.ResponseWriter, r *http.Request) {
+	var req roleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad JSON", http.StatusBadRequest)
+		return
+	}
+	writeAdminResult(w, r, ah.admin.SetUserRole(r.Context(), chi.URLParam(r, "userID"), req.Role))
+}
+
+// HandlerDeleteUser удаление пользователя с его ссылками и ключами API
+func (ah *AdminHandlers) HandlerDeleteUser(w http.ResponseWriter, r *http.Request) {
+	deleted, err := ah.admin.DeleteUser(r.Context(), chi.URLParam(r, "userID"))
+	if err != nil {
+		writeAdminResult(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"deleted_urls": deleted})
+}
+
+// writeAdminResult ответ на изменение: 204 или код ошибки
+func writeAdminResult(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, admin.ErrNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, admin.ErrInvalidRole):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, admin.ErrSelf):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logger.FromCtx(r.Context()).Error("admin operation failed", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// parsePage параметры limit и offset, при ошибке ответ уже отправлен
+func parsePage(w http.ResponseWriter, r *http.Request) (limit, offset int, ok bool) {
+	for name, v := range map[string]*int{"limit": &limit, "offset": &offset} {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			http.Error(w, "Bad "+name, http.StatusBadRequest)
+			return 0, 0, false
+		}
+		*v = n
+	}
+	return limit, offset, true
+}
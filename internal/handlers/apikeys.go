@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/SversusN/shortener/internal/apikeys"
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/storage/dbstorage"
+)
+
+// APIKeyHandlers обработчики ключей API пользователя
+type APIKeyHandlers struct {
+	keys *apikeys.Service
+}
+
+// createKeyRequest запрос выпуска ключа
+type createKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// keyResponse ключ API без секрета
+type keyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Key        string     `json:"key,omitempty"` // секрет, только в ответе на создание
+}
+
+// NewAPIKeyHandlers инициализация обработчиков ключей API
+func NewAPIKeyHandlers(keys *apikeys.Service) *APIKeyHandlers {
+	return &APIKeyHandlers{keys}
+}
+
+// HandlerCreateKey выпуск ключа API
+func (h *APIKeyHandlers) HandlerCreateKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromCtx(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var req createKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad JSON", http.StatusBadRequest)
+		return
+	}
+	key, secret, err := h.keys.Create(r.Context(), userID, req.Name, req.Scopes)
+	switch {
+	case errors.Is(err, apikeys.ErrInvalidName), errors.Is(err, apikeys.ErrInvalidScope):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		logger.FromCtx(r.Context()).Error("create api key failed", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	res := newKeyResponse(key)
+	res.Key = secret
+	writeJSON(w, http.StatusCreated, res)
+}
+
+// HandlerListKeys ключи API пользователя без секретов
+func (h *APIKeyHandlers) HandlerListKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromCtx(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	keys, err := h.keys.List(r.Context(), userID)
+	if err != nil {
+		logger.FromCtx(r.Context()).Error("list api keys failed", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	res := make([]keyResponse, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, newKeyResponse(k))
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// HandlerRevokeKey отзыв ключа API
+func (h *APIKeyHandlers) HandlerRevokeKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromCtx(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	err = h.keys.Revoke(r.Context(), userID, chi.URLParam(r, "keyID"))
+	switch {
+	case errors.Is(err, apikeys.ErrNotFound):
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	case err != nil:
+		logger.FromCtx(r.Context()).Error("revoke api key failed", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newKeyResponse ключ API для ответа
+func newKeyResponse(k dbstorage.APIKey) keyResponse {
+	res := keyResponse{ID: k.ID, Name: k.Name, Scopes: k.Scopes, CreatedAt: k.CreatedAt}
+	if !k.LastUsedAt.IsZero() {
+		res.LastUsedAt = &k.LastUsedAt
+	}
+	return res
+}
+
+// writeJSON ответ в формате JSON
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
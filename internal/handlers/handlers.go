@@ -6,19 +6,25 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
-	"net"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
 
 	"github.com/SversusN/shortener/config"
+	"github.com/SversusN/shortener/internal/apikeys"
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/buildinfo"
+	"github.com/SversusN/shortener/internal/clientip"
 	"github.com/SversusN/shortener/internal/internalerrors"
+	"github.com/SversusN/shortener/internal/logger"
 	mw "github.com/SversusN/shortener/internal/middleware"
 	"github.com/SversusN/shortener/internal/pkg/utils"
 	"github.com/SversusN/shortener/internal/storage/dbstorage"
 	"github.com/SversusN/shortener/internal/storage/storage"
+	"github.com/SversusN/shortener/internal/workspaces"
 )
 
 // Handlers тип для внедрения зависимости
@@ -26,6 +32,8 @@ type Handlers struct {
 	cfg       *config.Config
 	s         storage.Storage
 	waitGroup *sync.WaitGroup
+	info      buildinfo.Info
+	trusted   *clientip.Gate
 }
 
 // JSONRequest передача JSON Объекта в обработчик
@@ -58,13 +66,28 @@ type JSONUserURLs struct {
 
 // statsResponse ответ статистики сервера
 type statsResponse struct {
-	URLs  int `json:"urls"`  // количество сокращённых URL в сервисе
-	Users int `json:"users"` // количество пользователей в сервисе
+	URLs           int             `json:"urls"`             // количество активных сокращённых URL в сервисе
+	Users          int             `json:"users"`            // количество пользователей в сервисе
+	Total          int             `json:"total"`            // всего сокращённых URL, включая удаленные
+	Deleted        int             `json:"deleted"`          // количество удаленных URL
+	CreatedLastDay int             `json:"created_last_24h"` // URL, созданные за последние 24 часа
+	TopUsers       []topUserResult `json:"top_users"`        // пользователи с наибольшим числом ссылок
+}
+
+// topUserResult элемент рейтинга пользователей
+type topUserResult struct {
+	UserID string `json:"user_id"`
+	URLs   int    `json:"urls"`
 }
 
 // NewHandlers инициализация объекта handlers
-func NewHandlers(cfg *config.Config, s storage.Storage, waitGroup *sync.WaitGroup) *Handlers {
-	return &Handlers{cfg, s, waitGroup}
+func NewHandlers(cfg *config.Config, s storage.Storage, waitGroup *sync.WaitGroup, info buildinfo.Info) *Handlers {
+	return &Handlers{cfg: cfg, s: s, waitGroup: waitGroup, info: info}
+}
+
+// SetTrustedGate задает проверку доверенных подсетей для служебных обработчиков, без нее доступ закрыт
+func (h *Handlers) SetTrustedGate(gate *clientip.Gate) {
+	h.trusted = gate
 }
 
 // HandlerPost получает оригинальный URL для сокращения в формате text\plain
@@ -72,11 +95,11 @@ func (h *Handlers) HandlerPost(res http.ResponseWriter, req *http.Request) {
 	originalURL, err := io.ReadAll(req.Body)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
-		log.Printf("Error parsing URL %s", err)
+		logger.FromCtx(req.Context()).Error("Error parsing URL", zap.Error(err))
 		return
 	}
 	res.Header().Set("Content-Type", "text/plain")
-	userID, err := getUserIDFromCtx(req)
+	owner, err := getOwnerFromCtx(req)
 	if errors.Is(err, internalerrors.ErrUserTypeError) {
 		res.WriteHeader(http.StatusBadRequest)
 		return
@@ -85,8 +108,10 @@ func (h *Handlers) HandlerPost(res http.ResponseWriter, req *http.Request) {
 		var shortURL string
 		key := utils.GenerateShortKey()
 		var result string
-		result, err = h.s.SetURL(key, string(originalURL), userID)
-
+		result, err = h.s.SetURL(req.Context(), key, string(originalURL), owner)
+		if writeQuotaError(res, err) {
+			return
+		}
 		switch {
 		case errors.Is(err, internalerrors.ErrOriginalURLAlreadyExists):
 			res.WriteHeader(http.StatusConflict)
@@ -109,7 +134,7 @@ func (h *Handlers) HandlerGet(res http.ResponseWriter, req *http.Request) {
 		http.Error(res, "Shortened key is missing", http.StatusBadRequest)
 		return
 	}
-	originalURL, err := h.s.GetURL(key)
+	originalURL, err := h.s.GetURL(req.Context(), key)
 	if errors.Is(err, internalerrors.ErrDeleted) {
 		http.Error(res, err.Error(), http.StatusGone)
 		return
@@ -127,7 +152,7 @@ func (h *Handlers) HandlerJSONPost(res http.ResponseWriter, req *http.Request) {
 	b, err := io.ReadAll(req.Body)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
-		log.Printf("Error parsing URLs %s", err)
+		logger.FromCtx(req.Context()).Error("Error parsing URLs", zap.Error(err))
 	}
 	res.Header().Set("Content-Type", "application/json")
 	var (
@@ -136,19 +161,21 @@ func (h *Handlers) HandlerJSONPost(res http.ResponseWriter, req *http.Request) {
 		key     string
 	)
 	if err = json.Unmarshal(b, &reqBody); err != nil {
-		log.Printf("Error parsing JSON request body: %s", err)
+		logger.FromCtx(req.Context()).Error("Error parsing JSON request body", zap.Error(err))
 		res.WriteHeader(http.StatusBadRequest)
 	}
 	defer req.Body.Close()
-	userID, err := getUserIDFromCtx(req)
+	owner, err := getOwnerFromCtx(req)
 	if errors.Is(err, internalerrors.ErrUserTypeError) {
 		res.WriteHeader(http.StatusBadRequest)
 		return
 	}
 	key = utils.GenerateShortKey()
 	var result string
-	result, err = h.s.SetURL(key, reqBody.URL, userID)
-
+	result, err = h.s.SetURL(req.Context(), key, reqBody.URL, owner)
+	if writeQuotaError(res, err) {
+		return
+	}
 	switch {
 	case errors.Is(err, internalerrors.ErrOriginalURLAlreadyExists):
 		res.WriteHeader(http.StatusConflict)
@@ -171,7 +198,7 @@ func (h *Handlers) HandlerJSONPostBatch(res http.ResponseWriter, req *http.Reque
 	b, err := io.ReadAll(req.Body)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
-		log.Printf("Error parsing URLs %s", err)
+		logger.FromCtx(req.Context()).Error("Error parsing URLs", zap.Error(err))
 		return
 	}
 	res.Header().Set("Content-Type", "application/json")
@@ -184,21 +211,24 @@ func (h *Handlers) HandlerJSONPostBatch(res http.ResponseWriter, req *http.Reque
 	if err = json.Unmarshal(b, &reqBody); err != nil {
 		http.Error(res, "Bad JSON request...", http.StatusBadRequest)
 	}
-	userID, err := getUserIDFromCtx(req)
+	owner, err := getOwnerFromCtx(req)
 	if errors.Is(err, internalerrors.ErrUserTypeError) {
 		res.WriteHeader(http.StatusBadRequest)
 		return
 	}
 	for _, r := range reqBody {
 		newKey := utils.GenerateShortKey()
-		saveUrls[newKey] = dbstorage.UserURL{UserID: userID, OriginalURL: r.OriginalURL}
+		saveUrls[newKey] = dbstorage.UserURL{UserID: owner.UserID, OriginalURL: r.OriginalURL, WorkspaceID: owner.WorkspaceID}
 	}
 	if len(saveUrls) > 0 {
 		var rs JSONBatchResponse
 
 		var mapResp map[string]dbstorage.UserURL
 
-		mapResp, err = h.s.SetURLBatch(saveUrls)
+		mapResp, err = h.s.SetURLBatch(req.Context(), saveUrls)
+		if writeQuotaError(res, err) {
+			return
+		}
 
 		for s := range mapResp {
 			i := indexOfURL(mapResp[s].OriginalURL, reqBody)
@@ -224,12 +254,11 @@ func (h *Handlers) HandlerJSONPostBatch(res http.ResponseWriter, req *http.Reque
 
 // HandlerDBPing проверяет возможность использования БД
 func (h *Handlers) HandlerDBPing(res http.ResponseWriter, req *http.Request) {
-	pinger, ok := h.s.(storage.Pinger)
-	if !ok {
-		http.Error(res, "No DB to ping , sorry...", http.StatusBadRequest)
-		return
+	//Хранилище без соединения доступно всегда
+	var result error
+	if pinger, ok := h.s.(storage.Pinger); ok {
+		result = pinger.Ping(req.Context())
 	}
-	result := pinger.Ping()
 	res.Header().Set("Content-Type", "text/plain")
 	if result == nil {
 		res.WriteHeader(http.StatusOK)
@@ -243,20 +272,20 @@ func (h *Handlers) HandlerDBPing(res http.ResponseWriter, req *http.Request) {
 // HandlerGetUserURLs получение пользователя из Cookie
 func (h *Handlers) HandlerGetUserURLs(w http.ResponseWriter, r *http.Request) {
 	_, err := r.Cookie(mw.NameCookie)
-	if err != nil {
+	if err != nil && auth.BearerToken(r.Header.Get("Authorization")) == "" && r.Header.Get(apikeys.Header) == "" {
 		http.Error(w, "Bad Token, no token in cookie", http.StatusUnauthorized)
 		return
 	}
-	userID, err := getUserIDFromCtx(r)
+	owner, err := getOwnerFromCtx(r)
 	if errors.Is(err, internalerrors.ErrUserTypeError) {
 		http.Error(w, "Bad userID, need Int data", http.StatusBadRequest)
 		return
 	}
-	if userID == "" {
+	if owner.UserID == "" {
 		http.Error(w, "No userID, bad token data", http.StatusUnauthorized)
 		return
 	}
-	mapRest, err := h.s.GetUserUrls(userID)
+	mapRest, err := h.s.GetUserUrls(r.Context(), owner)
 	if errors.Is(err, internalerrors.ErrNotFound) {
 		http.Error(w, "No URLs for user", http.StatusNotFound)
 		return
@@ -292,11 +321,11 @@ func (h *Handlers) HandlerGetUserURLs(w http.ResponseWriter, r *http.Request) {
 // Удаление происходит асинхронно
 func (h *Handlers) HandlerDeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 
-	userID, err := getUserIDFromCtx(r)
+	owner, err := getOwnerFromCtx(r)
 	if errors.Is(err, internalerrors.ErrUserTypeError) {
 		http.Error(w, "Bad userID, need Int data", http.StatusBadRequest)
 	}
-	if userID == "" {
+	if owner.UserID == "" {
 		http.Error(w, "No userID, bad token data", http.StatusUnauthorized)
 	}
 	deleteURLs := make([]string, 0)
@@ -309,7 +338,7 @@ func (h *Handlers) HandlerDeleteUserURLs(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Bad JSON", http.StatusInternalServerError)
 	}
 	// Создается канал с наполнением URL для удаления
-	deleteCh, err := h.s.DeleteUserURLs(userID, h.waitGroup)
+	deleteCh, err := h.s.DeleteUserURLs(r.Context(), owner, h.waitGroup)
 	if err != nil {
 		http.Error(w, "Bad userID", http.StatusBadRequest)
 	}
@@ -327,51 +356,126 @@ func (h *Handlers) HandlerDeleteUserURLs(w http.ResponseWriter, r *http.Request)
 
 // HandlerGetStats получение статистической информации о ссылках/пользователях
 func (h *Handlers) HandlerGetStats(w http.ResponseWriter, r *http.Request) {
-	trustSubnet, err := utils.GetCIDR(h.cfg.TrustedSubnet)
-	if err != nil {
+	if !h.isTrustedRequest(r) {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
-	if trustSubnet == nil {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	h.writeStats(w, r)
+}
+
+// writeStats ответ со статистикой для доверенной подсети и административного API
+func (h *Handlers) writeStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.s.GetStats(r.Context())
+	if err != nil {
+		http.Error(w, "Can`t get from storage", http.StatusInternalServerError)
 		return
 	}
-	clientIP := r.Header.Get("X-Real-IP")
-	if clientIP == "" {
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
+	statsResp := statsResponse{
+		URLs:           stats.Active,
+		Users:          stats.Users,
+		Total:          stats.Total,
+		Deleted:        stats.Deleted,
+		CreatedLastDay: stats.CreatedLastDay,
+		TopUsers:       make([]topUserResult, 0, len(stats.TopUsers)),
+	}
+	for _, u := range stats.TopUsers {
+		statsResp.TopUsers = append(statsResp.TopUsers, topUserResult{UserID: u.UserID, URLs: u.URLs})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(statsResp)
+	if err != nil {
+		logger.FromCtx(r.Context()).Error("Error encoding stats", zap.Error(err))
 	}
+}
 
-	ip := net.ParseIP(clientIP)
-	if ip == nil {
+// timeSeriesBucket интервал временного ряда статистики
+type timeSeriesBucket struct {
+	Start   time.Time `json:"start"`
+	Created int       `json:"created"`
+	Deleted int       `json:"deleted"`
+	Clicked int       `json:"clicked"`
+}
+
+// HandlerGetStatsTimeSeries временной ряд созданных, удаленных ссылок и переходов
+//
+// Параметры: from и to в RFC3339 (по умолчанию последние сутки), interval - hour или day.
+func (h *Handlers) HandlerGetStatsTimeSeries(w http.ResponseWriter, r *http.Request) {
+	if !h.isTrustedRequest(r) {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
+	from, to, interval, err := parseTimeRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	buckets, err := h.s.GetTimeSeries(r.Context(), from, to, interval)
+	if errors.Is(err, dbstorage.ErrBadTimeRange) {
+		http.Error(w, "Bad time range", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Can`t get from storage", http.StatusInternalServerError)
+		return
+	}
+	resBody := make([]timeSeriesBucket, 0, len(buckets))
+	for _, b := range buckets {
+		resBody = append(resBody, timeSeriesBucket{Start: b.Start, Created: b.Created, Deleted: b.Deleted, Clicked: b.Clicked})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(resBody)
+	if err != nil {
+		logger.FromCtx(r.Context()).Error("Error encoding time series", zap.Error(err))
+	}
+}
 
-	if !trustSubnet.Contains(ip) {
+// HandlerGetVersion сведения о сборке и конфигурации сервера
+func (h *Handlers) HandlerGetVersion(w http.ResponseWriter, r *http.Request) {
+	if !h.isTrustedRequest(r) {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
-	countUsers, countURLs, err := storage.Storage.GetStats(nil)
+	resBody, err := json.Marshal(h.info)
 	if err != nil {
-		statsResp := statsResponse{
-			URLs:  0,
-			Users: 0,
+		http.Error(w, "Bad JSON", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resBody)
+}
+
+// parseTimeRange разбор параметров временного ряда из строки запроса
+func parseTimeRange(r *http.Request) (from time.Time, to time.Time, interval time.Duration, err error) {
+	q := r.URL.Query()
+	to = time.Now()
+	if v := q.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, 0, errors.New("bad 'to' parameter, need RFC3339")
 		}
-		statsResp.Users = countUsers
-		statsResp.URLs = countURLs
-
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "application/json")
-		err = json.NewEncoder(w).Encode(statsResp)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	}
+	from = to.Add(-dbstorage.IntervalDay)
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, 0, errors.New("bad 'from' parameter, need RFC3339")
 		}
-	} else {
-		http.Error(w, "Can`t get from storage", http.StatusInternalServerError)
-		return
 	}
+	switch q.Get("interval") {
+	case "", "hour":
+		interval = dbstorage.IntervalHour
+	case "day":
+		interval = dbstorage.IntervalDay
+	default:
+		return from, to, 0, errors.New("bad 'interval' parameter, need hour or day")
+	}
+	return from, to, interval, nil
+}
+
+// isTrustedRequest проверка адреса клиента по доверенным подсетям
+func (h *Handlers) isTrustedRequest(r *http.Request) bool {
+	return h.trusted.AllowRequest(r)
 }
 
 // indexOfURL получает индекс или возвращает -1
@@ -385,18 +489,10 @@ func indexOfURL(element string, data []JSONBatchRequest) int {
 	return -1 //not found.
 }
 
-// getUserIDFromCtx попытка получить ИД пользователя из Cookie
-func getUserIDFromCtx(r *http.Request) (string, error) {
-	userID := r.Context().Value(mw.CtxUser)
-	if userID == nil {
-		return "", errors.New("user ID is missing")
-	}
-	userIDInt, ok := userID.(string)
-	if !ok {
-		return "", internalerrors.ErrUserTypeError
-	} else {
-		return userIDInt, nil
-	}
+// getOwnerFromCtx владелец ссылок запроса: активное пространство или пользователь,
+// проверенный middleware авторизации
+func getOwnerFromCtx(r *http.Request) (dbstorage.Owner, error) {
+	return workspaces.OwnerFromCtx(r.Context())
 }
 
 // getFullURL - создает валидную полноценную ссылку из адреса и короткого ключа
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/SversusN/shortener/internal/accounts"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/oidc"
+)
+
+// Кука с состоянием входа через OpenID Connect
+const (
+	oidcCookie  = "OIDC"
+	oidcFlowTTL = 10 * time.Minute
+)
+
+// errBadFlow состояние входа не найдено или не совпадает
+var errBadFlow = errors.New("oidc login state is missing or does not match, start login again")
+
+// OIDCHandlers обработчики входа через OpenID Connect
+type OIDCHandlers struct {
+	provider *oidc.Provider
+	accounts *AccountHandlers
+}
+
+// oidcFlow состояние входа между переходом к провайдеру и возвратом
+type oidcFlow struct {
+	state, nonce, verifier string
+	claim                  bool
+}
+
+// NewOIDCHandlers инициализация обработчиков входа через провайдер
+//
+// Сессия выдается так же, как при входе по паролю, см. AccountHandlers.
+func NewOIDCHandlers(p *oidc.Provider, accounts *AccountHandlers) *OIDCHandlers {
+	return &OIDCHandlers{p, accounts}
+}
+
+// HandlerLogin переход к провайдеру, claim_anonymous=true переносит анонимные ссылки после входа
+func (h *OIDCHandlers) HandlerLogin(w http.ResponseWriter, r *http.Request) {
+	var flow oidcFlow
+	for _, v := range []*string{&flow.state, &flow.nonce, &flow.verifier} {
+		s, err := oidc.RandomString()
+		if err != nil {
+			logger.FromCtx(r.Context()).Error("oidc login failed", zap.Error(err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		*v = s
+	}
+	flow.claim = r.URL.Query().Get("claim_anonymous") == "true"
+	target, err := h.provider.AuthCodeURL(r.Context(), flow.state, flow.nonce, flow.verifier)
+	if err != nil {
+		logger.FromCtx(r.Context()).Error("oidc provider unavailable", zap.Error(err))
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+	h.accounts.auth.SetFlowCookie(w, oidcCookie, flow.encode(), oidcFlowTTL)
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// HandlerCallback возврат от провайдера: проверка state, обмен кода и выдача сессии
+func (h *OIDCHandlers) HandlerCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		http.Error(w, "Login rejected by identity provider: "+e, http.StatusUnauthorized)
+		return
+	}
+	cookie, err := r.Cookie(oidcCookie)
+	if err != nil {
+		http.Error(w, errBadFlow.Error(), http.StatusBadRequest)
+		return
+	}
+	//Состояние одноразовое
+	h.accounts.auth.SetFlowCookie(w, oidcCookie, "", 0)
+	flow, ok := decodeFlow(cookie.Value)
+	if !ok || subtle.ConstantTimeCompare([]byte(flow.state), []byte(q.Get("state"))) != 1 || q.Get("code") == "" {
+		http.Error(w, errBadFlow.Error(), http.StatusBadRequest)
+		return
+	}
+	rawIDToken, err := h.provider.Exchange(r.Context(), q.Get("code"), flow.verifier)
+	if err != nil {
+		logger.FromCtx(r.Context()).Warn("oidc code exchange failed", zap.Error(err))
+		http.Error(w, "Code exchange failed", http.StatusUnauthorized)
+		return
+	}
+	claims, err := h.provider.Verify(r.Context(), rawIDToken, flow.nonce)
+	if err != nil {
+		logger.FromCtx(r.Context()).Warn("oidc id token rejected", zap.Error(err))
+		http.Error(w, "Invalid ID token", http.StatusUnauthorized)
+		return
+	}
+	session, err := h.accounts.accounts.LoginIdentity(r.Context(), h.provider.Issuer(), claims.Subject)
+	if errors.Is(err, accounts.ErrUserDisabled) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		logger.FromCtx(r.Context()).Error("oidc login failed", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.accounts.writeSession(w, r, credentialsRequest{ClaimAnonymous: flow.claim}, session, http.StatusOK)
+}
+
+// encode значение куки: значения в base64url и не содержат точек
+func (f oidcFlow) encode() string {
+	claim := "0"
+	if f.claim {
+		claim = "1"
+	}
+	return strings.Join([]string{f.state, f.nonce, f.verifier, claim}, ".")
+}
+
+// decodeFlow разбор куки состояния
+func decodeFlow(s string) (oidcFlow, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return oidcFlow{}, false
+	}
+	return oidcFlow{state: parts[0], nonce: parts[1], verifier: parts[2], claim: parts[3] == "1"}, true
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/internalerrors"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/quota"
+)
+
+// QuotaHandlers обработчики квот пользователя
+type QuotaHandlers struct {
+	q *quota.Service
+}
+
+// quotaResponse использование квот, лимит 0 - без ограничения
+type quotaResponse struct {
+	Links        int `json:"links"`
+	MaxLinks     int `json:"max_links"`
+	MaxBatchSize int `json:"max_batch_size"`
+	MaxURLLength int `json:"max_url_length"`
+}
+
+// NewQuotaHandlers инициализация обработчиков квот
+func NewQuotaHandlers(q *quota.Service) *QuotaHandlers {
+	return &QuotaHandlers{q}
+}
+
+// HandlerUsage текущее использование квот пользователем
+func (h *QuotaHandlers) HandlerUsage(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromCtx(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	usage, err := h.q.Usage(r.Context(), userID)
+	if err != nil {
+		logger.FromCtx(r.Context()).Error("quota usage failed", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, quotaResponse{
+		Links:        usage.Links,
+		MaxLinks:     usage.MaxLinks,
+		MaxBatchSize: usage.MaxBatchSize,
+		MaxURLLength: usage.MaxURLLength,
+	})
+}
+
+// writeQuotaError ответ на нарушение квоты, false - ошибка не связана с квотами
+func writeQuotaError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, internalerrors.ErrURLTooLong):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, internalerrors.ErrBatchTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, internalerrors.ErrQuotaExceeded):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/workspaces"
+)
+
+// WorkspaceHandlers обработчики управления пространствами и их участниками
+type WorkspaceHandlers struct {
+	ws *workspaces.Service
+}
+
+// createWorkspaceRequest запрос создания пространства
+type createWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+// workspaceResponse пространство с ролью пользователя
+type workspaceResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// memberResponse участник пространства
+type memberResponse struct {
+	UserID    string    `json:"user_id"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewWorkspaceHandlers инициализация обработчиков пространств
+func NewWorkspaceHandlers(ws *workspaces.Service) *WorkspaceHandlers {
+	return &WorkspaceHandlers{ws}
+}
+
+// HandlerCreate создание пространства, пользователь становится владельцем
+func (h *WorkspaceHandlers) HandlerCreate(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromCtx(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var req createWorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad JSON", http.StatusBadRequest)
+		return
+	}
+	ws, err := h.ws.Create(r.Context(), userID, req.Name)
+	if !writeWorkspaceResult(w, r, err, "create workspace failed") {
+		return
+	}
+	writeJSON(w, http.StatusCreated, workspaceResponse{ID: ws.ID, Name: ws.Name, Role: ws.Role, CreatedAt: ws.CreatedAt})
+}
+
+// HandlerList пространства пользователя с его ролями
+func (h *WorkspaceHandlers) HandlerList(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromCtx(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	list, err := h.ws.List(r.Context(), userID)
+	if !writeWorkspaceResult(w, r, err, "list workspaces failed") {
+		return
+	}
+	res := make([]workspaceResponse, 0, len(list))
+	for _, ws := range list {
+		res = append(res, workspaceResponse{ID: ws.ID, Name: ws.Name, Role: ws.Role, CreatedAt: ws.CreatedAt})
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// HandlerMembers участники пространства
+func (h *WorkspaceHandlers) HandlerMembers(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromCtx(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	members, err := h.ws.Members(r.Context(), userID, chi.URLParam(r, "workspaceID"))
+	if !writeWorkspaceResult(w, r, err, "list workspace members failed") {
+		return
+	}
+	res := make([]memberResponse, 0, len(members))
+	for _, m := range members {
+		res = append(res, memberResponse{UserID: m.UserID, Role: m.Role, CreatedAt: m.CreatedAt})
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// HandlerSetMember добавление участника или смена его роли
+func (h *WorkspaceHandlers) HandlerSetMember(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromCtx(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var req roleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad JSON", http.StatusBadRequest)
+		return
+	}
+	err = h.ws.SetMember(r.Context(), userID, chi.URLParam(r, "workspaceID"), chi.URLParam(r, "userID"), req.Role)
+	if writeWorkspaceResult(w, r, err, "set workspace member failed") {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandlerRemoveMember исключение участника или выход из пространства
+func (h *WorkspaceHandlers) HandlerRemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.UserIDFromCtx(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	err = h.ws.RemoveMember(r.Context(), userID, chi.URLParam(r, "workspaceID"), chi.URLParam(r, "userID"))
+	if writeWorkspaceResult(w, r, err, "remove workspace member failed") {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// writeWorkspaceResult ответ с ошибкой сервиса пространств, true - ошибки нет
+func writeWorkspaceResult(w http.ResponseWriter, r *http.Request, err error, msg string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, workspaces.ErrNotMember), errors.Is(err, workspaces.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, workspaces.ErrInvalidName), errors.Is(err, workspaces.ErrInvalidRole):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, workspaces.ErrLastOwner):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, workspaces.ErrNotFound):
+		http.Error(w, "Member not found", http.StatusNotFound)
+	default:
+		logger.FromCtx(r.Context()).Error(msg, zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+	return false
+}
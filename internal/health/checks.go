@@ -0,0 +1,40 @@
+// Типовые проверки готовности
+package health
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// QueueDepth проверка, что очередь не превышает limit
+func QueueDepth(depth func() int64, limit int64) Check {
+	return func(_ context.Context) error {
+		if d := depth(); d > limit {
+			return fmt.Errorf("queue depth %d exceeds %d", d, limit)
+		}
+		return nil
+	}
+}
+
+// DiskSpace проверка свободного места на дисках с файлами paths
+//
+// Пустые пути пропускаются, проверяется каталог файла.
+func DiskSpace(minFreeBytes uint64, paths ...string) Check {
+	return func(_ context.Context) error {
+		for _, path := range paths {
+			if path == "" {
+				continue
+			}
+			dir := filepath.Dir(path)
+			free, err := freeSpace(dir)
+			if err != nil {
+				return fmt.Errorf("%s: %w", dir, err)
+			}
+			if free < minFreeBytes {
+				return fmt.Errorf("%s: %d MB free, need %d MB", dir, free>>20, minFreeBytes>>20)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package health
+
+import "math"
+
+// freeSpace на этих системах место не проверяется
+func freeSpace(_ string) (uint64, error) {
+	return math.MaxUint64, nil
+}
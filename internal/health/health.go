@@ -0,0 +1,151 @@
+// Package health содержит проверки живости и готовности сервиса.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Состояния проверок
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// checkTimeout время на одну проверку, если в контексте нет более раннего дедлайна
+const checkTimeout = 2 * time.Second
+
+// Check проверка зависимости, nil - зависимость доступна
+type Check func(ctx context.Context) error
+
+// CheckResult результат одной проверки
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report сводный результат проверок
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// namedCheck проверка с именем для отчета
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker набор проверок готовности
+//
+// После SetShuttingDown готовность всегда отрицательная, чтобы балансировщик
+// перестал отправлять запросы до остановки серверов.
+type Checker struct {
+	mu           sync.RWMutex
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+// New создает пустой набор проверок
+func New() *Checker {
+	return &Checker{}
+}
+
+// Add добавляет проверку готовности
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// SetShuttingDown переводит сервис в состояние остановки
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// ShuttingDown признак остановки сервиса
+func (c *Checker) ShuttingDown() bool {
+	return c.shuttingDown.Load()
+}
+
+// Ready выполняет проверки параллельно и собирает отчет
+func (c *Checker) Ready(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := make([]namedCheck, len(c.checks))
+	copy(checks, c.checks)
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(checks)+1)}
+	if c.ShuttingDown() {
+		report.Status = StatusDown
+		report.Checks["shutdown"] = CheckResult{Status: StatusDown, Error: "server is shutting down"}
+	}
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}(i, nc.check)
+	}
+	wg.Wait()
+	for i, nc := range checks {
+		report.Checks[nc.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// run выполнение одной проверки с таймаутом
+func run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{Status: StatusUp, Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler обработчик /healthz: процесс жив и обслуживает запросы
+//
+// Зависимости не проверяются, чтобы их сбой не приводил к перезапуску сервиса.
+func (c *Checker) LivenessHandler(w http.ResponseWriter, _ *http.Request) {
+	writeReport(w, Report{Status: StatusUp})
+}
+
+// ReadinessHandler обработчик /readyz: сервис готов принимать запросы
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, c.Ready(r.Context()))
+}
+
+// PublicReadinessHandler обработчик /readyz основного сервера: только итоговое состояние
+//
+// Ошибки проверок могут содержать адреса и пути, подробный отчет отдает служебный сервер.
+func (c *Checker) PublicReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, Report{Status: c.Ready(r.Context()).Status})
+}
+
+// writeReport ответ с отчетом, 503 при отрицательном результате
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != StatusUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,61 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadiness(t *testing.T) {
+	hc := New()
+	hc.Add("storage", func(context.Context) error { return nil })
+	hc.Add("delete_worker", QueueDepth(func() int64 { return 5 }, 10))
+
+	rec := httptest.NewRecorder()
+	hc.ReadinessHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var report Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, StatusUp, report.Status)
+	assert.Equal(t, StatusUp, report.Checks["storage"].Status)
+	assert.Equal(t, StatusUp, report.Checks["delete_worker"].Status)
+
+	hc.Add("disk", func(context.Context) error { return errors.New("no space") })
+	report = hc.Ready(context.Background())
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, "no space", report.Checks["disk"].Error)
+	assert.Equal(t, StatusUp, report.Checks["storage"].Status)
+
+	//Публичный ответ без подробностей проверок
+	rec = httptest.NewRecorder()
+	hc.PublicReadinessHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "no space")
+}
+
+func TestShuttingDown(t *testing.T) {
+	hc := New()
+	hc.SetShuttingDown()
+
+	rec := httptest.NewRecorder()
+	hc.ReadinessHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "shutting down")
+
+	//Живость от остановки не зависит
+	rec = httptest.NewRecorder()
+	hc.LivenessHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, DiskSpace(1, dir+"/file.json", "")(context.Background()))
+	assert.Error(t, DiskSpace(1<<62, dir+"/file.json")(context.Background()))
+}
@@ -14,6 +14,15 @@ var (
 	ErrUserTypeError            = errors.New("user type error")             // Ошибка получения ИД пользователя
 	ErrUserNotFound             = errors.New("user not found error")        // Ошибка наличия пользователя
 	ErrDeleted                  = errors.New("try get deleted error")       //Попытка получения удаленной ссылки
+	ErrLoginTaken               = errors.New("login already taken")         // Логин занят другим пользователем
+	ErrNotAnonymous             = errors.New("user is registered")          // Ссылки зарегистрированного пользователя не переносятся
+	ErrURLTooLong               = errors.New("url is too long")             // Ссылка длиннее разрешенной
+	ErrBatchTooLarge            = errors.New("batch is too large")          // В пакете больше ссылок, чем разрешено
+	ErrQuotaExceeded            = errors.New("link quota exceeded")         // Пользователь исчерпал лимит активных ссылок
+	// ErrDisabled ссылка заблокирована администратором, для клиентов выглядит как удаленная
+	ErrDisabled = fmt.Errorf("link is disabled: %w", ErrDeleted)
+	// ErrLastOwner у пространства должен остаться хотя бы один владелец
+	ErrLastOwner = errors.New("workspace must keep at least one owner")
 )
 
 // ConflictError тип внутренней ошибки конфликта
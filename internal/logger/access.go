@@ -0,0 +1,132 @@
+// Журнал запросов HTTP
+package logger
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Форматы журнала запросов
+const (
+	AccessLogJSON     = "json"     // структурированная запись через логер запроса
+	AccessLogCombined = "combined" // Apache Combined Log Format
+)
+
+// combinedTimeFormat формат времени %t в Apache
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLog настройки журнала запросов
+type AccessLog struct {
+	Format      string    // json или combined
+	SampleEvery int       // писать каждый N-й успешный редирект, 0 и 1 - все
+	Out         io.Writer // вывод формата combined, nil - вывод логера
+}
+
+// accessSampler прореживает записи об успешных редиректах
+//
+// Редиректы - основной поток запросов, ошибки и остальные ответы пишутся всегда.
+type accessSampler struct {
+	every uint64
+	n     atomic.Uint64
+}
+
+// keep решает, писать ли запись для ответа с кодом status
+func (s *accessSampler) keep(status int) bool {
+	if s.every <= 1 || status != http.StatusTemporaryRedirect {
+		return true
+	}
+	return (s.n.Add(1)-1)%s.every == 0
+}
+
+// accessEntry поля записи журнала запросов
+type accessEntry struct {
+	remoteIP  string
+	userID    string
+	requestID string
+	method    string
+	uri       string
+	proto     string
+	status    int
+	size      int
+	referer   string
+	userAgent string
+	start     time.Time
+	duration  time.Duration
+}
+
+// newAccessEntry сбор записи по запросу и ответу
+func newAccessEntry(r *http.Request, rd *responseData, start time.Time) accessEntry {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return accessEntry{
+		remoteIP:  ip,
+		userID:    UserIDFromCtx(r.Context()),
+		requestID: RequestIDFromCtx(r.Context()),
+		method:    r.Method,
+		uri:       r.RequestURI,
+		proto:     r.Proto,
+		status:    rd.status,
+		size:      rd.size,
+		referer:   r.Referer(),
+		userAgent: r.UserAgent(),
+		start:     start,
+		duration:  time.Since(start),
+	}
+}
+
+// log запись в формате JSON, request_id добавляет логер запроса
+func (e accessEntry) log(l *zap.Logger) {
+	l.Sugar().Infow("request",
+		"remote_ip", e.remoteIP,
+		"uri", e.uri,
+		"method", e.method,
+		"proto", e.proto,
+		"status", e.status,
+		"duration", e.duration,
+		"size", e.size,
+		"referer", e.referer,
+		"user_agent", e.userAgent,
+		"user_id", e.userID,
+	)
+}
+
+// writeCombined запись в формате Combined, идентификатор запроса - последнее поле
+func writeCombined(w io.Writer, e accessEntry) {
+	size := "-"
+	if e.size > 0 {
+		size = strconv.Itoa(e.size)
+	}
+	_, _ = fmt.Fprintf(w, "%s - %s [%s] %s %d %s %s %s %s\n",
+		dash(e.remoteIP),
+		dash(e.userID),
+		e.start.Format(combinedTimeFormat),
+		strconv.Quote(e.method+" "+e.uri+" "+e.proto),
+		e.status,
+		size,
+		quoteField(e.referer),
+		quoteField(e.userAgent),
+		quoteField(e.requestID),
+	)
+}
+
+// dash значение поля или "-" для пустого
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// quoteField поле в кавычках с экранированием, пустое - "-"
+func quoteField(s string) string {
+	return strconv.Quote(dash(s))
+}
@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLoggingMWCombined(t *testing.T) {
+	var buf bytes.Buffer
+	l := ServerLogger{Logger: zap.NewNop()}
+	h := l.RequestIDMW(l.LoggingMW(AccessLog{Format: AccessLogCombined, Out: &buf})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WithUserID(r.Context(), "user1")
+			_, _ = w.Write([]byte("hello"))
+		})))
+
+	req := httptest.NewRequest(http.MethodGet, "/abc?x=1", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set(RequestIDHeader, "req-1")
+	req.Header.Set("User-Agent", "curl/8.0")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	re := regexp.MustCompile(`^10\.0\.0\.1 - user1 \[[^\]]+\] "GET /abc\?x=1 HTTP/1\.1" 200 5 "-" "curl/8\.0" "req-1"\n$`)
+	assert.Regexp(t, re, buf.String())
+}
+
+func TestLoggingMWSampling(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	l := ServerLogger{Logger: zap.New(core)}
+	h := l.LoggingMW(AccessLog{Format: AccessLogJSON, SampleEvery: 3})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			http.Redirect(w, r, "https://example.com", http.StatusTemporaryRedirect)
+		}))
+
+	for i := 0; i < 6; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc", nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	//Из шести редиректов пишется каждый третий, ошибка пишется всегда
+	entries := logs.All()
+	require.Len(t, entries, 3)
+	assert.EqualValues(t, http.StatusBadRequest, entries[2].ContextMap()["status"])
+}
+
+func TestLoggingMWPanic(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	l := ServerLogger{Logger: zap.New(core)}
+	h := l.LoggingMW(AccessLog{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "panic recovered", entries[0].Message)
+	assert.Equal(t, "boom", entries[0].ContextMap()["panic"])
+	assert.EqualValues(t, http.StatusInternalServerError, entries[1].ContextMap()["status"])
+}
@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Идентификатор запроса в заголовках HTTP и метаданных gRPC
+const (
+	RequestIDHeader  = "X-Request-ID" // заголовок HTTP
+	RequestIDMetaKey = "x-request-id" // ключ метаданных gRPC
+)
+
+// maxRequestIDLen ограничение длины идентификатора, пришедшего от клиента
+const maxRequestIDLen = 128
+
+// ctxKey тип ключей контекста пакета
+type ctxKey int
+
+// Ключи контекста
+const (
+	ctxRequestInfo ctxKey = iota // *requestInfo
+	ctxLogger                    // *zap.Logger запроса
+)
+
+// requestInfo данные запроса, которые дополняются по ходу цепочки middleware
+//
+// Middleware логирования создается раньше авторизации, поэтому пользователь
+// записывается в общий для запроса объект, а не только в новый контекст.
+type requestInfo struct {
+	mu        sync.Mutex
+	requestID string
+	userID    string
+}
+
+// NormalizeRequestID возвращает идентификатор клиента или генерирует новый
+//
+// Слишком длинные и содержащие управляющие символы значения заменяются.
+func NormalizeRequestID(id string) string {
+	if id == "" || len(id) > maxRequestIDLen {
+		return uuid.NewString()
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return uuid.NewString()
+		}
+	}
+	return id
+}
+
+// WithRequestID сохраняет идентификатор запроса и логер с ним в контексте
+func WithRequestID(ctx context.Context, base *zap.Logger, requestID string) context.Context {
+	ctx = context.WithValue(ctx, ctxRequestInfo, &requestInfo{requestID: requestID})
+	return context.WithValue(ctx, ctxLogger, base.With(zap.String("request_id", requestID)))
+}
+
+// WithUserID добавляет пользователя к логеру запроса
+func WithUserID(ctx context.Context, userID string) context.Context {
+	if info, ok := ctx.Value(ctxRequestInfo).(*requestInfo); ok {
+		info.mu.Lock()
+		info.userID = userID
+		info.mu.Unlock()
+	}
+	return context.WithValue(ctx, ctxLogger, FromCtx(ctx).With(zap.String("user_id", userID)))
+}
+
+// RequestIDFromCtx идентификатор запроса из контекста
+func RequestIDFromCtx(ctx context.Context) string {
+	if info, ok := ctx.Value(ctxRequestInfo).(*requestInfo); ok {
+		return info.requestID
+	}
+	return ""
+}
+
+// UserIDFromCtx пользователь запроса, известный после авторизации
+func UserIDFromCtx(ctx context.Context) string {
+	if info, ok := ctx.Value(ctxRequestInfo).(*requestInfo); ok {
+		info.mu.Lock()
+		defer info.mu.Unlock()
+		return info.userID
+	}
+	return ""
+}
+
+// FromCtx логер запроса, без него - глобальный логер zap
+func FromCtx(ctx context.Context) *zap.Logger {
+	return fromCtxOr(ctx, zap.L())
+}
+
+// fromCtxOr логер запроса, без него - base
+func fromCtxOr(ctx context.Context, base *zap.Logger) *zap.Logger {
+	if l, ok := ctx.Value(ctxLogger).(*zap.Logger); ok {
+		return l
+	}
+	return base
+}
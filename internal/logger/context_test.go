@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestIDMW(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	l := ServerLogger{Logger: zap.New(core)}
+	h := l.RequestIDMW(l.LoggingMW(AccessLog{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(WithUserID(r.Context(), "user1"))
+		FromCtx(r.Context()).Info("handler")
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, "req-1", rec.Header().Get(RequestIDHeader))
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		fields := e.ContextMap()
+		assert.Equal(t, "req-1", fields["request_id"])
+		assert.Equal(t, "user1", fields["user_id"])
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Len(t, rec.Header().Get(RequestIDHeader), 36)
+}
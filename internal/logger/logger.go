@@ -2,29 +2,105 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Форматы записи логов
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
 )
 
 // ServerLogger структура логера
 type ServerLogger struct {
-	Logger *zap.Logger //не хочу делать Sugar, но хочу использовать ниже.
+	Logger *zap.Logger     //не хочу делать Sugar, но хочу использовать ниже.
+	Level  zap.AtomicLevel //уровень, который можно менять во время работы
+	out    io.Closer       //файл логов, nil при выводе в stdout
+	w      io.Writer       //вывод логера для журнала запросов в текстовом формате
 }
 
-// CreateLogger функция создания с возможностью регулирования уровней
-func CreateLogger(level zap.AtomicLevel) *ServerLogger {
-	cfg := zap.NewProductionConfig()
-	cfg.Level = level
-	l, err := cfg.Build()
+// Options настройки логера
+type Options struct {
+	Level      string // уровень: debug, info, warn, error
+	Encoding   string // формат: json или console
+	File       string // файл логов, пустой путь - вывод в stdout
+	MaxSizeMB  int    // размер файла в мегабайтах, после которого он ротируется
+	MaxBackups int    // сколько ротированных файлов хранить, 0 - все
+}
 
+// NewLogger создание логера по настройкам
+//
+// Уровень хранится в Level и меняется без перезапуска, см. LevelHandler.
+func NewLogger(opts Options) (*ServerLogger, error) {
+	level, err := zap.ParseAtomicLevel(opts.Level)
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("bad log level: %w", err)
+	}
+	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	var enc zapcore.Encoder
+	switch opts.Encoding {
+	case EncodingJSON, "":
+		enc = zapcore.NewJSONEncoder(encCfg)
+	case EncodingConsole:
+		encCfg.EncodeLevel = zapcore.CapitalLevelEncoder
+		enc = zapcore.NewConsoleEncoder(encCfg)
+	default:
+		return nil, fmt.Errorf("unknown log encoding %q", opts.Encoding)
 	}
-	return &ServerLogger{
-		Logger: l,
+
+	sl := &ServerLogger{Level: level}
+	ws := zapcore.Lock(os.Stdout)
+	if opts.File != "" {
+		//lumberjack сам ротирует файл по размеру, запись в него потокобезопасна
+		lj := &lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    opts.MaxSizeMB,
+			MaxBackups: opts.MaxBackups,
+		}
+		ws = zapcore.AddSync(lj)
+		sl.out = lj
 	}
+	sl.w = ws
+	sl.Logger = zap.New(zapcore.NewCore(enc, ws, level),
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	)
+	return sl, nil
+}
+
+// LevelHandler обработчик просмотра (GET) и изменения (PUT) уровня логирования
+//
+// Формат тела: {"level":"debug"}.
+func (l *ServerLogger) LevelHandler() http.Handler {
+	return l.Level
+}
+
+// writer вывод логера, по умолчанию stdout
+func (l *ServerLogger) writer() io.Writer {
+	if l.w == nil {
+		return os.Stdout
+	}
+	return l.w
+}
+
+// Close сброс буфера и закрытие файла логов
+func (l *ServerLogger) Close() error {
+	//Sync для stdout на некоторых системах возвращает ошибку, ее не учитываем
+	_ = l.Logger.Sync()
+	if l.out != nil {
+		return l.out.Close()
+	}
+	return nil
 }
 
 // responseData тип для фиксации размера запроса
@@ -51,16 +127,29 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.responseData.status = statusCode
 }
 
+// RequestIDMW принимает X-Request-ID клиента или генерирует новый
+//
+// Идентификатор возвращается в ответе и попадает в логер запроса, см. FromCtx.
+func (l ServerLogger) RequestIDMW(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := NormalizeRequestID(r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), l.Logger, requestID)))
+	})
+}
+
 // LoggingMW функция middleware для внерения в роутер
-func (l ServerLogger) LoggingMW() func(http.Handler) http.Handler {
-	sl := *l.Logger.Sugar()
+//
+// Пишет журнал запросов в формате al.Format. Паника обработчика логируется
+// со стеком, клиент получает 500, если ответ еще не начат.
+func (l ServerLogger) LoggingMW(al AccessLog) func(http.Handler) http.Handler {
+	sampler := &accessSampler{every: uint64(al.SampleEvery)}
+	out := al.Out
+	if out == nil {
+		out = l.writer()
+	}
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, req *http.Request) {
-			defer func() {
-				if err := recover(); err != nil {
-					return
-				}
-			}()
 			responseData := &responseData{
 				size:   0,
 				status: 0,
@@ -70,15 +159,34 @@ func (l ServerLogger) LoggingMW() func(http.Handler) http.Handler {
 				responseData:   responseData,
 			}
 			start := time.Now()
+			defer func() {
+				if p := recover(); p != nil {
+					//Штатное прерывание ответа net/http обрабатывает сам
+					if p == http.ErrAbortHandler {
+						panic(p)
+					}
+					fromCtxOr(req.Context(), l.Logger).Error("panic recovered",
+						zap.Any("panic", p),
+						zap.Stack("stack"),
+					)
+					if responseData.status == 0 && responseData.size == 0 {
+						http.Error(&lw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					}
+				}
+				if responseData.status == 0 {
+					responseData.status = http.StatusOK
+				}
+				if !sampler.keep(responseData.status) {
+					return
+				}
+				entry := newAccessEntry(req, responseData, start)
+				if al.Format == AccessLogCombined {
+					writeCombined(out, entry)
+					return
+				}
+				entry.log(fromCtxOr(req.Context(), l.Logger))
+			}()
 			next.ServeHTTP(&lw, req)
-			duration := time.Since(start)
-			sl.Infoln(
-				"uri", req.RequestURI,
-				"method", req.Method,
-				"status", responseData.status,
-				"duration", duration,
-				"size", responseData.size,
-			)
 		}
 		return http.HandlerFunc(fn)
 	}
@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogger(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "shortener.log")
+	l, err := NewLogger(Options{Level: "info", Encoding: EncodingJSON, File: file, MaxSizeMB: 1})
+	require.NoError(t, err)
+
+	l.Logger.Debug("hidden")
+	l.Logger.Info("visible")
+
+	//Меняем уровень через обработчик служебного сервера
+	rec := httptest.NewRecorder()
+	l.LevelHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"level":"debug"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	l.Logger.Debug("debug enabled")
+
+	rec = httptest.NewRecorder()
+	l.LevelHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/loglevel", nil))
+	assert.JSONEq(t, `{"level":"debug"}`, rec.Body.String())
+	require.NoError(t, l.Close())
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "hidden")
+	assert.Contains(t, string(data), `"msg":"visible"`)
+	assert.Contains(t, string(data), `"msg":"debug enabled"`)
+}
+
+func TestNewLoggerBadOptions(t *testing.T) {
+	_, err := NewLogger(Options{Level: "loud"})
+	assert.Error(t, err)
+	_, err = NewLogger(Options{Level: "info", Encoding: "xml"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,201 @@
+// Package metrics содержит метрики сервиса в формате Prometheus.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace префикс всех метрик сервиса
+const namespace = "shortener"
+
+// Metrics набор метрик сервиса в собственном реестре
+type Metrics struct {
+	registry    *prometheus.Registry
+	httpLatency *prometheus.HistogramVec // HTTP запросы по маршруту, методу и статусу
+	grpcLatency *prometheus.HistogramVec // gRPC запросы по методу и коду
+	storageOps  *prometheus.HistogramVec // операции хранилища по имени и результату
+	deleteQueue prometheus.Gauge         // ключи в очереди на удаление
+	queued      atomic.Int64             // то же значение для проверки готовности
+}
+
+// PoolStatser источник статистики пула соединений с БД
+type PoolStatser interface {
+	PoolStats() sql.DBStats
+}
+
+// CacheStatser источник статистики кэша
+type CacheStatser interface {
+	CacheStats() (hits uint64, misses uint64)
+}
+
+// New создает метрики и регистрирует их вместе с метриками рантайма
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		grpcLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "grpc_request_duration_seconds",
+			Help:      "gRPC request latency by method and code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		storageOps: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Storage operation latency by operation and result.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "result"}),
+		deleteQueue: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "delete_queue_depth",
+			Help:      "URLs accepted for deletion and not yet processed by storage.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpLatency,
+		m.grpcLatency,
+		m.storageOps,
+		m.deleteQueue,
+	)
+	return m
+}
+
+// Handler обработчик /metrics в формате Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveHTTP учет HTTP запроса
+func (m *Metrics) ObserveHTTP(route, method string, status int, duration time.Duration) {
+	m.httpLatency.WithLabelValues(route, method, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// ObserveGRPC учет gRPC запроса
+func (m *Metrics) ObserveGRPC(method, code string, duration time.Duration) {
+	m.grpcLatency.WithLabelValues(method, code).Observe(duration.Seconds())
+}
+
+// DeleteQueueDepth число ключей в очереди на удаление
+func (m *Metrics) DeleteQueueDepth() int64 {
+	return m.queued.Load()
+}
+
+// observeStorage учет операции хранилища
+func (m *Metrics) observeStorage(operation string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.storageOps.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
+
+// RegisterPool регистрирует метрики пула соединений с БД
+func (m *Metrics) RegisterPool(src PoolStatser) {
+	gauge := func(name, help string, value func(s sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "db_pool",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(src.PoolStats()) })
+	}
+	counter := func(name, help string, value func(s sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "db_pool",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(src.PoolStats()) })
+	}
+	m.registry.MustRegister(
+		gauge("max_open_connections", "Maximum number of open connections.",
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }),
+		gauge("open_connections", "Established connections, in use and idle.",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+		gauge("in_use_connections", "Connections currently in use.",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }),
+		gauge("idle_connections", "Idle connections.",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }),
+		counter("wait_count_total", "Total number of connections waited for.",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+		counter("wait_duration_seconds_total", "Total time blocked waiting for a new connection.",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+	)
+}
+
+// RegisterCache регистрирует метрики кэша ссылок
+func (m *Metrics) RegisterCache(src CacheStatser) {
+	m.registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "url_cache_hits_total",
+			Help:      "URL cache hits.",
+		}, func() float64 {
+			hits, _ := src.CacheStats()
+			return float64(hits)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "url_cache_misses_total",
+			Help:      "URL cache misses.",
+		}, func() float64 {
+			_, misses := src.CacheStats()
+			return float64(misses)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "url_cache_hit_ratio",
+			Help:      "URL cache hit ratio since start.",
+		}, func() float64 {
+			hits, misses := src.CacheStats()
+			if hits+misses == 0 {
+				return 0
+			}
+			return float64(hits) / float64(hits+misses)
+		}),
+	)
+}
+
+// statusWriter фиксирует код ответа для метрик
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader фиксация кода ответа
+func (w *statusWriter) WriteHeader(statusCode int) {
+	w.status = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// HTTPMW middleware учета HTTP запросов
+//
+// Маршрут берется из шаблона chi, чтобы короткие ключи не раздували число рядов.
+func (m *Metrics) HTTPMW(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(sw, r)
+		route := "unknown"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		m.ObserveHTTP(route, r.Method, sw.status, time.Since(start))
+	})
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SversusN/shortener/internal/storage/dbstorage"
+	"github.com/SversusN/shortener/internal/storage/primitivestorage"
+)
+
+func TestHandler(t *testing.T) {
+	m := New()
+	s := NewStorage(primitivestorage.NewStorage(nil, errors.New("dont need file")), m)
+	_, err := s.SetURL(context.Background(), "sk", "http://example.com", dbstorage.Owner{UserID: "user"})
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(m.HTTPMW)
+	r.Get("/{shortKey}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/sk", nil))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(rec.Result().Body)
+	require.NoError(t, err)
+	defer rec.Result().Body.Close()
+
+	assert.Contains(t, string(body), `shortener_http_request_duration_seconds_count{method="GET",route="/{shortKey}",status="307"} 1`)
+	assert.Contains(t, string(body), `shortener_storage_operation_duration_seconds_count{operation="set_url",result="ok"} 1`)
+	assert.Contains(t, string(body), "shortener_delete_queue_depth 0")
+}
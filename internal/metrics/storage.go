@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+	"github.com/SversusN/shortener/internal/storage/storage"
+)
+
+// instrumentedStorage хранилище с учетом времени операций
+type instrumentedStorage struct {
+	s storage.Storage
+	m *Metrics
+}
+
+// instrumentedPinger хранилище с учетом времени операций и проверкой соединения
+type instrumentedPinger struct {
+	*instrumentedStorage
+	p storage.Pinger
+}
+
+// NewStorage оборачивает хранилище метриками
+//
+// Если хранилище поддерживает Ping, обертка тоже реализует storage.Pinger.
+func NewStorage(s storage.Storage, m *Metrics) storage.Storage {
+	is := &instrumentedStorage{s: s, m: m}
+	if p, ok := s.(storage.Pinger); ok {
+		return &instrumentedPinger{instrumentedStorage: is, p: p}
+	}
+	return is
+}
+
+// GetURL получение ссылки
+func (is *instrumentedStorage) GetURL(ctx context.Context, id string) (string, error) {
+	start := time.Now()
+	url, err := is.s.GetURL(ctx, id)
+	is.m.observeStorage("get_url", start, err)
+	return url, err
+}
+
+// SetURL сохранение ссылки
+func (is *instrumentedStorage) SetURL(ctx context.Context, id string, targetURL string, owner entity.Owner) (string, error) {
+	start := time.Now()
+	result, err := is.s.SetURL(ctx, id, targetURL, owner)
+	is.m.observeStorage("set_url", start, err)
+	return result, err
+}
+
+// SetURLBatch пакетное сохранение ссылок
+func (is *instrumentedStorage) SetURLBatch(ctx context.Context, u map[string]entity.UserURL) (map[string]entity.UserURL, error) {
+	start := time.Now()
+	result, err := is.s.SetURLBatch(ctx, u)
+	is.m.observeStorage("set_url_batch", start, err)
+	return result, err
+}
+
+// GetUserUrls получение ссылок пользователя
+func (is *instrumentedStorage) GetUserUrls(ctx context.Context, owner entity.Owner) (any, error) {
+	start := time.Now()
+	result, err := is.s.GetUserUrls(ctx, owner)
+	is.m.observeStorage("get_user_urls", start, err)
+	return result, err
+}
+
+// DeleteUserURLs удаление ссылок с учетом глубины очереди
+//
+// Ключ считается в очереди с момента получения до закрытия пакета удаления,
+// после которого хранилище выполняет удаление.
+func (is *instrumentedStorage) DeleteUserURLs(ctx context.Context, owner entity.Owner, group *sync.WaitGroup) (chan string, error) {
+	start := time.Now()
+	inner, err := is.s.DeleteUserURLs(ctx, owner, group)
+	is.m.observeStorage("delete_user_urls", start, err)
+	if err != nil {
+		return inner, err
+	}
+	outer := make(chan string)
+	group.Add(1)
+	go func() {
+		defer group.Done()
+		var queued int
+		for key := range outer {
+			is.m.deleteQueue.Inc()
+			is.m.queued.Add(1)
+			queued++
+			inner <- key
+		}
+		close(inner)
+		is.m.deleteQueue.Sub(float64(queued))
+		is.m.queued.Add(-int64(queued))
+	}()
+	return outer, nil
+}
+
+// GetStats статистика хранилища
+func (is *instrumentedStorage) GetStats(ctx context.Context) (entity.Stats, error) {
+	start := time.Now()
+	stats, err := is.s.GetStats(ctx)
+	is.m.observeStorage("get_stats", start, err)
+	return stats, err
+}
+
+// GetTimeSeries временной ряд статистики
+func (is *instrumentedStorage) GetTimeSeries(ctx context.Context, from, to time.Time, interval time.Duration) ([]entity.TimeBucket, error) {
+	start := time.Now()
+	buckets, err := is.s.GetTimeSeries(ctx, from, to, interval)
+	is.m.observeStorage("get_time_series", start, err)
+	return buckets, err
+}
+
+// Ping проверка соединения
+func (ip *instrumentedPinger) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := ip.p.Ping(ctx)
+	ip.m.observeStorage("ping", start, err)
+	return err
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// AdminAuthMW проверяет Basic авторизацию или Bearer токен служебного сервера
+//
+// Пустые user/password или token выключают соответствующий способ.
+func AdminAuthMW(user, password, token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token != "" {
+				if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secureEqual(bearer, token) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			if user != "" && password != "" {
+				if u, p, ok := r.BasicAuth(); ok && secureEqual(u, user) && secureEqual(p, password) {
+					next.ServeHTTP(w, r)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", `Basic realm="debug", charset="UTF-8"`)
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		})
+	}
+}
+
+// secureEqual сравнение за постоянное время, в том числе для строк разной длины
+func secureEqual(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
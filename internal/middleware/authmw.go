@@ -1,109 +1,230 @@
 package middleware
 
 import (
-	"context"
 	"errors"
-	"log"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 
-	"github.com/SversusN/shortener/internal/storage/storage"
+	"github.com/SversusN/shortener/internal/apikeys"
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/logger"
 )
 
-// Константные настройки
-const (
-	TokenExp           = time.Minute * 180 //Время жизни токена
-	SecretKey          = "secret"          // секретный ключ
-	NameCookie         = "Token"           // наименование куки в запросе
-	CtxUser    ctxUser = "UserID"          //поле ид пользователя в куки
-)
+// NameCookie наименование куки с токеном в запросе
+const NameCookie = "Token"
 
-// ctxUser требуется для передачи пользователя в контексте
-type ctxUser string
+// CookieOptions атрибуты куки с токеном, HttpOnly ставится всегда
+type CookieOptions struct {
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
 
-// Claims тиа для указания UserID
-type Claims struct {
-	jwt.RegisteredClaims
-	UserID string
+// ParseSameSite значение SameSite из конфигурации: lax, strict или none
+func ParseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(s) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("unknown SameSite mode %q", s)
 }
 
 // AuthMW структура middleware авторизации
 type AuthMW struct {
-	db storage.Storage
+	tokens *auth.Manager
+	keys   *apikeys.Service
+	cookie CookieOptions
 }
 
 // NewAuthMW конструктор объекта авторизации
-func NewAuthMW() *AuthMW {
-	return &AuthMW{}
+//
+// keys может быть nil, тогда ключи API не принимаются.
+func NewAuthMW(tokens *auth.Manager, keys *apikeys.Service, cookie CookieOptions) *AuthMW {
+	return &AuthMW{tokens: tokens, keys: keys, cookie: cookie}
 }
 
-// BuildNewToken функция генерации токена новому пользователю
-func BuildNewToken(userID string) (string, error) {
-	claims := Claims{RegisteredClaims: jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExp)),
-	},
-		UserID: userID,
+// TokenFromRequest токен из куки, для клиентов API - из заголовка Authorization, как в gRPC
+func TokenFromRequest(r *http.Request) (token string, fromCookie bool) {
+	if cookie, err := r.Cookie(NameCookie); err == nil {
+		return cookie.Value, true
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	stringToken, err := token.SignedString([]byte(SecretKey))
-	if err != nil {
-		return "", errors.New("error signing token")
-	}
-	return stringToken, nil
+	return auth.BearerToken(r.Header.Get("Authorization")), false
 }
 
-// GetUserID получение ИД пользователя из токена
-func GetUserID(tokenString string) (string, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims,
-		func(t *jwt.Token) (interface{}, error) {
-			return []byte(SecretKey), nil
-		})
-	if err != nil {
-		return "", errors.New("error parsing token")
+// apiKeyFromRequest ключ API из заголовка X-API-Key или Authorization: Bearer sk_...
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get(apikeys.Header); key != "" {
+		return key
 	}
-
-	if !token.Valid {
-		return "", errors.New("invalid token")
+	if token := auth.BearerToken(r.Header.Get("Authorization")); apikeys.IsAPIKey(token) {
+		return token
 	}
-
-	return claims.UserID, nil
+	return ""
 }
 
 // AuthMWfunc Функция аутентифкации
+//
+// Ключ API проверяется раньше токена и ограничивает запрос своими областями доступа.
+// Истекший токен с верной подписью в пределах окна продления заменяется новым
+// с тем же пользователем и его текущей ролью, остальные невалидные токены отклоняются, а кука сбрасывается.
+// Роль действующего токена тоже сверяется с текущей, заблокированный пользователь получает 403.
 func (a AuthMW) AuthMWfunc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie(NameCookie)
-		if err == nil {
-			userID, err := GetUserID(cookie.Value)
-			if userID != "" && err == nil {
-				ctx := context.WithValue(r.Context(), CtxUser, userID)
-				next.ServeHTTP(w, r.WithContext(ctx))
+		if raw := apiKeyFromRequest(r); raw != "" && a.keys != nil {
+			key, err := a.keys.Authenticate(r.Context(), raw)
+			if errors.Is(err, apikeys.ErrInvalidKey) {
+				http.Error(w, "invalid api key", http.StatusUnauthorized)
 				return
-			} else {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte("pls, clear cookie data"))
+			}
+			if err != nil {
+				logger.FromCtx(r.Context()).Error("api key check failed", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
+			ctx := auth.WithAPIKeyID(auth.WithScopes(auth.WithUserID(r.Context(), key.UserID), key.Scopes), key.ID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		token, fromCookie := TokenFromRequest(r)
+		if token != "" {
+			claims, err := a.tokens.CurrentClaims(r.Context(), token)
+			if err == nil {
+				next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+				return
+			}
+			if !errors.Is(err, auth.ErrInvalidToken) && !errors.Is(err, auth.ErrUserDisabled) {
+				logger.FromCtx(r.Context()).Error("user access check failed", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if errors.Is(err, auth.ErrInvalidToken) {
+				claims, token, err = a.tokens.Refresh(r.Context(), token)
+			}
+			if err == nil {
+				logger.FromCtx(r.Context()).Debug("token refreshed", zap.String("user_id", claims.UserID))
+				a.setToken(w, token, fromCookie)
+				next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+				return
+			}
+			if fromCookie {
+				a.ClearCookie(w)
+			}
+			if errors.Is(err, auth.ErrUserDisabled) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("pls, clear cookie data"))
+			return
 		}
 		//ИД пользователя если обращение происходит первый раз (uuid)
 		userID := uuid.NewString()
-		token, err := BuildNewToken(userID)
+		token, err := a.tokens.BuildNewToken(userID)
 		if err != nil {
-			log.Print("err while building new token")
+			logger.FromCtx(r.Context()).Error("err while building new token", zap.Error(err))
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		http.SetCookie(w, &http.Cookie{
-			Name:  NameCookie,
-			Value: token,
+		a.setToken(w, token, true)
+		next.ServeHTTP(w, r.WithContext(auth.WithUserID(r.Context(), userID)))
+	})
+}
+
+// setToken выдача токена клиенту: в куке или, для клиентов API, в заголовке Authorization
+func (a AuthMW) setToken(w http.ResponseWriter, token string, cookie bool) {
+	if !cookie {
+		w.Header().Set("Authorization", "Bearer "+token)
+		return
+	}
+	lifetime := a.tokens.CookieLifetime()
+	c := a.newCookie(token)
+	c.MaxAge = int(lifetime.Seconds())
+	c.Expires = time.Now().Add(lifetime)
+	http.SetCookie(w, c)
+}
+
+// SetCookie выдача токена в куке, например после входа по паролю
+func (a AuthMW) SetCookie(w http.ResponseWriter, token string) {
+	a.setToken(w, token, true)
+}
+
+// SetFlowCookie короткоживущая кука процесса входа с атрибутами куки токена, ttl 0 - удаление
+//
+// SameSite=Lax обязателен: кука должна прийти при возврате пользователя со стороннего сайта.
+func (a AuthMW) SetFlowCookie(w http.ResponseWriter, name, value string, ttl time.Duration) {
+	c := a.newCookie(value)
+	c.Name = name
+	c.SameSite = http.SameSiteLaxMode
+	c.MaxAge = int(ttl.Seconds())
+	if ttl == 0 {
+		c.MaxAge = -1
+	}
+	http.SetCookie(w, c)
+}
+
+// ClearCookie удаление куки с токеном
+func (a AuthMW) ClearCookie(w http.ResponseWriter) {
+	c := a.newCookie("")
+	c.MaxAge = -1
+	http.SetCookie(w, c)
+}
+
+// newCookie кука с токеном и атрибутами из конфигурации
+func (a AuthMW) newCookie(token string) *http.Cookie {
+	return &http.Cookie{
+		Name:     NameCookie,
+		Value:    token,
+		Path:     a.cookie.Path,
+		Domain:   a.cookie.Domain,
+		Secure:   a.cookie.Secure,
+		HttpOnly: true,
+		SameSite: a.cookie.SameSite,
+	}
+}
+
+// RequireScope доступ по ключу API только с областью scope, токены пользователя не ограничиваются
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.HasScope(r.Context(), scope) {
+				http.Error(w, "api key has no scope "+scope, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission доступ только пользователям, чья роль дает право perm
+func RequirePermission(perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.Can(r.Context(), perm) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
 		})
-		ctx := context.WithValue(r.Context(), CtxUser, userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// RequireSession доступ только по токену пользователя, например для управления ключами API
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth.IsAPIKey(r.Context()) {
+			http.Error(w, "api keys are not allowed here", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SversusN/shortener/internal/auth"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// newTestAuth middleware и обработчик, возвращающий пользователя запроса
+func newTestAuth(t *testing.T, grace time.Duration) http.Handler {
+	tokens, err := auth.New(auth.Options{Secret: testSecret, TTL: time.Hour, Grace: grace})
+	require.NoError(t, err)
+	cookie := CookieOptions{Path: "/", Secure: true, SameSite: http.SameSiteStrictMode}
+	return NewAuthMW(tokens, nil, cookie).AuthMWfunc(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := auth.UserIDFromCtx(r.Context())
+		_, _ = w.Write([]byte(userID))
+	}))
+}
+
+// expiredToken токен с истекшим сроком и верной подписью
+func expiredToken(t *testing.T, userID string) string {
+	//Срок округляется до секунд вниз, токен с наносекундным сроком истекает сразу
+	issuer, err := auth.New(auth.Options{Secret: testSecret, TTL: time.Nanosecond})
+	require.NoError(t, err)
+	token, err := issuer.BuildNewToken(userID)
+	require.NoError(t, err)
+	return token
+}
+
+func TestAuthMWCookie(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestAuth(t, time.Hour).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	res := rec.Result()
+	defer res.Body.Close()
+	cookies := res.Cookies()
+	require.Len(t, cookies, 1)
+	c := cookies[0]
+	assert.Equal(t, NameCookie, c.Name)
+	assert.True(t, c.HttpOnly)
+	assert.True(t, c.Secure)
+	assert.Equal(t, http.SameSiteStrictMode, c.SameSite)
+	assert.Equal(t, "/", c.Path)
+	//Кука живет до конца окна продления
+	assert.Equal(t, int((2 * time.Hour).Seconds()), c.MaxAge)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), c.Expires, time.Minute)
+}
+
+func TestAuthMWRefresh(t *testing.T) {
+	token := expiredToken(t, "user1")
+
+	//Истекший токен в куке заменяется новым с тем же пользователем
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: NameCookie, Value: token})
+	rec := httptest.NewRecorder()
+	newTestAuth(t, time.Hour).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "user1", rec.Body.String())
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.NotEqual(t, token, cookies[0].Value)
+
+	//Клиенту API новый токен приходит в заголовке
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	newTestAuth(t, time.Hour).ServeHTTP(rec, req)
+	assert.Equal(t, "user1", rec.Body.String())
+	assert.NotEmpty(t, auth.BearerToken(rec.Header().Get("Authorization")))
+
+	//Вне окна продления кука сбрасывается
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: NameCookie, Value: token})
+	rec = httptest.NewRecorder()
+	newTestAuth(t, 0).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	cookies = rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, -1, cookies[0].MaxAge)
+}
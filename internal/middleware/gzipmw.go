@@ -5,9 +5,12 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/SversusN/shortener/internal/logger"
 )
 
 // GzipResponseWriter тип объекта lkz ьшввдуцфку
@@ -54,7 +57,7 @@ func GzipMiddleware(next http.Handler) http.Handler {
 			defer func(gzipWriter *gzip.Writer) {
 				err := gzipWriter.Close()
 				if err != nil {
-					log.Println("Error closing gzip writer")
+					logger.FromCtx(r.Context()).Error("Error closing gzip writer", zap.Error(err))
 				}
 			}(gzipWriter)
 
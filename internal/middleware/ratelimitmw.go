@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/SversusN/shortener/internal/clientip"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/ratelimit"
+)
+
+// RateLimit ограничение частоты действия action для клиента запроса
+//
+// Подключается после AuthMWfunc: клиент определяется по ключу API или пользователю.
+// При превышении отвечает 429 с Retry-After, при ошибке хранилища корзин запрос пропускается.
+func RateLimit(l *ratelimit.Limiter, ips *clientip.Resolver, action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, wait, err := l.Allow(r.Context(), action, ratelimit.ClientKey(r.Context(), ips.FromRequest(r)))
+			if err != nil {
+				logger.FromCtx(r.Context()).Error("rate limit check failed", zap.Error(err))
+			}
+			if err == nil && !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(wait)))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
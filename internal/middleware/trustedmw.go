@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/SversusN/shortener/internal/clientip"
+)
+
+// TrustedSubnetMW пропускает только запросы, пришедшие из доверенных подсетей
+//
+// Адрес клиента определяет gate: заголовки учитываются только от доверенных прокси.
+func TrustedSubnetMW(gate *clientip.Gate) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !gate.AllowRequest(r) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
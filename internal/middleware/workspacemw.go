@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/logger"
+	"github.com/SversusN/shortener/internal/workspaces"
+)
+
+// WorkspaceMW выбор активного пространства по заголовку X-Workspace-ID
+//
+// Подключается после AuthMWfunc: участие проверяется для пользователя запроса.
+// Без заголовка запрос работает с личными ссылками пользователя.
+func WorkspaceMW(ws *workspaces.Service) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			workspaceID := r.Header.Get(workspaces.Header)
+			if workspaceID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			userID, err := auth.UserIDFromCtx(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			member, err := ws.Resolve(r.Context(), userID, workspaceID)
+			switch {
+			case errors.Is(err, workspaces.ErrNotMember):
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			case err != nil:
+				logger.FromCtx(r.Context()).Error("workspace lookup failed", zap.Error(err))
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(workspaces.WithMember(r.Context(), member)))
+		})
+	}
+}
+
+// RequireWorkspaceWrite создание и удаление ссылок пространства только для owner и editor
+func RequireWorkspaceWrite(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !workspaces.CanWrite(r.Context()) {
+			http.Error(w, "workspace viewers cannot change links", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
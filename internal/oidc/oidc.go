@@ -0,0 +1,310 @@
+// Package oidc вход через OpenID Connect: authorization code с PKCE.
+//
+// Адреса провайдера берутся из документа discovery издателя, подпись ID токена
+// проверяется ключами из его JWKS.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/SversusN/shortener/internal/auth"
+)
+
+// Ограничения обращений к провайдеру
+const (
+	requestTimeout    = 10 * time.Second
+	maxResponseSize   = 1 << 20
+	jwksRefreshPeriod = 10 * time.Second // не чаще для неизвестного kid
+)
+
+// signingMethods алгоритмы ID токена, симметричные не принимаются
+var signingMethods = []string{auth.AlgRS256, "ES256", auth.AlgEdDSA}
+
+// ErrInvalidToken ID токен не прошел проверку
+var ErrInvalidToken = errors.New("oidc: invalid id token")
+
+// Config клиент у провайдера OpenID Connect
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // пусто для публичного клиента, PKCE используется всегда
+	RedirectURL  string
+	Scopes       []string // кроме openid
+}
+
+// Claims утверждения ID токена
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce string `json:"nonce"`
+	Email string `json:"email,omitempty"`
+}
+
+// metadata документ discovery издателя
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider провайдер OpenID Connect
+//
+// Документ discovery читается при первом входе и кэшируется, поэтому
+// недоступный при старте провайдер не мешает запуску сервиса.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu     sync.Mutex
+	meta   *metadata
+	keys   map[string]crypto.PublicKey
+	keysAt time.Time
+}
+
+// New создает провайдер, client nil - клиент с таймаутом по умолчанию
+func New(cfg Config, client *http.Client) (*Provider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("oidc: issuer, client id and redirect url are required")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	return &Provider{cfg: cfg, client: client}, nil
+}
+
+// Issuer издатель, субъекты уникальны в его пределах
+func (p *Provider) Issuer() string {
+	return p.cfg.Issuer
+}
+
+// RandomString случайное значение для state, nonce и code_verifier
+func RandomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// challenge code_challenge метода S256
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL адрес входа у провайдера
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("oidc: bad authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange обмен кода авторизации на ID токен
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {p.cfg.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	var res struct {
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	status, err := p.do(req, &res)
+	if err != nil {
+		return "", fmt.Errorf("oidc: token request: %w", err)
+	}
+	if status != http.StatusOK || res.Error != "" {
+		return "", fmt.Errorf("oidc: token request: status %d: %s %s", status, res.Error, res.Description)
+	}
+	if res.IDToken == "" {
+		return "", errors.New("oidc: token response has no id_token")
+	}
+	return res.IDToken, nil
+}
+
+// Verify проверка подписи, издателя, получателя, срока и nonce ID токена
+func (p *Provider) Verify(ctx context.Context, raw, nonce string) (Claims, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+	var claims Claims
+	_, err = jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := p.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if !keyMatches(key, t.Method.Alg()) {
+			return nil, fmt.Errorf("oidc: key %s does not match %s", kid, t.Method.Alg())
+		}
+		return key, nil
+	}, jwt.WithValidMethods(signingMethods))
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	switch {
+	case claims.Issuer != meta.Issuer:
+		return Claims{}, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Issuer)
+	case !claims.VerifyAudience(p.cfg.ClientID, true):
+		return Claims{}, fmt.Errorf("%w: token is not issued for this client", ErrInvalidToken)
+	case claims.ExpiresAt == nil:
+		return Claims{}, fmt.Errorf("%w: no expiration", ErrInvalidToken)
+	case claims.Subject == "":
+		return Claims{}, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// keyMatches тип ключа соответствует алгоритму токена
+func keyMatches(key crypto.PublicKey, alg string) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return alg == auth.AlgRS256
+	case *ecdsa.PublicKey:
+		return alg == "ES256"
+	case ed25519.PublicKey:
+		return alg == auth.AlgEdDSA
+	}
+	return false
+}
+
+// discover документ discovery, читается до первого успеха
+func (p *Provider) discover(ctx context.Context) (metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return *p.meta, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return metadata{}, err
+	}
+	var meta metadata
+	status, err := p.do(req, &meta)
+	if err != nil {
+		return metadata{}, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	if status != http.StatusOK {
+		return metadata{}, fmt.Errorf("oidc: discovery: status %d", status)
+	}
+	//Издатель в документе должен совпадать с настроенным, иначе токены подписывает не тот, кого ждем
+	if meta.Issuer != p.cfg.Issuer {
+		return metadata{}, fmt.Errorf("oidc: discovery issuer %q does not match %q", meta.Issuer, p.cfg.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return metadata{}, errors.New("oidc: discovery document is incomplete")
+	}
+	p.meta = &meta
+	return meta, nil
+}
+
+// key открытый ключ издателя по kid
+//
+// Неизвестный kid перечитывает JWKS: провайдер мог сменить ключи.
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysAt) < jwksRefreshPeriod {
+		return nil, fmt.Errorf("oidc: unknown key %q", kid)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.meta.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set auth.JWKSet
+	status, err := p.do(req, &set)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: jwks: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: jwks: status %d", status)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		//Ключи неподдерживаемых типов пропускаются, токены ими не проверить
+		if key, err := jwk.PublicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+	p.keys, p.keysAt = keys, time.Now()
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("oidc: unknown key %q", kid)
+	}
+	return key, nil
+}
+
+// do запрос к провайдеру с ответом JSON
+func (p *Provider) do(req *http.Request, v any) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("bad response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,103 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SversusN/shortener/internal/oidc"
+	"github.com/SversusN/shortener/internal/oidc/oidctest"
+)
+
+// authorize вход у провайдера без браузера, возвращает код авторизации
+func authorize(t *testing.T, p *oidc.Provider, state, nonce, verifier string) string {
+	t.Helper()
+	target, err := p.AuthCodeURL(context.Background(), state, nonce, verifier)
+	require.NoError(t, err)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(target)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	back, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, state, back.Query().Get("state"))
+	return back.Query().Get("code")
+}
+
+func TestProvider(t *testing.T) {
+	ctx := context.Background()
+	idp := oidctest.New(t, "shortener", "secret")
+	p, err := oidc.New(oidc.Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     "shortener",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/api/auth/oidc/callback",
+	}, nil)
+	require.NoError(t, err)
+
+	//Полный вход: код обменивается только с верным code_verifier и один раз
+	idp.SetSubject("alice")
+	code := authorize(t, p, "state", "nonce", "verifier-verifier-verifier-verifier-verif")
+	_, err = p.Exchange(ctx, code, "wrong-verifier-wrong-verifier-wrong-verifi")
+	assert.Error(t, err)
+	code = authorize(t, p, "state", "nonce", "verifier-verifier-verifier-verifier-verif")
+	raw, err := p.Exchange(ctx, code, "verifier-verifier-verifier-verifier-verif")
+	require.NoError(t, err)
+	_, err = p.Exchange(ctx, code, "verifier-verifier-verifier-verifier-verif")
+	assert.Error(t, err)
+	claims, err := p.Verify(ctx, raw, "nonce")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", claims.Subject)
+	_, err = p.Verify(ctx, raw, "other nonce")
+	assert.ErrorIs(t, err, oidc.ErrInvalidToken)
+
+	//Токены чужого клиента, издателя, просроченные и без подписи провайдера отклоняются
+	valid := func() oidc.Claims {
+		return oidc.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    idp.Issuer(),
+				Subject:   "alice",
+				Audience:  jwt.ClaimStrings{"shortener"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Nonce: "nonce",
+		}
+	}
+	_, err = p.Verify(ctx, idp.Sign(t, valid()), "nonce")
+	require.NoError(t, err)
+	for name, mutate := range map[string]func(c *oidc.Claims){
+		"audience": func(c *oidc.Claims) { c.Audience = jwt.ClaimStrings{"other"} },
+		"issuer":   func(c *oidc.Claims) { c.Issuer = "https://evil.example.com" },
+		"expired":  func(c *oidc.Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) },
+		"no exp":   func(c *oidc.Claims) { c.ExpiresAt = nil },
+		"subject":  func(c *oidc.Claims) { c.Subject = "" },
+	} {
+		c := valid()
+		mutate(&c)
+		_, err = p.Verify(ctx, idp.Sign(t, c), "nonce")
+		assert.ErrorIs(t, err, oidc.ErrInvalidToken, name)
+	}
+	hs, err := jwt.NewWithClaims(jwt.SigningMethodHS256, valid()).SignedString([]byte("shared secret"))
+	require.NoError(t, err)
+	_, err = p.Verify(ctx, hs, "nonce")
+	assert.ErrorIs(t, err, oidc.ErrInvalidToken)
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := oidctest.New(t, "shortener", "")
+	p, err := oidc.New(oidc.Config{
+		Issuer:      idp.Issuer() + "/",
+		ClientID:    "shortener",
+		RedirectURL: "http://localhost/api/auth/oidc/callback",
+	}, nil)
+	require.NoError(t, err)
+	_, err = p.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	assert.ErrorContains(t, err, "does not match")
+}
@@ -0,0 +1,195 @@
+// Package oidctest поддельный провайдер OpenID Connect для тестов входа.
+//
+// Провайдер сразу одобряет вход субъектом, заданным SetSubject, и проверяет
+// клиента, redirect_uri и PKCE так же строго, как настоящий.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/oidc"
+)
+
+// keyID kid ключа подписи провайдера
+const keyID = "idp-key"
+
+// IdP поддельный провайдер, издатель - адрес Server.URL
+type IdP struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+	key          *rsa.PrivateKey
+
+	mu      sync.Mutex
+	subject string
+	grants  map[string]grant
+}
+
+// grant выданный код авторизации
+type grant struct {
+	challenge, nonce, redirectURI, subject string
+}
+
+// New запускает провайдер на время теста
+func New(t testing.TB, clientID, clientSecret string) *IdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &IdP{ClientID: clientID, ClientSecret: clientSecret, key: key, subject: "user-1", grants: make(map[string]grant)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/jwks", idp.jwks)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Server.Close)
+	return idp
+}
+
+// Issuer издатель токенов
+func (idp *IdP) Issuer() string {
+	return idp.Server.URL
+}
+
+// SetSubject субъект следующих входов
+func (idp *IdP) SetSubject(subject string) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.subject = subject
+}
+
+// Sign ID токен с произвольными утверждениями, подписанный ключом провайдера
+func (idp *IdP) Sign(t testing.TB, claims jwt.Claims) string {
+	t.Helper()
+	signed, err := idp.sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// discovery документ discovery
+func (idp *IdP) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 idp.Issuer(),
+		"authorization_endpoint": idp.Issuer() + "/authorize",
+		"token_endpoint":         idp.Issuer() + "/token",
+		"jwks_uri":               idp.Issuer() + "/jwks",
+	})
+}
+
+// authorize одобрение входа и возврат кода на redirect_uri
+func (idp *IdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != idp.ClientID ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	code, err := oidc.RandomString()
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+	idp.mu.Lock()
+	idp.grants[code] = grant{
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		redirectURI: q.Get("redirect_uri"),
+		subject:     idp.subject,
+	}
+	idp.mu.Unlock()
+	back := redirect.Query()
+	back.Set("code", code)
+	back.Set("state", q.Get("state"))
+	redirect.RawQuery = back.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token обмен одноразового кода на ID токен с проверкой клиента и PKCE
+func (idp *IdP) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	id, secret, _ := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if id != idp.ClientID || secret != idp.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	idp.mu.Lock()
+	g, ok := idp.grants[r.PostForm.Get("code")]
+	delete(idp.grants, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || g.redirectURI != r.PostForm.Get("redirect_uri") || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	now := time.Now()
+	idToken, err := idp.sign(oidc.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    idp.Issuer(),
+			Subject:   g.subject,
+			Audience:  jwt.ClaimStrings{idp.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+		Nonce: g.nonce,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"token_type": "Bearer", "id_token": idToken})
+}
+
+// sign подпись токена ключом провайдера
+func (idp *IdP) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	return token.SignedString(idp.key)
+}
+
+// jwks открытый ключ провайдера
+func (idp *IdP) jwks(w http.ResponseWriter, _ *http.Request) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	writeJSON(w, http.StatusOK, auth.JWKSet{Keys: []auth.JWK{{
+		KeyType:   "RSA",
+		KeyID:     keyID,
+		Algorithm: auth.AlgRS256,
+		Use:       "sig",
+		N:         b64(idp.key.N.Bytes()),
+		E:         b64(big.NewInt(int64(idp.key.E)).Bytes()),
+	}}})
+}
+
+// writeJSON ответ в формате JSON
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -49,6 +49,12 @@ func (fh FileHelper) WriteFile(uuid int, shortURL string, userURL entity.UserURL
 	}
 }
 
+// Check проверка, что файл хранения на месте и доступен
+func (fh FileHelper) Check() error {
+	_, err := os.Stat(fh.file.Name())
+	return err
+}
+
 // RMFile Перезаписываем файл после того как отработает удаление
 func (fh FileHelper) RMFile(data *sync.Map) error {
 	err := os.Truncate(fh.file.Name(), 0)
@@ -0,0 +1,78 @@
+// Package quota ограничивает ссылки пользователей: число активных ссылок,
+// размер пакета и длину ссылки.
+//
+// Квоты проверяются оберткой хранилища, поэтому одинаково действуют для HTTP и gRPC.
+// Проверка числа ссылок не атомарна с сохранением: параллельные запросы могут
+// ненадолго превысить лимит на несколько ссылок.
+package quota
+
+import (
+	"context"
+
+	"github.com/SversusN/shortener/internal/internalerrors"
+	"github.com/SversusN/shortener/internal/storage/storage"
+)
+
+// Limits квоты, 0 - без ограничения
+type Limits struct {
+	MaxLinks     int // активных ссылок на пользователя
+	MaxBatchSize int // ссылок в одном пакетном запросе
+	MaxURLLength int // длина оригинальной ссылки в байтах
+}
+
+// Usage использование квот пользователем
+type Usage struct {
+	Limits
+	Links int // активные ссылки пользователя
+}
+
+// Service проверка квот
+type Service struct {
+	store  storage.QuotaStore
+	limits Limits
+}
+
+// New создает сервис квот
+func New(store storage.QuotaStore, limits Limits) *Service {
+	return &Service{store: store, limits: limits}
+}
+
+// Usage текущее использование квот пользователем
+func (s *Service) Usage(ctx context.Context, userID string) (Usage, error) {
+	links, err := s.store.CountUserURLs(ctx, userID)
+	if err != nil {
+		return Usage{}, err
+	}
+	return Usage{Limits: s.limits, Links: links}, nil
+}
+
+// CheckURL длина ссылки в пределах квоты
+func (s *Service) CheckURL(url string) error {
+	if s.limits.MaxURLLength > 0 && len(url) > s.limits.MaxURLLength {
+		return internalerrors.ErrURLTooLong
+	}
+	return nil
+}
+
+// CheckBatch размер пакета в пределах квоты
+func (s *Service) CheckBatch(n int) error {
+	if s.limits.MaxBatchSize > 0 && n > s.limits.MaxBatchSize {
+		return internalerrors.ErrBatchTooLarge
+	}
+	return nil
+}
+
+// CheckLinks у пользователя есть место еще на n активных ссылок
+func (s *Service) CheckLinks(ctx context.Context, userID string, n int) error {
+	if s.limits.MaxLinks <= 0 {
+		return nil
+	}
+	links, err := s.store.CountUserURLs(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if links+n > s.limits.MaxLinks {
+		return internalerrors.ErrQuotaExceeded
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SversusN/shortener/internal/internalerrors"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+	"github.com/SversusN/shortener/internal/storage/primitivestorage"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	ms := primitivestorage.NewStorage(nil, errors.New("no file"))
+	q := New(ms, Limits{MaxLinks: 3, MaxBatchSize: 2, MaxURLLength: 30})
+	s := NewStorage(ms, q)
+	owner := entity.Owner{UserID: "alice"}
+
+	_, err := s.SetURL(ctx, "a1", "https://example.com/1", owner)
+	require.NoError(t, err)
+	_, err = s.SetURL(ctx, "a2", "https://example.com/"+strings.Repeat("x", 30), owner)
+	assert.ErrorIs(t, err, internalerrors.ErrURLTooLong)
+
+	batch := func(keys ...string) map[string]entity.UserURL {
+		u := make(map[string]entity.UserURL)
+		for _, k := range keys {
+			u[k] = entity.UserURL{UserID: "alice", OriginalURL: "https://example.com/" + k}
+		}
+		return u
+	}
+	_, err = s.SetURLBatch(ctx, batch("b1", "b2", "b3"))
+	assert.ErrorIs(t, err, internalerrors.ErrBatchTooLarge)
+	_, err = s.SetURLBatch(ctx, batch("b1", "b2"))
+	require.NoError(t, err)
+
+	//Лимит активных ссылок исчерпан, у другого пользователя свой лимит
+	_, err = s.SetURL(ctx, "a3", "https://example.com/3", owner)
+	assert.ErrorIs(t, err, internalerrors.ErrQuotaExceeded)
+	_, err = s.SetURL(ctx, "c1", "https://example.com/c", entity.Owner{UserID: "bob"})
+	require.NoError(t, err)
+	usage, err := q.Usage(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, Usage{Limits: Limits{MaxLinks: 3, MaxBatchSize: 2, MaxURLLength: 30}, Links: 3}, usage)
+}
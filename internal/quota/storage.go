@@ -0,0 +1,62 @@
+package quota
+
+import (
+	"context"
+
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+	"github.com/SversusN/shortener/internal/storage/storage"
+)
+
+// limitedStorage хранилище с проверкой квот перед сохранением ссылок
+type limitedStorage struct {
+	storage.Storage
+	q *Service
+}
+
+// limitedPinger хранилище с квотами и проверкой соединения
+type limitedPinger struct {
+	*limitedStorage
+	storage.Pinger
+}
+
+// NewStorage оборачивает хранилище проверкой квот
+//
+// Если хранилище поддерживает Ping, обертка тоже реализует storage.Pinger.
+func NewStorage(s storage.Storage, q *Service) storage.Storage {
+	ls := &limitedStorage{Storage: s, q: q}
+	if p, ok := s.(storage.Pinger); ok {
+		return &limitedPinger{limitedStorage: ls, Pinger: p}
+	}
+	return ls
+}
+
+// SetURL сохранение ссылки в пределах квот создателя
+func (ls *limitedStorage) SetURL(ctx context.Context, id string, targetURL string, owner entity.Owner) (string, error) {
+	if err := ls.q.CheckURL(targetURL); err != nil {
+		return "", err
+	}
+	if err := ls.q.CheckLinks(ctx, owner.UserID, 1); err != nil {
+		return "", err
+	}
+	return ls.Storage.SetURL(ctx, id, targetURL, owner)
+}
+
+// SetURLBatch пакетное сохранение: пакет с нарушением квот не сохраняется целиком
+func (ls *limitedStorage) SetURLBatch(ctx context.Context, u map[string]entity.UserURL) (map[string]entity.UserURL, error) {
+	if err := ls.q.CheckBatch(len(u)); err != nil {
+		return nil, err
+	}
+	perUser := make(map[string]int)
+	for _, url := range u {
+		if err := ls.q.CheckURL(url.OriginalURL); err != nil {
+			return nil, err
+		}
+		perUser[url.UserID]++
+	}
+	for userID, n := range perUser {
+		if err := ls.q.CheckLinks(ctx, userID, n); err != nil {
+			return nil, err
+		}
+	}
+	return ls.Storage.SetURLBatch(ctx, u)
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+)
+
+// sweepInterval период удаления полных корзин из памяти
+const sweepInterval = time.Minute
+
+// memoryBucket остаток токенов корзины на момент updated
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	bucket  entity.TokenBucket
+}
+
+// MemoryStore корзины в памяти одного экземпляра сервиса
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore создает хранилище корзин в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket), now: time.Now}
+}
+
+// TakeToken списание токена из корзины key
+func (m *MemoryStore) TakeToken(_ context.Context, key string, bucket entity.TokenBucket) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweep(now)
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: bucket.Burst, updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens, b.updated, b.bucket = bucket.Refill(b.tokens, now.Sub(b.updated)), now, bucket
+	if b.tokens < 1 {
+		return false, bucket.Wait(b.tokens), nil
+	}
+	b.tokens--
+	return true, 0, nil
+}
+
+// sweep удаление полных корзин: они не отличаются от отсутствующих
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if b.bucket.Refill(b.tokens, now.Sub(b.updated)) >= b.bucket.Burst {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Package ratelimit ограничивает частоту запросов клиентов корзинами токенов.
+//
+// Для сокращения ссылок, переходов и удаления действуют отдельные лимиты.
+// Клиент определяется по ключу API, пользователю учетной записи или, для анонимных
+// пользователей, по адресу с учетом доверенных прокси.
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/SversusN/shortener/internal/auth"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+	"github.com/SversusN/shortener/internal/storage/storage"
+)
+
+// Действия с отдельными лимитами
+const (
+	ActionShorten  = "shorten"  // сокращение ссылок, в том числе пакетное
+	ActionRedirect = "redirect" // переход по короткой ссылке
+	ActionDelete   = "delete"   // удаление ссылок пользователя
+)
+
+// Limiter проверка лимитов действий, nil пропускает все запросы
+type Limiter struct {
+	store   storage.RateLimitStore
+	buckets map[string]entity.TokenBucket
+}
+
+// PerMinute корзина на perMinute запросов в минуту с запасом на минуту
+func PerMinute(perMinute int) entity.TokenBucket {
+	return entity.TokenBucket{Rate: float64(perMinute) / 60, Burst: float64(perMinute)}
+}
+
+// New создает ограничитель, limits - запросов в минуту на действие, 0 - без ограничения
+func New(store storage.RateLimitStore, limits map[string]int) *Limiter {
+	buckets := make(map[string]entity.TokenBucket, len(limits))
+	for action, perMinute := range limits {
+		if perMinute > 0 {
+			buckets[action] = PerMinute(perMinute)
+		}
+	}
+	return &Limiter{store: store, buckets: buckets}
+}
+
+// Allow списывает запрос клиента, при превышении возвращает false и время до следующей попытки
+func (l *Limiter) Allow(ctx context.Context, action, client string) (bool, time.Duration, error) {
+	if l == nil {
+		return true, 0, nil
+	}
+	bucket, ok := l.buckets[action]
+	if !ok {
+		return true, 0, nil
+	}
+	return l.store.TakeToken(ctx, action+":"+client, bucket)
+}
+
+// ClientKey клиент запроса: ключ API, пользователь учетной записи или адрес ip
+//
+// Анонимный токен можно получить заново на каждый запрос, поэтому анонимные пользователи считаются по адресу.
+func ClientKey(ctx context.Context, ip net.IP) string {
+	if keyID := auth.APIKeyIDFromCtx(ctx); keyID != "" {
+		return "key:" + keyID
+	}
+	if userID, err := auth.UserIDFromCtx(ctx); err == nil && auth.IsAccount(ctx) {
+		return "user:" + userID
+	}
+	if ip == nil {
+		return "ip:unknown"
+	}
+	return "ip:" + ip.String()
+}
+
+// RetryAfterSeconds значение заголовка Retry-After, не меньше секунды
+func RetryAfterSeconds(wait time.Duration) int {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
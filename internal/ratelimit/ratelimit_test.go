@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SversusN/shortener/internal/auth"
+)
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	l := New(store, map[string]int{ActionShorten: 2, ActionRedirect: 0})
+
+	for i := 0; i < 2; i++ {
+		ok, _, err := l.Allow(ctx, ActionShorten, "ip:1.2.3.4")
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+	ok, wait, err := l.Allow(ctx, ActionShorten, "ip:1.2.3.4")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 30*time.Second, wait)
+	assert.Equal(t, 30, RetryAfterSeconds(wait))
+
+	//Другой клиент и действие без лимита не ограничены, корзина пополняется со временем
+	ok, _, _ = l.Allow(ctx, ActionShorten, "ip:5.6.7.8")
+	assert.True(t, ok)
+	ok, _, _ = l.Allow(ctx, ActionRedirect, "ip:1.2.3.4")
+	assert.True(t, ok)
+	now = now.Add(30 * time.Second)
+	ok, _, _ = l.Allow(ctx, ActionShorten, "ip:1.2.3.4")
+	assert.True(t, ok)
+
+	//Полные корзины удаляются из памяти
+	now = now.Add(2 * time.Minute)
+	_, _, _ = l.Allow(ctx, ActionShorten, "ip:9.9.9.9")
+	assert.Len(t, store.buckets, 1)
+}
+
+func TestClientKey(t *testing.T) {
+	ip := net.ParseIP("203.0.113.5")
+	ctx := context.Background()
+	assert.Equal(t, "ip:203.0.113.5", ClientKey(ctx, ip))
+	assert.Equal(t, "ip:203.0.113.5", ClientKey(auth.WithUserID(ctx, "anon"), ip))
+	assert.Equal(t, "ip:203.0.113.5", ClientKey(auth.WithClaims(ctx, auth.Claims{UserID: "anon"}), ip))
+	assert.Equal(t, "user:alice", ClientKey(auth.WithClaims(ctx, auth.Claims{UserID: "alice", Account: true}), ip))
+	assert.Equal(t, "key:k1", ClientKey(auth.WithAPIKeyID(auth.WithUserID(ctx, "alice"), "k1"), ip))
+}
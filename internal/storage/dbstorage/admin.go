@@ -0,0 +1,202 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/SversusN/shortener/internal/internalerrors"
+)
+
+// GetUserAccess роль и блокировка пользователя
+//
+// Читается с основной БД: блокировка должна действовать сразу.
+func (pg *PostgresDB) GetUserAccess(ctx context.Context, userID string) (UserAccess, error) {
+	access := UserAccess{UserID: userID}
+	query := "SELECT role, disabled FROM USER_ACCESS WHERE user_id = $1"
+	err := pg.db.QueryRowContext(ctx, query, userID).Scan(&access.Role, &access.Disabled)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return UserAccess{}, fmt.Errorf("failed to query user access: %w", err)
+	}
+	return access, nil
+}
+
+// SetUserAccess сохранение роли и блокировки пользователя
+func (pg *PostgresDB) SetUserAccess(ctx context.Context, access UserAccess) error {
+	query := `INSERT INTO USER_ACCESS (user_id, role, disabled) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET role = EXCLUDED.role, disabled = EXCLUDED.disabled, updated_at = now()`
+	if _, err := pg.db.ExecContext(ctx, query, access.UserID, access.Role, access.Disabled); err != nil {
+		return fmt.Errorf("failed to set user access: %w", err)
+	}
+	return nil
+}
+
+// ListURLs ссылки всех пользователей, новые первыми
+func (pg *PostgresDB) ListURLs(ctx context.Context, filter URLFilter) ([]URLInfo, error) {
+	query := `SELECT short_url, original_url, COALESCE(user_id::text, ''), created_at,
+		COALESCE(is_deleted, FALSE), deleted_at, is_disabled, COALESCE(workspace_id::text, '')
+		FROM URLS
+		WHERE ($1 = '' OR strpos(lower(short_url), lower($1)) > 0 OR strpos(lower(original_url), lower($1)) > 0)
+		AND ($2 = '' OR user_id::text = $2)
+		ORDER BY created_at DESC, short_url LIMIT $3 OFFSET $4`
+	result := make([]URLInfo, 0)
+	err := pg.replicas.read(ctx, func(db *sql.DB) error {
+		result = result[:0]
+		rows, err := db.QueryContext(ctx, query, filter.Query, filter.UserID, filter.Limit, filter.Offset)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				u         URLInfo
+				deletedAt sql.NullTime
+			)
+			err = rows.Scan(&u.ShortURL, &u.OriginalURL, &u.UserID, &u.CreatedAt, &u.IsDeleted, &deletedAt, &u.IsDisabled, &u.WorkspaceID)
+			if err != nil {
+				return err
+			}
+			u.DeletedAt = deletedAt.Time
+			result = append(result, u)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list urls: %w", err)
+	}
+	return result, nil
+}
+
+// SetURLDisabled блокировка ссылки, кэш редиректа сбрасывается
+func (pg *PostgresDB) SetURLDisabled(ctx context.Context, shortURL string, disabled bool) error {
+	res, err := pg.db.ExecContext(ctx, "UPDATE URLS SET is_disabled = $2 WHERE short_url = $1", shortURL, disabled)
+	if err != nil {
+		return fmt.Errorf("failed to disable url: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return internalerrors.ErrNotFound
+	}
+	pg.cache.remove(shortURL)
+	pg.replicas.markWrite(shortURL)
+	return nil
+}
+
+// DeleteURL удаление ссылки любого пользователя, повторное удаление не меняет время удаления
+func (pg *PostgresDB) DeleteURL(ctx context.Context, shortURL string) error {
+	query := "UPDATE URLS SET is_deleted = TRUE, deleted_at = COALESCE(deleted_at, now()) WHERE short_url = $1"
+	res, err := pg.db.ExecContext(ctx, query, shortURL)
+	if err != nil {
+		return fmt.Errorf("failed to delete url: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return internalerrors.ErrNotFound
+	}
+	pg.cache.remove(shortURL)
+	pg.replicas.markWrite(shortURL)
+	return nil
+}
+
+// ListUsers пользователи с паролем и пользователи провайдеров входа, новые первыми
+func (pg *PostgresDB) ListUsers(ctx context.Context, filter UserFilter) ([]UserInfo, error) {
+	query := `SELECT u.id::text, u.login, '', '', COALESCE(a.role, ''), COALESCE(a.disabled, FALSE), u.created_at
+		FROM USERS u LEFT JOIN USER_ACCESS a ON a.user_id = u.id
+		WHERE $1 = '' OR strpos(lower(u.login), lower($1)) > 0 OR u.id::text = $1
+		UNION ALL
+		SELECT i.user_id::text, '', i.issuer, i.subject, COALESCE(a.role, ''), COALESCE(a.disabled, FALSE), i.created_at
+		FROM USER_IDENTITIES i LEFT JOIN USER_ACCESS a ON a.user_id = i.user_id
+		WHERE $1 = '' OR strpos(lower(i.subject), lower($1)) > 0 OR i.user_id::text = $1
+		ORDER BY 7 DESC, 1 LIMIT $2 OFFSET $3`
+	rows, err := pg.db.QueryContext(ctx, query, filter.Query, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+	result := make([]UserInfo, 0)
+	for rows.Next() {
+		var u UserInfo
+		if err := rows.Scan(&u.ID, &u.Login, &u.Issuer, &u.Subject, &u.Role, &u.Disabled, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to list users: %w", err)
+		}
+		result = append(result, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return result, nil
+}
+
+// DeleteUser удаление пользователя одной транзакцией, ИД остается заблокированным
+//
+// Ссылки пространств остаются у пространств, удаляются только личные ссылки.
+// Если пользователь - последний владелец пространства, владельцем становится самый давний
+// из остальных участников; ссылки пространства без других участников удаляются вместе с личными.
+func (pg *PostgresDB) DeleteUser(ctx context.Context, userID string) (deleted int, err error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	//Пространства пользователя блокируются, как при смене участников
+	query := `SELECT w.id FROM WORKSPACES w JOIN WORKSPACE_MEMBERS m ON m.workspace_id = w.id
+		WHERE m.user_id = $1 ORDER BY w.id FOR UPDATE OF w`
+	if _, err = tx.ExecContext(ctx, query, userID); err != nil {
+		return 0, fmt.Errorf("failed to lock user workspaces: %w", err)
+	}
+	query = `UPDATE URLS SET is_deleted = TRUE, deleted_at = now()
+		WHERE is_deleted = FALSE AND ((user_id = $1 AND workspace_id IS NULL) OR workspace_id IN (
+			SELECT m.workspace_id FROM WORKSPACE_MEMBERS m WHERE m.user_id = $1 AND NOT EXISTS(
+				SELECT 1 FROM WORKSPACE_MEMBERS o WHERE o.workspace_id = m.workspace_id AND o.user_id <> $1)))
+		RETURNING short_url`
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete user urls: %w", err)
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to delete user urls: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to delete user urls: %w", err)
+	}
+	query = `UPDATE WORKSPACE_MEMBERS m SET role = $2 FROM (
+			SELECT DISTINCT ON (o.workspace_id) o.workspace_id, o.user_id FROM WORKSPACE_MEMBERS o
+			WHERE o.user_id <> $1 AND o.workspace_id IN (
+				SELECT workspace_id FROM WORKSPACE_MEMBERS WHERE user_id = $1 AND role = $2)
+			AND NOT EXISTS(SELECT 1 FROM WORKSPACE_MEMBERS x
+				WHERE x.workspace_id = o.workspace_id AND x.user_id <> $1 AND x.role = $2)
+			ORDER BY o.workspace_id, o.created_at) heir
+		WHERE m.workspace_id = heir.workspace_id AND m.user_id = heir.user_id`
+	if _, err = tx.ExecContext(ctx, query, userID, WorkspaceRoleOwner); err != nil {
+		return 0, fmt.Errorf("failed to transfer workspace ownership: %w", err)
+	}
+	for _, q := range []string{
+		"DELETE FROM USERS WHERE id = $1",
+		"DELETE FROM USER_IDENTITIES WHERE user_id = $1",
+		"DELETE FROM API_KEYS WHERE user_id = $1",
+		"DELETE FROM WORKSPACE_MEMBERS WHERE user_id = $1",
+		`INSERT INTO USER_ACCESS (user_id, disabled) VALUES ($1, TRUE)
+			ON CONFLICT (user_id) DO UPDATE SET disabled = TRUE, updated_at = now()`,
+	} {
+		if _, err = tx.ExecContext(ctx, q, userID); err != nil {
+			return 0, fmt.Errorf("failed to delete user: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	pg.cache.remove(keys...)
+	pg.replicas.markWrite(userID)
+	pg.replicas.markWrite(keys...)
+	return len(keys), nil
+}
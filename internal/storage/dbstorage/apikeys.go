@@ -0,0 +1,99 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SversusN/shortener/internal/internalerrors"
+)
+
+// CreateAPIKey сохранение ключа API
+func (pg *PostgresDB) CreateAPIKey(ctx context.Context, k APIKey) error {
+	query := "INSERT INTO API_KEYS (id, user_id, name, hash, scopes) VALUES ($1, $2, $3, $4, $5)"
+	if _, err := pg.db.ExecContext(ctx, query, k.ID, k.UserID, k.Name, k.Hash, strings.Join(k.Scopes, ",")); err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey ключ API по ИД
+//
+// Читается с основной БД: отозванный ключ не должен действовать из-за отставания реплик.
+func (pg *PostgresDB) GetAPIKey(ctx context.Context, id string) (APIKey, error) {
+	query := "SELECT id, user_id, name, hash, scopes, created_at, last_used_at FROM API_KEYS WHERE id = $1"
+	k, err := scanAPIKey(pg.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKey{}, internalerrors.ErrNotFound
+	}
+	if err != nil {
+		return APIKey{}, fmt.Errorf("failed to query api key: %w", err)
+	}
+	return k, nil
+}
+
+// ListAPIKeys ключи API пользователя по времени создания
+func (pg *PostgresDB) ListAPIKeys(ctx context.Context, userID string) ([]APIKey, error) {
+	query := "SELECT id, user_id, name, hash, scopes, created_at, last_used_at FROM API_KEYS WHERE user_id = $1 ORDER BY created_at"
+	rows, err := pg.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+	keys := make([]APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// DeleteAPIKey отзыв ключа API пользователя
+func (pg *PostgresDB) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	res, err := pg.db.ExecContext(ctx, "DELETE FROM API_KEYS WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete api key: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete api key: %w", err)
+	}
+	if n == 0 {
+		return internalerrors.ErrNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey обновление времени последнего использования ключа API
+func (pg *PostgresDB) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	if _, err := pg.db.ExecContext(ctx, "UPDATE API_KEYS SET last_used_at = $2 WHERE id = $1", id, at); err != nil {
+		return fmt.Errorf("failed to touch api key: %w", err)
+	}
+	return nil
+}
+
+// rowScanner общий интерфейс sql.Row и sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanAPIKey чтение ключа API из строки результата
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var (
+		k        APIKey
+		scopes   string
+		lastUsed sql.NullTime
+	)
+	if err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Hash, &scopes, &k.CreatedAt, &lastUsed); err != nil {
+		return APIKey{}, err
+	}
+	k.Scopes = strings.Split(scopes, ",")
+	k.LastUsedAt = lastUsed.Time
+	return k, nil
+}
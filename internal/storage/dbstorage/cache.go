@@ -0,0 +1,143 @@
+// Кэш коротких ссылок для редиректа
+package dbstorage
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// urlCacheTTL время жизни записи кэша
+//
+// Удаление и блокировка на этом экземпляре сбрасывают запись сразу, на остальных
+// экземплярах они вступают в силу не позже чем через urlCacheTTL.
+const urlCacheTTL = 5 * time.Second
+
+// urlCache LRU кэш short_url -> ссылка с признаками удаления и блокировки
+//
+// Кэш размером 0 выключен: ничего не хранит и не считает обращения.
+type urlCache struct {
+	mu     sync.Mutex
+	size   int
+	ttl    time.Duration
+	now    func() time.Time
+	items  map[string]*list.Element
+	order  *list.List // от недавно использованных к давно использованным
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// cachedURL ссылка и ее состояние на момент чтения из БД
+type cachedURL struct {
+	originalURL string
+	deleted     bool
+	disabled    bool
+}
+
+// cacheEntry элемент кэша
+type cacheEntry struct {
+	key     string
+	value   cachedURL
+	expires time.Time
+}
+
+// newURLCache конструктор кэша на size элементов с временем жизни записей ttl
+func newURLCache(size int, ttl time.Duration) *urlCache {
+	return &urlCache{
+		size:  size,
+		ttl:   ttl,
+		now:   time.Now,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// get получение ссылки из кэша, устаревшая запись удаляется и считается промахом
+func (c *urlCache) get(key string) (cachedURL, bool) {
+	if c.size <= 0 {
+		return cachedURL{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if ok && !c.now().Before(e.Value.(*cacheEntry).expires) {
+		c.order.Remove(e)
+		delete(c.items, key)
+		ok = false
+	}
+	if !ok {
+		c.misses.Add(1)
+		return cachedURL{}, false
+	}
+	c.hits.Add(1)
+	c.order.MoveToFront(e)
+	return e.Value.(*cacheEntry).value, true
+}
+
+// put сохранение ссылки с вытеснением давно использованной
+func (c *urlCache) put(key string, value cachedURL) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if e, ok := c.items[key]; ok {
+		entry := e.Value.(*cacheEntry)
+		entry.value, entry.expires = value, expires
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, value: value, expires: expires})
+	if c.order.Len() > c.size {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.items, last.Value.(*cacheEntry).key)
+	}
+}
+
+// remove удаление ссылок из кэша
+func (c *urlCache) remove(keys ...string) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if e, ok := c.items[key]; ok {
+			c.order.Remove(e)
+			delete(c.items, key)
+		}
+	}
+}
+
+// check проверка, что кэш не заблокирован дольше дедлайна ctx
+func (c *urlCache) check(ctx context.Context) error {
+	for !c.mu.TryLock() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// CheckCache проверка доступности кэша ссылок
+func (pg *PostgresDB) CheckCache(ctx context.Context) error {
+	return pg.cache.check(ctx)
+}
+
+// CacheStats количество попаданий и промахов кэша ссылок
+func (pg *PostgresDB) CacheStats() (hits uint64, misses uint64) {
+	return pg.cache.hits.Load(), pg.cache.misses.Load()
+}
+
+// PoolStats статистика пула соединений с основной БД
+func (pg *PostgresDB) PoolStats() sql.DBStats {
+	return pg.db.Stats()
+}
@@ -0,0 +1,43 @@
+package dbstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLCache(t *testing.T) {
+	now := time.Now()
+	c := newURLCache(2, time.Second)
+	c.now = func() time.Time { return now }
+
+	//Состояние ссылки хранится вместе с ней
+	c.put("short", cachedURL{originalURL: "https://example.com", disabled: true})
+	u, ok := c.get("short")
+	assert.True(t, ok)
+	assert.True(t, u.disabled)
+
+	//Устаревшая запись не отдается и перечитывается из БД
+	now = now.Add(time.Second)
+	_, ok = c.get("short")
+	assert.False(t, ok)
+	assert.Empty(t, c.items)
+
+	//Давно использованная запись вытесняется
+	c.put("a", cachedURL{originalURL: "a"})
+	c.put("b", cachedURL{originalURL: "b"})
+	_, _ = c.get("a")
+	c.put("c", cachedURL{originalURL: "c"})
+	_, ok = c.get("b")
+	assert.False(t, ok)
+	hits, misses := c.hits.Load(), c.misses.Load()
+	assert.Equal(t, uint64(2), hits)
+	assert.Equal(t, uint64(2), misses)
+
+	//Выключенный кэш ничего не хранит
+	off := newURLCache(0, time.Second)
+	off.put("short", cachedURL{originalURL: "a"})
+	_, ok = off.get("short")
+	assert.False(t, ok)
+}
@@ -0,0 +1,70 @@
+// Буферизация переходов по ссылкам для временного ряда статистики
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// clicksFlushInterval период сброса накопленных переходов в БД
+const clicksFlushInterval = 10 * time.Second
+
+// clickBuffer копит переходы по часам, чтобы редирект не писал в БД на каждый запрос
+type clickBuffer struct {
+	mu      sync.Mutex
+	buckets map[time.Time]int64
+	lg      *zap.Logger
+}
+
+// newClickBuffer конструктор буфера переходов
+func newClickBuffer(lg *zap.Logger) *clickBuffer {
+	return &clickBuffer{buckets: make(map[time.Time]int64), lg: lg}
+}
+
+// add учет перехода в момент t
+func (cb *clickBuffer) add(t time.Time, n int64) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.buckets[BucketStart(t, IntervalHour)] += n
+}
+
+// flush запись накопленных переходов, при ошибке несохраненные значения возвращаются в буфер
+func (cb *clickBuffer) flush(ctx context.Context, db *sql.DB) error {
+	cb.mu.Lock()
+	pending := cb.buckets
+	cb.buckets = make(map[time.Time]int64)
+	cb.mu.Unlock()
+
+	query := `INSERT INTO STATS_HOURLY (bucket, clicked) VALUES ($1, $2)
+		ON CONFLICT (bucket) DO UPDATE SET clicked = STATS_HOURLY.clicked + EXCLUDED.clicked;`
+	for bucket, n := range pending {
+		if _, err := db.ExecContext(ctx, query, bucket, n); err != nil {
+			for b, v := range pending {
+				cb.add(b, v)
+			}
+			return err
+		}
+		delete(pending, bucket)
+	}
+	return nil
+}
+
+// run периодический сброс буфера до отмены контекста
+func (cb *clickBuffer) run(ctx context.Context, db *sql.DB) {
+	ticker := time.NewTicker(clicksFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cb.flush(ctx, db); err != nil {
+				cb.lg.Error("failed to flush clicks", zap.Error(err))
+			}
+		}
+	}
+}
@@ -1,6 +1,14 @@
 // Модель хранения объектов в БД
 package dbstorage
 
+import (
+	"math"
+	"time"
+)
+
+// TopUsersLimit количество пользователей в рейтинге статистики
+const TopUsersLimit = 10
+
 // UserURLEntity связка короткой и оригинальной ссылки
 type UserURLEntity struct {
 	ShortURL    string
@@ -11,4 +19,167 @@ type UserURLEntity struct {
 type UserURL struct {
 	UserID      string
 	OriginalURL string
+	CreatedAt   time.Time // время создания, заполняется хранилищем
+	IsDeleted   bool      // признак удаления ссылки
+	DeletedAt   time.Time // время удаления
+	IsDisabled  bool      // ссылка заблокирована администратором
+	WorkspaceID string    `json:",omitempty"` // пространство-владелец, пустое для личной ссылки
+}
+
+// Owner владелец ссылок в запросе: личные ссылки пользователя или, с WorkspaceID,
+// ссылки рабочего пространства, в котором пользователь состоит
+type Owner struct {
+	UserID      string
+	WorkspaceID string
+}
+
+// Owns ссылка принадлежит владельцу
+func (o Owner) Owns(u UserURL) bool {
+	if o.WorkspaceID != "" {
+		return u.WorkspaceID == o.WorkspaceID
+	}
+	return u.UserID == o.UserID && u.WorkspaceID == ""
+}
+
+// Key ключ владельца для чтения своих записей с основной БД
+func (o Owner) Key() string {
+	if o.WorkspaceID != "" {
+		return o.WorkspaceID
+	}
+	return o.UserID
+}
+
+// Workspace рабочее пространство с общими ссылками
+type Workspace struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time // время создания, заполняется хранилищем
+}
+
+// WorkspaceRoleOwner роль владельца пространства, хранилище не дает пространству остаться без него
+const WorkspaceRoleOwner = "owner"
+
+// WorkspaceMember участник пространства с ролью
+type WorkspaceMember struct {
+	WorkspaceID string
+	UserID      string
+	Role        string
+	CreatedAt   time.Time // время добавления, заполняется хранилищем
+}
+
+// WorkspaceMembership пространство пользователя и его роль в нем
+type WorkspaceMembership struct {
+	Workspace
+	Role string
+}
+
+// User зарегистрированный пользователь
+//
+// ID совпадает по формату с ИД анонимных пользователей (uuid), поэтому ссылки
+// хранятся одинаково для обоих видов пользователей.
+type User struct {
+	ID           string
+	Login        string
+	PasswordHash string    // хэш bcrypt
+	CreatedAt    time.Time // время регистрации, заполняется хранилищем
+}
+
+// Identity пользователь внешнего провайдера входа, связанный с UserID сервиса
+type Identity struct {
+	Issuer    string
+	Subject   string
+	UserID    string
+	CreatedAt time.Time // время первого входа, заполняется хранилищем
+}
+
+// UserAccess роль и блокировка пользователя
+//
+// Пользователь без записи имеет роль auth.RoleUser и не заблокирован.
+type UserAccess struct {
+	UserID   string
+	Role     string
+	Disabled bool
+}
+
+// URLFilter отбор ссылок всех пользователей для администратора
+type URLFilter struct {
+	Query  string // подстрока короткой или оригинальной ссылки
+	UserID string
+	Limit  int
+	Offset int
+}
+
+// URLInfo ссылка любого пользователя для администратора
+type URLInfo struct {
+	ShortURL string
+	UserURL
+}
+
+// UserFilter отбор зарегистрированных пользователей для администратора
+type UserFilter struct {
+	Query  string // подстрока логина, субъекта провайдера или ИД
+	Limit  int
+	Offset int
+}
+
+// UserInfo зарегистрированный пользователь для администратора
+//
+// Пользователь с паролем имеет Login, пользователь провайдера входа - Issuer и Subject.
+type UserInfo struct {
+	ID        string
+	Login     string
+	Issuer    string
+	Subject   string
+	Role      string
+	Disabled  bool
+	CreatedAt time.Time
+}
+
+// APIKey ключ API пользователя, секрет хранится только в виде хэша
+type APIKey struct {
+	ID         string
+	UserID     string
+	Name       string
+	Hash       string // sha256 секрета в hex
+	Scopes     []string
+	CreatedAt  time.Time // время создания, заполняется хранилищем
+	LastUsedAt time.Time // нулевое - ключ не использовался
+}
+
+// Stats статистика ссылок и пользователей сервиса
+type Stats struct {
+	Total          int        // всего сокращенных ссылок, включая удаленные
+	Active         int        // неудаленные ссылки
+	Deleted        int        // удаленные ссылки
+	CreatedLastDay int        // ссылки, созданные за последние 24 часа
+	Users          int        // пользователи с активными ссылками
+	TopUsers       []UserStat // пользователи с наибольшим числом активных ссылок
+}
+
+// UserStat количество активных ссылок пользователя
+type UserStat struct {
+	UserID string
+	URLs   int
+}
+
+// TokenBucket параметры корзины токенов для ограничения запросов
+type TokenBucket struct {
+	Rate  float64 // пополнение, токенов в секунду
+	Burst float64 // емкость корзины, новая корзина полная
+}
+
+// Refill остаток токенов через elapsed после остатка tokens
+func (b TokenBucket) Refill(tokens float64, elapsed time.Duration) float64 {
+	if elapsed > 0 {
+		tokens += elapsed.Seconds() * b.Rate
+	}
+	return math.Min(tokens, b.Burst)
+}
+
+// Wait время до появления целого токена при остатке tokens
+func (b TokenBucket) Wait(tokens float64) time.Duration {
+	if tokens >= 1 || b.Rate <= 0 {
+		return 0
+	}
+	return time.Duration((1 - tokens) / b.Rate * float64(time.Second))
 }
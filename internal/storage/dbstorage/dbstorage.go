@@ -7,20 +7,26 @@ import (
 	"embed"
 	"errors"
 	"fmt"
-	"log"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
 
 	"github.com/SversusN/shortener/internal/internalerrors"
+	"github.com/SversusN/shortener/internal/logger"
 	utils "github.com/SversusN/shortener/internal/pkg/migrator"
 )
 
 // PostgresDB структура для реализации sql.DB с помощью драйвера для PostgreSQL
 type PostgresDB struct {
-	ctx context.Context
-	db  *sql.DB
+	ctx      context.Context
+	db       *sql.DB
+	replicas *replicaSet // реплики для чтения, без реплик чтение идет в db
+	clicks   *clickBuffer
+	cache    *urlCache   // кэш ссылок для редиректа
+	lg       *zap.Logger // логер фоновых операций хранилища
 }
 
 //go:embed migrations/*.sql
@@ -29,8 +35,16 @@ var MigrationsFS embed.FS
 // migrationsDir - локальная папка с миграциями
 const migrationsDir = "migrations"
 
+// ownerFilter условие ссылок владельца: $1 - пользователь, $2 - пространство или пустая строка
+const ownerFilter = "(($2 = '' AND user_id = $1::uuid AND workspace_id IS NULL) OR workspace_id = NULLIF($2, '')::uuid)"
+
 // NewDB конструктор для объекта БД
-func NewDB(ctx context.Context, connectionString string) (*PostgresDB, error) {
+//
+// replicaDSNs - строки соединения с репликами для чтения, может быть пустым.
+// readYourWrites - окно, в течение которого чтение после записи идет на основную БД.
+// cacheSize - размер кэша ссылок для редиректа, 0 выключает кэш, записи живут urlCacheTTL.
+// lg - логер для операций вне запросов (миграции, реплики, фоновые записи).
+func NewDB(ctx context.Context, connectionString string, replicaDSNs []string, readYourWrites time.Duration, cacheSize int, lg *zap.Logger) (*PostgresDB, error) {
 	db, err := sql.Open("pgx", connectionString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open connection to postgresql: %w", err)
@@ -49,49 +63,78 @@ func NewDB(ctx context.Context, connectionString string) (*PostgresDB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create table URLs: %w", err)
 	}
-	log.Println("Migrations applied!")
-	return &PostgresDB{
-		db:  db,
-		ctx: ctx,
-	}, nil
+	lg.Info("Migrations applied!")
+	replicas, err := newReplicaSet(ctx, db, replicaDSNs, readYourWrites, lg)
+	if err != nil {
+		return nil, err
+	}
+	clicks := newClickBuffer(lg)
+	go clicks.run(ctx, db)
+	pg := &PostgresDB{
+		db:       db,
+		ctx:      ctx,
+		replicas: replicas,
+		clicks:   clicks,
+		cache:    newURLCache(cacheSize, urlCacheTTL),
+		lg:       lg,
+	}
+	go pg.purgeRateLimits(ctx)
+	return pg, nil
 }
 
 // Close -метод закрытия соединения
 func (pg *PostgresDB) Close() {
+	if pg.clicks != nil {
+		if err := pg.clicks.flush(context.Background(), pg.db); err != nil {
+			pg.lg.Error("failed to flush clicks", zap.Error(err))
+		}
+	}
+	if pg.replicas != nil {
+		pg.replicas.close()
+	}
 	if pg.db != nil {
 		err := pg.db.Close()
 		if err != nil {
-			log.Fatalf("Error closing database connection: %v\n", err)
+			pg.lg.Error("Error closing database connection", zap.Error(err))
+			return
 		}
-		log.Println("Database connection closed.")
+		pg.lg.Info("Database connection closed.")
 	}
 }
 
 // GetURL - реализация метода получения единичной ссылки
-func (pg *PostgresDB) GetURL(shortURL string) (string, error) {
-	query := "SELECT original_url, COALESCE(is_deleted, FALSE) as is_deleted FROM URLS WHERE short_url=$1"
-	row := pg.db.QueryRowContext(pg.ctx, query, shortURL)
-	var (
-		originalURL string
-		isDeleted   bool
-	)
-	err := row.Scan(&originalURL, &isDeleted)
-	if err != nil {
-		return "", fmt.Errorf("failed to query short URL: %w", err)
+//
+// Удаленные и заблокированные ссылки кэшируются вместе с состоянием.
+func (pg *PostgresDB) GetURL(ctx context.Context, shortURL string) (string, error) {
+	u, ok := pg.cache.get(shortURL)
+	if !ok {
+		query := "SELECT original_url, COALESCE(is_deleted, FALSE) as is_deleted, is_disabled FROM URLS WHERE short_url=$1"
+		err := pg.replicas.readExisting(ctx, func(db *sql.DB) error {
+			return db.QueryRowContext(ctx, query, shortURL).Scan(&u.originalURL, &u.deleted, &u.disabled)
+		}, shortURL)
+		if err != nil {
+			return "", fmt.Errorf("failed to query short URL: %w", err)
+		}
+		pg.cache.put(shortURL, u)
 	}
-	if isDeleted {
+	if u.deleted {
 		return "", internalerrors.ErrDeleted
 	}
-	return originalURL, nil
+	if u.disabled {
+		return "", internalerrors.ErrDisabled
+	}
+	pg.clicks.add(time.Now(), 1)
+	return u.originalURL, nil
 }
 
 // SetURL реализация метода сохранения едичничной ссылки
-func (pg *PostgresDB) SetURL(shortURL string, originalURL string, userID string) (string, error) {
+func (pg *PostgresDB) SetURL(ctx context.Context, shortURL string, originalURL string, owner Owner) (string, error) {
+	userID := owner.UserID
 	if userID == "" {
 		userID = uuid.Nil.String()
 	}
 
-	tx, err := pg.db.Begin()
+	tx, err := pg.db.BeginTx(ctx, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -102,11 +145,20 @@ func (pg *PostgresDB) SetURL(shortURL string, originalURL string, userID string)
 	}()
 	var keyExist string
 	queryCheck := "SELECT short_url FROM URLS WHERE original_url=$1 LIMIT 1 FOR UPDATE"
-	query := "INSERT INTO URLS (short_url, original_url, user_id) VALUES ($1, $2, $3)"
-	errKeyExist := tx.QueryRowContext(pg.ctx, queryCheck, originalURL).Scan(&keyExist)
+	query := "INSERT INTO URLS (short_url, original_url, user_id, workspace_id) VALUES ($1, $2, $3, NULLIF($4, '')::uuid)"
+	errKeyExist := tx.QueryRowContext(ctx, queryCheck, originalURL).Scan(&keyExist)
+	if errKeyExist != nil && !errors.Is(errKeyExist, sql.ErrNoRows) {
+		err = errKeyExist
+		return "", fmt.Errorf("failed to check url: %w", err)
+	}
 	if errors.Is(errKeyExist, sql.ErrNoRows) {
-		tx.QueryRowContext(pg.ctx, query, shortURL, originalURL, userID)
-		tx.Commit()
+		if _, err = tx.ExecContext(ctx, query, shortURL, originalURL, userID, owner.WorkspaceID); err != nil {
+			return "", fmt.Errorf("failed to insert url: %w", err)
+		}
+		if err = tx.Commit(); err != nil {
+			return "", fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		pg.replicas.markWrite(owner.Key(), shortURL)
 		return shortURL, nil
 	} else {
 		tx.Rollback()
@@ -115,9 +167,9 @@ func (pg *PostgresDB) SetURL(shortURL string, originalURL string, userID string)
 }
 
 // SetURLBatch сохранение массива ссылок
-func (pg *PostgresDB) SetURLBatch(u map[string]UserURL) (map[string]UserURL, error) {
+func (pg *PostgresDB) SetURLBatch(ctx context.Context, u map[string]UserURL) (map[string]UserURL, error) {
 	result := make(map[string]UserURL)
-	tx, err := pg.db.Begin()
+	tx, err := pg.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -128,70 +180,78 @@ func (pg *PostgresDB) SetURLBatch(u map[string]UserURL) (map[string]UserURL, err
 	}()
 
 	queryCheck := "SELECT short_url FROM URLS WHERE original_url=$1 LIMIT 1 FOR UPDATE"
-	query := "INSERT INTO URLS (short_url, original_url, user_id) VALUES ($1, $2, $3)"
+	query := "INSERT INTO URLS (short_url, original_url, user_id, workspace_id) VALUES ($1, $2, $3, NULLIF($4, '')::uuid)"
 	var possibleError error
 	for s := range u {
 		var keyExist string
-		errBlankKey := tx.QueryRowContext(pg.ctx, queryCheck, u[s].OriginalURL).Scan(&keyExist)
-		if errors.Is(errBlankKey, sql.ErrNoRows) {
-			tx.QueryRowContext(pg.ctx, query, s, u[s].OriginalURL, u[s].UserID)
+		err = tx.QueryRowContext(ctx, queryCheck, u[s].OriginalURL).Scan(&keyExist)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if _, err = tx.ExecContext(ctx, query, s, u[s].OriginalURL, u[s].UserID, u[s].WorkspaceID); err != nil {
+				return nil, fmt.Errorf("failed to insert url: %w", err)
+			}
 			result[s] = u[s]
-		} else {
+		case err != nil:
+			return nil, fmt.Errorf("failed to check url: %w", err)
+		default:
 			possibleError = internalerrors.ErrOriginalURLAlreadyExists
 			result[keyExist] = u[s]
 		}
 	}
-	err = tx.Commit()
-	if err != nil {
-		err := tx.Rollback()
-		if err != nil {
-			return nil, err
-		}
+	if err = tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	for s := range result {
+		pg.replicas.markWrite(Owner{UserID: result[s].UserID, WorkspaceID: result[s].WorkspaceID}.Key(), s)
+	}
 	return result, possibleError
 }
 
 // Ping - метод проверки соединения с БД Postgre
-func (pg *PostgresDB) Ping() error {
-	err := pg.db.Ping()
+func (pg *PostgresDB) Ping(ctx context.Context) error {
+	err := pg.db.PingContext(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to ping PostgreSQL: %w", err)
 	}
 	return nil
 }
 
-// GetUserUrls получение массива ссылок  с фильтром пользователя
-func (pg *PostgresDB) GetUserUrls(userID string) (any, error) {
-	result := make([]UserURLEntity, 0)
-	query := "SELECT short_url, original_url FROM URLS WHERE user_id = $1 and is_deleted = FALSE;"
-	rows, err := pg.db.QueryContext(pg.ctx, query, userID)
-	if err != nil {
-		return nil, errors.New("error postgres get userUrls")
-	}
-	defer rows.Close()
-	var count = 0
-	for rows.Next() {
-		count++
-		resultRow := UserURLEntity{}
-		err = rows.Scan(&resultRow.ShortURL, &resultRow.OriginalURL)
+// GetUserUrls получение массива ссылок  с фильтром владельца
+func (pg *PostgresDB) GetUserUrls(ctx context.Context, owner Owner) (any, error) {
+	var result []UserURLEntity
+	query := "SELECT short_url, original_url FROM URLS WHERE " + ownerFilter + " and is_deleted = FALSE;"
+	err := pg.replicas.read(ctx, func(db *sql.DB) error {
+		//При повторе на основной БД результат собирается заново
+		result = make([]UserURLEntity, 0)
+		rows, err := db.QueryContext(ctx, query, owner.UserID, owner.WorkspaceID)
 		if err != nil {
-			log.Printf("postgres get userUrls: %v", err)
-			return nil, err
+			return err
 		}
-		result = append(result, resultRow)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+		defer rows.Close()
+		for rows.Next() {
+			resultRow := UserURLEntity{}
+			err = rows.Scan(&resultRow.ShortURL, &resultRow.OriginalURL)
+			if err != nil {
+				logger.FromCtx(ctx).Error("postgres get userUrls", zap.Error(err))
+				return err
+			}
+			result = append(result, resultRow)
+		}
+		return rows.Err()
+	}, owner.Key())
+	if err != nil {
+		return nil, errors.New("error postgres get userUrls")
 	}
-	if count == 0 {
+	if len(result) == 0 {
 		return nil, internalerrors.ErrNotFound
 	}
-	return result, err
+	return result, nil
 }
 
-// DeleteUserURLs реализация асинхронного удаления ссылок по ИД пользователя
-func (pg *PostgresDB) DeleteUserURLs(userID string, group *sync.WaitGroup) (deletedURLs chan string, err error) {
+// DeleteUserURLs реализация асинхронного удаления ссылок владельца
+//
+// Удаление выполняется в фоне на контексте хранилища: контекст запроса к этому времени завершен.
+func (pg *PostgresDB) DeleteUserURLs(_ context.Context, owner Owner, group *sync.WaitGroup) (deletedURLs chan string, err error) {
 	deletedURLs = make(chan string)
 	tx, err := pg.db.BeginTx(pg.ctx, nil)
 	if err != nil {
@@ -202,7 +262,7 @@ func (pg *PostgresDB) DeleteUserURLs(userID string, group *sync.WaitGroup) (dele
 			tx.Rollback()
 		}
 	}()
-	query := "UPDATE URLS set is_deleted = true WHERE user_id = $1 AND short_url = ANY($2);"
+	query := "UPDATE URLS set is_deleted = true, deleted_at = now() WHERE " + ownerFilter + " AND short_url = ANY($3) AND is_deleted = FALSE;"
 	group.Add(1)
 	go func() {
 		var forDelete []string //= make([]string, 0, 10)
@@ -228,7 +288,7 @@ func (pg *PostgresDB) DeleteUserURLs(userID string, group *sync.WaitGroup) (dele
 				}
 			}
 		}
-		_, err = tx.ExecContext(pg.ctx, query, userID, forDelete)
+		_, err = tx.ExecContext(pg.ctx, query, owner.UserID, owner.WorkspaceID, forDelete)
 		if err != nil {
 			return
 		}
@@ -236,35 +296,94 @@ func (pg *PostgresDB) DeleteUserURLs(userID string, group *sync.WaitGroup) (dele
 		if err != nil {
 			return
 		}
+		pg.cache.remove(forDelete...)
+		pg.replicas.markWrite(owner.Key())
+		pg.replicas.markWrite(forDelete...)
 	}()
 
 	return deletedURLs, nil
 }
 
-// GetStats получение количества ссылок и уникальных пользователей
-func (pg *PostgresDB) GetStats() (usersCount int, URLsCount int, statError error) {
-
-	tx, err := pg.db.Begin()
-	if err != nil {
-		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer func() {
+// GetStats получение статистики из счетчиков, которые ведет триггер urls_stats
+func (pg *PostgresDB) GetStats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	err := pg.replicas.read(ctx, func(db *sql.DB) error {
+		//Снимок в одной транзакции, чтобы счетчики и рейтинг были согласованы
+		tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
 		if err != nil {
-			tx.Rollback()
+			return fmt.Errorf("failed to begin transaction: %w", err)
 		}
-	}()
-	query := "SELECT COALESCE(count(*),0) as URLsCount FROM URLS WHERE is_deleted = FALSE;"
-	row := tx.QueryRowContext(pg.ctx, query)
-	err = row.Scan(URLsCount)
+		defer tx.Rollback()
+		stats = Stats{TopUsers: make([]UserStat, 0, TopUsersLimit)}
+		query := "SELECT COALESCE(sum(total), 0), COALESCE(sum(deleted), 0), COALESCE(sum(users), 0) FROM URL_STATS_SHARDS;"
+		err = tx.QueryRowContext(ctx, query).Scan(&stats.Total, &stats.Deleted, &stats.Users)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		stats.Active = stats.Total - stats.Deleted
+		queryDay := "SELECT count(*) FROM URLS WHERE created_at > now() - interval '24 hours';"
+		err = tx.QueryRowContext(ctx, queryDay).Scan(&stats.CreatedLastDay)
+		if err != nil {
+			return err
+		}
+		queryTop := "SELECT user_id, active FROM USER_STATS WHERE active > 0 ORDER BY active DESC LIMIT $1;"
+		rows, err := tx.QueryContext(ctx, queryTop, TopUsersLimit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var us UserStat
+			if err = rows.Scan(&us.UserID, &us.URLs); err != nil {
+				return err
+			}
+			stats.TopUsers = append(stats.TopUsers, us)
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
 	if err != nil {
-		return 0, 0, err
+		return Stats{}, fmt.Errorf("failed to get stats: %w", err)
 	}
-	queryUsers := "SELECT COALESCE(count(distinct user_id),0) as usersCount FROM URLS WHERE is_deleted = FALSE;"
-	rowUsers := tx.QueryRowContext(pg.ctx, queryUsers)
-	err = rowUsers.Scan(usersCount)
+	return stats, nil
+}
+
+// GetTimeSeries события по интервалам [from, to) из почасовой таблицы STATS_HOURLY
+//
+// Переходы попадают в таблицу с задержкой до clicksFlushInterval.
+func (pg *PostgresDB) GetTimeSeries(ctx context.Context, from, to time.Time, interval time.Duration) ([]TimeBucket, error) {
+	if err := ValidateTimeRange(from, to, interval); err != nil {
+		return nil, err
+	}
+	unit := "hour"
+	if interval == IntervalDay {
+		unit = "day"
+	}
+	query := `SELECT date_trunc($1, bucket AT TIME ZONE 'UTC') AS start,
+		sum(created), sum(deleted), sum(clicked)
+		FROM STATS_HOURLY WHERE bucket >= $2 AND bucket < $3
+		GROUP BY start;`
+	sparse := make(map[time.Time]TimeBucket)
+	err := pg.replicas.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, query, unit, BucketStart(from, interval), to)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var b TimeBucket
+			if err = rows.Scan(&b.Start, &b.Created, &b.Deleted, &b.Clicked); err != nil {
+				return err
+			}
+			b.Start = time.Date(b.Start.Year(), b.Start.Month(), b.Start.Day(), b.Start.Hour(), 0, 0, 0, time.UTC)
+			sparse[b.Start] = b
+		}
+		return rows.Err()
+	})
 	if err != nil {
-		return 0, 0, err
+		return nil, fmt.Errorf("failed to get time series: %w", err)
 	}
-	tx.Commit()
-	return usersCount, URLsCount, nil
+	return FillTimeSeries(from, to, interval, sparse), nil
 }
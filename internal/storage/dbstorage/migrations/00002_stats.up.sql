@@ -0,0 +1,89 @@
+BEGIN TRANSACTION;
+ALTER TABLE URLS ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS idx_urls_created_at ON URLS(created_at);
+CREATE INDEX IF NOT EXISTS idx_urls_user_id ON URLS(user_id);
+
+-- Счетчики ссылок, поддерживаются триггером urls_stats
+CREATE TABLE IF NOT EXISTS URL_STATS
+(id smallint PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+ total bigint NOT NULL DEFAULT 0,
+ deleted bigint NOT NULL DEFAULT 0,
+ users bigint NOT NULL DEFAULT 0);
+
+-- Активные ссылки по пользователям
+CREATE TABLE IF NOT EXISTS USER_STATS
+(user_id uuid PRIMARY KEY,
+ active bigint NOT NULL DEFAULT 0);
+CREATE INDEX IF NOT EXISTS idx_user_stats_active ON USER_STATS(active DESC);
+
+INSERT INTO URL_STATS (id, total, deleted, users)
+SELECT 1,
+       count(*),
+       count(*) FILTER (WHERE COALESCE(is_deleted, FALSE)),
+       count(DISTINCT user_id) FILTER (WHERE NOT COALESCE(is_deleted, FALSE))
+FROM URLS
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO USER_STATS (user_id, active)
+SELECT user_id, count(*) FROM URLS
+WHERE NOT COALESCE(is_deleted, FALSE) AND user_id IS NOT NULL
+GROUP BY user_id
+ON CONFLICT (user_id) DO NOTHING;
+
+CREATE OR REPLACE FUNCTION urls_stats_trigger() RETURNS trigger AS $$
+DECLARE
+    delta_active bigint := 0;
+    uid uuid;
+    cur bigint;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        uid := NEW.user_id;
+        IF COALESCE(NEW.is_deleted, FALSE) THEN
+            UPDATE URL_STATS SET total = total + 1, deleted = deleted + 1 WHERE id = 1;
+        ELSE
+            UPDATE URL_STATS SET total = total + 1 WHERE id = 1;
+            delta_active := 1;
+        END IF;
+    ELSIF TG_OP = 'UPDATE' THEN
+        uid := NEW.user_id;
+        IF COALESCE(OLD.is_deleted, FALSE) = COALESCE(NEW.is_deleted, FALSE) THEN
+            RETURN NULL;
+        END IF;
+        IF COALESCE(NEW.is_deleted, FALSE) THEN
+            UPDATE URL_STATS SET deleted = deleted + 1 WHERE id = 1;
+            delta_active := -1;
+        ELSE
+            UPDATE URL_STATS SET deleted = deleted - 1 WHERE id = 1;
+            delta_active := 1;
+        END IF;
+    ELSE
+        uid := OLD.user_id;
+        IF COALESCE(OLD.is_deleted, FALSE) THEN
+            UPDATE URL_STATS SET total = total - 1, deleted = deleted - 1 WHERE id = 1;
+        ELSE
+            UPDATE URL_STATS SET total = total - 1 WHERE id = 1;
+            delta_active := -1;
+        END IF;
+    END IF;
+
+    IF uid IS NULL OR delta_active = 0 THEN
+        RETURN NULL;
+    END IF;
+    INSERT INTO USER_STATS (user_id, active) VALUES (uid, delta_active)
+    ON CONFLICT (user_id) DO UPDATE SET active = USER_STATS.active + EXCLUDED.active
+    RETURNING active INTO cur;
+    -- Пользователь появился или пропал из числа активных
+    IF delta_active > 0 AND cur = delta_active THEN
+        UPDATE URL_STATS SET users = users + 1 WHERE id = 1;
+    ELSIF delta_active < 0 AND cur = 0 THEN
+        UPDATE URL_STATS SET users = users - 1 WHERE id = 1;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS urls_stats ON URLS;
+CREATE TRIGGER urls_stats
+    AFTER INSERT OR UPDATE OF is_deleted OR DELETE ON URLS
+    FOR EACH ROW EXECUTE FUNCTION urls_stats_trigger();
+COMMIT TRANSACTION;
@@ -0,0 +1,32 @@
+BEGIN TRANSACTION;
+ALTER TABLE URLS ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+-- События по часам: созданные, удаленные ссылки и переходы
+CREATE TABLE IF NOT EXISTS STATS_HOURLY
+(bucket timestamptz PRIMARY KEY,
+ created bigint NOT NULL DEFAULT 0,
+ deleted bigint NOT NULL DEFAULT 0,
+ clicked bigint NOT NULL DEFAULT 0);
+
+INSERT INTO STATS_HOURLY (bucket, created)
+SELECT date_trunc('hour', created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', count(*) FROM URLS GROUP BY 1
+ON CONFLICT (bucket) DO NOTHING;
+
+CREATE OR REPLACE FUNCTION urls_hourly_trigger() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO STATS_HOURLY (bucket, created) VALUES (date_trunc('hour', NEW.created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', 1)
+        ON CONFLICT (bucket) DO UPDATE SET created = STATS_HOURLY.created + 1;
+    ELSIF COALESCE(NEW.is_deleted, FALSE) AND NOT COALESCE(OLD.is_deleted, FALSE) THEN
+        INSERT INTO STATS_HOURLY (bucket, deleted) VALUES (date_trunc('hour', COALESCE(NEW.deleted_at, now()) AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', 1)
+        ON CONFLICT (bucket) DO UPDATE SET deleted = STATS_HOURLY.deleted + 1;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS urls_hourly ON URLS;
+CREATE TRIGGER urls_hourly
+    AFTER INSERT OR UPDATE OF is_deleted ON URLS
+    FOR EACH ROW EXECUTE FUNCTION urls_hourly_trigger();
+COMMIT TRANSACTION;
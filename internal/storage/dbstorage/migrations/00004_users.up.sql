@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+-- Зарегистрированные пользователи, id совпадает с user_id ссылок
+CREATE TABLE IF NOT EXISTS USERS
+(id uuid PRIMARY KEY,
+ login varchar(64) NOT NULL UNIQUE,
+ password_hash varchar(100) NOT NULL,
+ created_at timestamptz NOT NULL DEFAULT now());
+COMMIT TRANSACTION;
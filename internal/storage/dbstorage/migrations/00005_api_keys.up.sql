@@ -0,0 +1,12 @@
+BEGIN TRANSACTION;
+-- Ключи API, секрет хранится как sha256, области доступа через запятую
+CREATE TABLE IF NOT EXISTS API_KEYS
+(id varchar(32) PRIMARY KEY,
+ user_id uuid NOT NULL,
+ name varchar(100) NOT NULL,
+ hash varchar(64) NOT NULL,
+ scopes varchar(100) NOT NULL,
+ created_at timestamptz NOT NULL DEFAULT now(),
+ last_used_at timestamptz);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON API_KEYS(user_id);
+COMMIT TRANSACTION;
@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+-- Пользователи внешних провайдеров входа, субъект уникален в пределах издателя
+CREATE TABLE IF NOT EXISTS USER_IDENTITIES
+(issuer text NOT NULL,
+ subject text NOT NULL,
+ user_id uuid NOT NULL,
+ created_at timestamptz NOT NULL DEFAULT now(),
+ PRIMARY KEY (issuer, subject));
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON USER_IDENTITIES(user_id);
+COMMIT TRANSACTION;
@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+-- Блокировка ссылки администратором, в отличие от удаления обратима
+ALTER TABLE URLS ADD COLUMN IF NOT EXISTS is_disabled BOOL NOT NULL DEFAULT FALSE;
+-- Роли и блокировки пользователей, пользователь без записи - user без блокировки
+CREATE TABLE IF NOT EXISTS USER_ACCESS
+(user_id uuid PRIMARY KEY,
+ role varchar(16) NOT NULL DEFAULT 'user',
+ disabled BOOL NOT NULL DEFAULT FALSE,
+ updated_at timestamptz NOT NULL DEFAULT now());
+COMMIT TRANSACTION;
@@ -0,0 +1,18 @@
+BEGIN TRANSACTION;
+-- Рабочие пространства с общими ссылками
+CREATE TABLE IF NOT EXISTS WORKSPACES
+(id uuid PRIMARY KEY,
+ name varchar(100) NOT NULL,
+ created_at timestamptz NOT NULL DEFAULT now());
+-- Участники пространств: owner, editor или viewer
+CREATE TABLE IF NOT EXISTS WORKSPACE_MEMBERS
+(workspace_id uuid NOT NULL REFERENCES WORKSPACES(id) ON DELETE CASCADE,
+ user_id uuid NOT NULL,
+ role varchar(16) NOT NULL,
+ created_at timestamptz NOT NULL DEFAULT now(),
+ PRIMARY KEY (workspace_id, user_id));
+CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON WORKSPACE_MEMBERS(user_id);
+-- Ссылка пространства, user_id остается автором; без пространства ссылка личная
+ALTER TABLE URLS ADD COLUMN IF NOT EXISTS workspace_id uuid REFERENCES WORKSPACES(id);
+CREATE INDEX IF NOT EXISTS idx_urls_workspace_id ON URLS(workspace_id) WHERE workspace_id IS NOT NULL;
+COMMIT TRANSACTION;
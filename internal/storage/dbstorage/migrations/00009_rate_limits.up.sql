@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+-- Корзины токенов ограничения запросов, общие для экземпляров сервиса.
+-- Потеря при сбое безопасна: пустая таблица означает полные корзины
+CREATE UNLOGGED TABLE IF NOT EXISTS RATE_LIMITS
+(key text PRIMARY KEY,
+ tokens double precision NOT NULL,
+ updated_at timestamptz NOT NULL DEFAULT now());
+CREATE INDEX IF NOT EXISTS rate_limits_updated_at_idx ON RATE_LIMITS (updated_at);
+COMMIT TRANSACTION;
@@ -0,0 +1,92 @@
+BEGIN TRANSACTION;
+-- Счетчики ссылок разнесены по 16 строкам, чтобы записи не ждали блокировку одной строки URL_STATS.
+-- Итог - сумма по всем строкам, строка выбирается по хэшу короткой ссылки или пользователя
+LOCK TABLE URLS IN SHARE ROW EXCLUSIVE MODE;
+
+CREATE TABLE IF NOT EXISTS URL_STATS_SHARDS
+(shard smallint PRIMARY KEY CHECK (shard >= 0 AND shard < 16),
+ total bigint NOT NULL DEFAULT 0,
+ deleted bigint NOT NULL DEFAULT 0,
+ users bigint NOT NULL DEFAULT 0);
+
+-- Анонимный владелец uuid.Nil не считается пользователем
+DELETE FROM USER_STATS WHERE user_id = '00000000-0000-0000-0000-000000000000';
+
+INSERT INTO URL_STATS_SHARDS (shard)
+SELECT generate_series(0, 15)
+ON CONFLICT (shard) DO NOTHING;
+
+UPDATE URL_STATS_SHARDS
+SET total   = s.total,
+    deleted = s.deleted,
+    users   = s.users
+FROM (SELECT count(*) AS total,
+             count(*) FILTER (WHERE COALESCE(is_deleted, FALSE)) AS deleted,
+             (SELECT count(*) FROM USER_STATS WHERE active > 0) AS users
+      FROM URLS) s
+WHERE shard = 0;
+
+CREATE OR REPLACE FUNCTION urls_stats_trigger() RETURNS trigger AS $$
+DECLARE
+    delta_active bigint := 0;
+    delta_total bigint := 0;
+    delta_deleted bigint := 0;
+    uid uuid;
+    link text;
+    cur bigint;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        uid := NEW.user_id;
+        link := NEW.short_url;
+        delta_total := 1;
+        IF COALESCE(NEW.is_deleted, FALSE) THEN
+            delta_deleted := 1;
+        ELSE
+            delta_active := 1;
+        END IF;
+    ELSIF TG_OP = 'UPDATE' THEN
+        uid := NEW.user_id;
+        link := NEW.short_url;
+        IF COALESCE(OLD.is_deleted, FALSE) = COALESCE(NEW.is_deleted, FALSE) THEN
+            RETURN NULL;
+        END IF;
+        IF COALESCE(NEW.is_deleted, FALSE) THEN
+            delta_deleted := 1;
+            delta_active := -1;
+        ELSE
+            delta_deleted := -1;
+            delta_active := 1;
+        END IF;
+    ELSE
+        uid := OLD.user_id;
+        link := OLD.short_url;
+        delta_total := -1;
+        IF COALESCE(OLD.is_deleted, FALSE) THEN
+            delta_deleted := -1;
+        ELSE
+            delta_active := -1;
+        END IF;
+    END IF;
+
+    UPDATE URL_STATS_SHARDS
+    SET total = total + delta_total, deleted = deleted + delta_deleted
+    WHERE shard = hashtext(link) & 15;
+
+    IF uid IS NULL OR uid = '00000000-0000-0000-0000-000000000000'::uuid OR delta_active = 0 THEN
+        RETURN NULL;
+    END IF;
+    INSERT INTO USER_STATS (user_id, active) VALUES (uid, delta_active)
+    ON CONFLICT (user_id) DO UPDATE SET active = USER_STATS.active + EXCLUDED.active
+    RETURNING active INTO cur;
+    -- Пользователь появился или пропал из числа активных
+    IF delta_active > 0 AND cur = delta_active THEN
+        UPDATE URL_STATS_SHARDS SET users = users + 1 WHERE shard = hashtext(uid::text) & 15;
+    ELSIF delta_active < 0 AND cur = 0 THEN
+        UPDATE URL_STATS_SHARDS SET users = users - 1 WHERE shard = hashtext(uid::text) & 15;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE IF EXISTS URL_STATS;
+COMMIT TRANSACTION;
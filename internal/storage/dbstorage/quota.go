@@ -0,0 +1,30 @@
+// Подсчет ссылок для квот пользователей
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// CountUserURLs активные ссылки пользователя из счетчиков USER_STATS
+//
+// Читается с основной БД: сразу после сохранения реплика может отставать и пропустить превышение.
+func (pg *PostgresDB) CountUserURLs(ctx context.Context, userID string) (int, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		//Ссылки сохраняются только для пользователей с ИД uuid
+		return 0, nil
+	}
+	var active int
+	err := pg.db.QueryRowContext(ctx, "SELECT active FROM USER_STATS WHERE user_id = $1", userID).Scan(&active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to count user urls: %w", err)
+	}
+	return active, nil
+}
@@ -0,0 +1,66 @@
+// Корзины токенов ограничения запросов для нескольких экземпляров сервиса
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Очистка корзин: давно не использованная корзина полная и равна отсутствующей
+const (
+	rateLimitsPurgeInterval = 10 * time.Minute
+	rateLimitsIdle          = time.Hour
+)
+
+// refillExpr остаток токенов корзины b на текущий момент по времени БД
+const refillExpr = `LEAST($2::float8, b.tokens + GREATEST(0, EXTRACT(EPOCH FROM (now() - b.updated_at)))::float8 * $3::float8)`
+
+// TakeToken списание токена одним запросом, время берется из БД, а не с экземпляров сервиса
+//
+// Без токена корзина не меняется, остаток читается отдельно для расчета ожидания.
+func (pg *PostgresDB) TakeToken(ctx context.Context, key string, bucket TokenBucket) (bool, time.Duration, error) {
+	query := `INSERT INTO RATE_LIMITS AS b (key, tokens, updated_at) VALUES ($1, $2::float8 - 1, now())
+		ON CONFLICT (key) DO UPDATE SET tokens = ` + refillExpr + ` - 1, updated_at = now()
+		WHERE ` + refillExpr + ` >= 1
+		RETURNING b.tokens`
+	var tokens float64
+	err := pg.db.QueryRowContext(ctx, query, key, bucket.Burst, bucket.Rate).Scan(&tokens)
+	if err == nil {
+		return true, 0, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, 0, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	query = `SELECT ` + refillExpr + ` FROM RATE_LIMITS b WHERE b.key = $1`
+	err = pg.db.QueryRowContext(ctx, query, key, bucket.Burst, bucket.Rate).Scan(&tokens)
+	if errors.Is(err, sql.ErrNoRows) {
+		//Корзину удалили между запросами, следующая попытка пройдет
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to read rate limit bucket: %w", err)
+	}
+	return false, bucket.Wait(tokens), nil
+}
+
+// purgeRateLimits периодическое удаление неиспользуемых корзин до отмены контекста
+func (pg *PostgresDB) purgeRateLimits(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitsPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := pg.db.ExecContext(ctx, "DELETE FROM RATE_LIMITS WHERE updated_at < $1", time.Now().Add(-rateLimitsIdle))
+			if err != nil && ctx.Err() == nil {
+				pg.lg.Error("failed to purge rate limits", zap.Error(err))
+			}
+		}
+	}
+}
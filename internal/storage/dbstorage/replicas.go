@@ -0,0 +1,201 @@
+// Маршрутизация чтения между основной БД и репликами
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Настройки проверки реплик
+const (
+	healthCheckInterval = 5 * time.Second // период проверки доступности реплик
+	healthCheckTimeout  = 2 * time.Second // таймаут одной проверки
+)
+
+// replica соединение с репликой и признак ее доступности
+type replica struct {
+	db      *sql.DB
+	name    string // порядковое имя для логов, DSN содержит пароль
+	healthy atomic.Bool
+}
+
+// replicaSet распределяет чтение между репликами
+//
+// При недоступности всех реплик чтение выполняется на основной БД.
+// Для read-your-writes ключи недавних записей (пользователь, короткая ссылка)
+// запоминаются на окно window, и чтение по ним уходит на основную БД.
+type replicaSet struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint32
+	window   time.Duration
+	writes   sync.Map // ключ записи -> time.Time окончания окна
+	lg       *zap.Logger
+}
+
+// newReplicaSet открывает соединения с репликами и запускает проверку их доступности
+func newReplicaSet(ctx context.Context, primary *sql.DB, dsns []string, window time.Duration, lg *zap.Logger) (*replicaSet, error) {
+	rs := &replicaSet{primary: primary, window: window, lg: lg}
+	for i, dsn := range dsns {
+		if dsn == "" {
+			continue
+		}
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			rs.close()
+			return nil, fmt.Errorf("failed to open connection to replica %d: %w", i, err)
+		}
+		r := &replica{db: db, name: fmt.Sprintf("replica-%d", i)}
+		//Недоступная при старте реплика не мешает запуску, ее вернет проверка
+		r.healthy.Store(r.ping(ctx) == nil)
+		rs.replicas = append(rs.replicas, r)
+	}
+	if len(rs.replicas) > 0 {
+		go rs.healthCheck(ctx)
+	}
+	return rs, nil
+}
+
+// ping проверка соединения с репликой
+func (r *replica) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	return r.db.PingContext(ctx)
+}
+
+// healthCheck периодически обновляет состояние реплик до отмены контекста
+func (rs *replicaSet) healthCheck(ctx context.Context) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, r := range rs.replicas {
+				rs.setHealthy(r, r.ping(ctx) == nil)
+			}
+			rs.pruneWrites(time.Now())
+		}
+	}
+}
+
+// setHealthy меняет состояние реплики и логирует переход
+func (rs *replicaSet) setHealthy(r *replica, healthy bool) {
+	if r.healthy.Swap(healthy) != healthy {
+		if healthy {
+			rs.lg.Info("replica is back online", zap.String("replica", r.name))
+		} else {
+			rs.lg.Warn("replica is unavailable, reads go to primary", zap.String("replica", r.name))
+		}
+	}
+}
+
+// markWrite запоминает ключи записи на окно read-your-writes
+func (rs *replicaSet) markWrite(keys ...string) {
+	if rs.window <= 0 || len(rs.replicas) == 0 {
+		return
+	}
+	until := time.Now().Add(rs.window)
+	for _, k := range keys {
+		if k != "" {
+			rs.writes.Store(k, until)
+		}
+	}
+}
+
+// recentlyWritten проверяет, была ли запись по одному из ключей в пределах окна
+func (rs *replicaSet) recentlyWritten(keys ...string) bool {
+	now := time.Now()
+	for _, k := range keys {
+		v, ok := rs.writes.Load(k)
+		if !ok {
+			continue
+		}
+		if now.Before(v.(time.Time)) {
+			return true
+		}
+		rs.writes.CompareAndDelete(k, v)
+	}
+	return false
+}
+
+// pruneWrites удаляет ключи с истекшим окном, к которым не было повторного чтения
+func (rs *replicaSet) pruneWrites(now time.Time) {
+	rs.writes.Range(func(k, v any) bool {
+		if !now.Before(v.(time.Time)) {
+			rs.writes.CompareAndDelete(k, v)
+		}
+		return true
+	})
+}
+
+// pick выбирает доступную реплику по кругу, nil если доступных нет
+func (rs *replicaSet) pick() *replica {
+	n := len(rs.replicas)
+	if n == 0 {
+		return nil
+	}
+	start := rs.next.Add(1)
+	for i := 0; i < n; i++ {
+		r := rs.replicas[(int(start)+i)%n]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// read выполняет fn на реплике, при сбое реплики повторяет на основной БД
+//
+// keys - ключи для read-your-writes, при недавней записи по ним реплики не используются.
+func (rs *replicaSet) read(ctx context.Context, fn func(db *sql.DB) error, keys ...string) error {
+	return rs.readOn(ctx, fn, false, keys...)
+}
+
+// readExisting как read, но отсутствие строки на реплике перепроверяется на основной БД
+//
+// Свежая запись другого клиента или экземпляра может еще не дойти до реплики.
+func (rs *replicaSet) readExisting(ctx context.Context, fn func(db *sql.DB) error, keys ...string) error {
+	return rs.readOn(ctx, fn, true, keys...)
+}
+
+// readOn выбор базы для чтения, retryMissing - повтор sql.ErrNoRows реплики на основной БД
+func (rs *replicaSet) readOn(ctx context.Context, fn func(db *sql.DB) error, retryMissing bool, keys ...string) error {
+	if rs.recentlyWritten(keys...) {
+		return fn(rs.primary)
+	}
+	r := rs.pick()
+	if r == nil {
+		return fn(rs.primary)
+	}
+	err := fn(r.db)
+	if errors.Is(err, sql.ErrNoRows) && retryMissing {
+		return fn(rs.primary)
+	}
+	if err == nil || errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	//Ошибку запроса отличаем от сбоя реплики проверкой соединения
+	if pingErr := r.ping(ctx); pingErr != nil {
+		rs.setHealthy(r, false)
+		return fn(rs.primary)
+	}
+	return err
+}
+
+// close закрывает соединения с репликами
+func (rs *replicaSet) close() {
+	for _, r := range rs.replicas {
+		if err := r.db.Close(); err != nil {
+			rs.lg.Error("Error closing replica connection", zap.String("replica", r.name), zap.Error(err))
+		}
+	}
+}
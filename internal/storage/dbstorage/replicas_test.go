@@ -0,0 +1,27 @@
+package dbstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneWrites(t *testing.T) {
+	rs := &replicaSet{replicas: []*replica{{name: "replica-0"}}, window: time.Minute}
+	rs.markWrite("user", "short")
+	rs.writes.Store("stale", time.Now().Add(-time.Second))
+
+	rs.pruneWrites(time.Now())
+	_, ok := rs.writes.Load("stale")
+	assert.False(t, ok)
+	assert.True(t, rs.recentlyWritten("short"))
+
+	rs.pruneWrites(time.Now().Add(2 * time.Minute))
+	count := 0
+	rs.writes.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+	assert.Zero(t, count)
+}
@@ -0,0 +1,59 @@
+// Модель временных рядов статистики
+package dbstorage
+
+import (
+	"errors"
+	"time"
+)
+
+// Интервалы временного ряда статистики
+const (
+	IntervalHour = time.Hour
+	IntervalDay  = 24 * time.Hour
+)
+
+// MaxTimeSeriesBuckets ограничение на количество интервалов в одном запросе
+const MaxTimeSeriesBuckets = 24 * 366
+
+// ErrBadTimeRange некорректные границы или интервал временного ряда
+var ErrBadTimeRange = errors.New("bad time range")
+
+// TimeBucket события за один интервал временного ряда
+type TimeBucket struct {
+	Start   time.Time // начало интервала, UTC
+	Created int       // созданные ссылки
+	Deleted int       // удаленные ссылки
+	Clicked int       // переходы по ссылкам
+}
+
+// BucketStart начало интервала, в который попадает t
+func BucketStart(t time.Time, interval time.Duration) time.Time {
+	return t.UTC().Truncate(interval)
+}
+
+// ValidateTimeRange проверка границ [from, to) и интервала временного ряда
+func ValidateTimeRange(from, to time.Time, interval time.Duration) error {
+	if interval != IntervalHour && interval != IntervalDay {
+		return ErrBadTimeRange
+	}
+	if !from.Before(to) {
+		return ErrBadTimeRange
+	}
+	if to.Sub(BucketStart(from, interval))/interval > MaxTimeSeriesBuckets {
+		return ErrBadTimeRange
+	}
+	return nil
+}
+
+// FillTimeSeries непрерывный ряд интервалов [from, to), пропуски заполняются нулями
+//
+// sparse - интервалы с событиями, ключ - начало интервала.
+func FillTimeSeries(from, to time.Time, interval time.Duration, sparse map[time.Time]TimeBucket) []TimeBucket {
+	result := make([]TimeBucket, 0)
+	for start := BucketStart(from, interval); start.Before(to); start = start.Add(interval) {
+		b := sparse[start]
+		b.Start = start
+		result = append(result, b)
+	}
+	return result
+}
@@ -0,0 +1,114 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/SversusN/shortener/internal/internalerrors"
+)
+
+// CreateUser регистрация пользователя, логин уникален
+func (pg *PostgresDB) CreateUser(ctx context.Context, u User) error {
+	query := "INSERT INTO USERS (id, login, password_hash) VALUES ($1, $2, $3) ON CONFLICT (login) DO NOTHING"
+	res, err := pg.db.ExecContext(ctx, query, u.ID, u.Login, u.PasswordHash)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	if n == 0 {
+		return internalerrors.ErrLoginTaken
+	}
+	return nil
+}
+
+// GetUserByLogin пользователь по логину
+//
+// Читается с основной БД: вход сразу после регистрации не должен зависеть от отставания реплик.
+func (pg *PostgresDB) GetUserByLogin(ctx context.Context, login string) (User, error) {
+	var u User
+	query := "SELECT id, login, password_hash, created_at FROM USERS WHERE login = $1"
+	err := pg.db.QueryRowContext(ctx, query, login).Scan(&u.ID, &u.Login, &u.PasswordHash, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, internalerrors.ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("failed to query user: %w", err)
+	}
+	return u, nil
+}
+
+// ClaimURLs перенос ссылок анонимного пользователя в учетную запись
+//
+// Выполняется одной транзакцией вместе со счетчиками USER_STATS и URL_STATS_SHARDS:
+// триггер urls_stats не отслеживает смену владельца.
+func (pg *PostgresDB) ClaimURLs(ctx context.Context, fromUserID, toUserID string) (claimed int, err error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	var registered bool
+	query := "SELECT EXISTS(SELECT 1 FROM USERS WHERE id = $1) OR EXISTS(SELECT 1 FROM USER_IDENTITIES WHERE user_id = $1)"
+	if err = tx.QueryRowContext(ctx, query, fromUserID).Scan(&registered); err != nil {
+		return 0, fmt.Errorf("failed to check user: %w", err)
+	}
+	if registered {
+		return 0, internalerrors.ErrNotAnonymous
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE URLS SET user_id = $2 WHERE user_id = $1", fromUserID, toUserID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim urls: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim urls: %w", err)
+	}
+	var active int64
+	query = "DELETE FROM USER_STATS WHERE user_id = $1 RETURNING active"
+	if scanErr := tx.QueryRowContext(ctx, query, fromUserID).Scan(&active); scanErr != nil && !errors.Is(scanErr, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to update user stats: %w", scanErr)
+	}
+	if active > 0 {
+		var total int64
+		query = `INSERT INTO USER_STATS (user_id, active) VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET active = USER_STATS.active + EXCLUDED.active RETURNING active`
+		if err = tx.QueryRowContext(ctx, query, toUserID, active).Scan(&total); err != nil {
+			return 0, fmt.Errorf("failed to update user stats: %w", err)
+		}
+		//Оба пользователя были активными, теперь остался один
+		if total > active {
+			query = "UPDATE URL_STATS_SHARDS SET users = users - 1 WHERE shard = hashtext($1::text) & 15"
+			if _, err = tx.ExecContext(ctx, query, toUserID); err != nil {
+				return 0, fmt.Errorf("failed to update stats: %w", err)
+			}
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	pg.replicas.markWrite(toUserID)
+	return int(n), nil
+}
+
+// GetOrCreateIdentity ИД пользователя для субъекта издателя, при первом входе субъект связывается с identity.UserID
+//
+// При одновременном первом входе запись создает один запрос, второй получает его ИД.
+func (pg *PostgresDB) GetOrCreateIdentity(ctx context.Context, identity Identity) (string, error) {
+	query := `INSERT INTO USER_IDENTITIES (issuer, subject, user_id) VALUES ($1, $2, $3)
+		ON CONFLICT (issuer, subject) DO UPDATE SET issuer = EXCLUDED.issuer
+		RETURNING user_id`
+	var userID string
+	if err := pg.db.QueryRowContext(ctx, query, identity.Issuer, identity.Subject, identity.UserID).Scan(&userID); err != nil {
+		return "", fmt.Errorf("failed to link identity: %w", err)
+	}
+	return userID, nil
+}
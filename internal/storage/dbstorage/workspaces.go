@@ -0,0 +1,181 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/SversusN/shortener/internal/internalerrors"
+)
+
+// CreateWorkspace сохранение пространства и первого участника одной транзакцией
+func (pg *PostgresDB) CreateWorkspace(ctx context.Context, ws Workspace, owner WorkspaceMember) (err error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if _, err = tx.ExecContext(ctx, "INSERT INTO WORKSPACES (id, name, created_at) VALUES ($1, $2, $3)", ws.ID, ws.Name, ws.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+	query := "INSERT INTO WORKSPACE_MEMBERS (workspace_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)"
+	if _, err = tx.ExecContext(ctx, query, ws.ID, owner.UserID, owner.Role, ws.CreatedAt); err != nil {
+		return fmt.Errorf("failed to add workspace owner: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetWorkspaceMember участник пространства
+//
+// Читается с основной БД: исключенный участник не должен сохранять доступ из-за отставания реплик.
+func (pg *PostgresDB) GetWorkspaceMember(ctx context.Context, workspaceID, userID string) (WorkspaceMember, error) {
+	m := WorkspaceMember{WorkspaceID: workspaceID, UserID: userID}
+	query := "SELECT role, created_at FROM WORKSPACE_MEMBERS WHERE workspace_id = $1 AND user_id = $2"
+	err := pg.db.QueryRowContext(ctx, query, workspaceID, userID).Scan(&m.Role, &m.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return WorkspaceMember{}, internalerrors.ErrNotFound
+	}
+	if err != nil {
+		return WorkspaceMember{}, fmt.Errorf("failed to query workspace member: %w", err)
+	}
+	return m, nil
+}
+
+// ListWorkspaces пространства пользователя по времени создания
+func (pg *PostgresDB) ListWorkspaces(ctx context.Context, userID string) ([]WorkspaceMembership, error) {
+	query := `SELECT w.id, w.name, w.created_at, m.role FROM WORKSPACES w
+		JOIN WORKSPACE_MEMBERS m ON m.workspace_id = w.id
+		WHERE m.user_id = $1 ORDER BY w.created_at`
+	rows, err := pg.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workspaces: %w", err)
+	}
+	defer rows.Close()
+	result := make([]WorkspaceMembership, 0)
+	for rows.Next() {
+		var w WorkspaceMembership
+		if err := rows.Scan(&w.ID, &w.Name, &w.CreatedAt, &w.Role); err != nil {
+			return nil, fmt.Errorf("failed to scan workspace: %w", err)
+		}
+		result = append(result, w)
+	}
+	return result, rows.Err()
+}
+
+// ListWorkspaceMembers участники пространства по времени добавления
+func (pg *PostgresDB) ListWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	query := "SELECT user_id, role, created_at FROM WORKSPACE_MEMBERS WHERE workspace_id = $1 ORDER BY created_at"
+	rows, err := pg.db.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workspace members: %w", err)
+	}
+	defer rows.Close()
+	result := make([]WorkspaceMember, 0)
+	for rows.Next() {
+		m := WorkspaceMember{WorkspaceID: workspaceID}
+		if err := rows.Scan(&m.UserID, &m.Role, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan workspace member: %w", err)
+		}
+		result = append(result, m)
+	}
+	return result, rows.Err()
+}
+
+// SetWorkspaceMember добавление участника или смена его роли
+//
+// Пространство блокируется на время транзакции: два владельца, понижающие друг друга,
+// не могут оба оставить его без владельца.
+func (pg *PostgresDB) SetWorkspaceMember(ctx context.Context, m WorkspaceMember) (err error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if err = lockWorkspace(ctx, tx, m.WorkspaceID); err != nil {
+		return err
+	}
+	if m.Role != WorkspaceRoleOwner {
+		if err = keepOwner(ctx, tx, m.WorkspaceID, m.UserID); err != nil {
+			return err
+		}
+	}
+	query := `INSERT INTO WORKSPACE_MEMBERS (workspace_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role`
+	if _, err = tx.ExecContext(ctx, query, m.WorkspaceID, m.UserID, m.Role); err != nil {
+		return fmt.Errorf("failed to set workspace member: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RemoveWorkspaceMember исключение участника, последний владелец не исключается
+func (pg *PostgresDB) RemoveWorkspaceMember(ctx context.Context, workspaceID, userID string) (err error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if err = lockWorkspace(ctx, tx, workspaceID); err != nil {
+		return err
+	}
+	if err = keepOwner(ctx, tx, workspaceID, userID); err != nil {
+		return err
+	}
+	query := "DELETE FROM WORKSPACE_MEMBERS WHERE workspace_id = $1 AND user_id = $2"
+	res, err := tx.ExecContext(ctx, query, workspaceID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove workspace member: %w", err)
+	}
+	if n, rowsErr := res.RowsAffected(); rowsErr != nil || n == 0 {
+		return internalerrors.ErrNotFound
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// lockWorkspace блокировка строки пространства до конца транзакции, отсутствие - internalerrors.ErrNotFound
+func lockWorkspace(ctx context.Context, tx *sql.Tx, workspaceID string) error {
+	var id string
+	err := tx.QueryRowContext(ctx, "SELECT id FROM WORKSPACES WHERE id = $1 FOR UPDATE", workspaceID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return internalerrors.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock workspace: %w", err)
+	}
+	return nil
+}
+
+// keepOwner после понижения или исключения userID в пространстве останется владелец
+func keepOwner(ctx context.Context, tx *sql.Tx, workspaceID, userID string) error {
+	query := `SELECT EXISTS(SELECT 1 FROM WORKSPACE_MEMBERS WHERE workspace_id = $1 AND user_id = $2 AND role = $3)
+		AND NOT EXISTS(SELECT 1 FROM WORKSPACE_MEMBERS WHERE workspace_id = $1 AND user_id <> $2 AND role = $3)`
+	var last bool
+	if err := tx.QueryRowContext(ctx, query, workspaceID, userID, WorkspaceRoleOwner).Scan(&last); err != nil {
+		return fmt.Errorf("failed to check workspace owners: %w", err)
+	}
+	if last {
+		return internalerrors.ErrLastOwner
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package primitivestorage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SversusN/shortener/internal/internalerrors"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+)
+
+// GetUserAccess роль и блокировка пользователя
+func (m *MapStorage) GetUserAccess(_ context.Context, userID string) (entity.UserAccess, error) {
+	m.users.mu.RLock()
+	defer m.users.mu.RUnlock()
+	access, ok := m.users.access[userID]
+	if !ok {
+		return entity.UserAccess{UserID: userID}, nil
+	}
+	return access, nil
+}
+
+// SetUserAccess сохранение роли и блокировки пользователя
+func (m *MapStorage) SetUserAccess(_ context.Context, access entity.UserAccess) error {
+	m.users.mu.Lock()
+	defer m.users.mu.Unlock()
+	if err := m.users.append(userRecord{Access: &access}); err != nil {
+		return err
+	}
+	m.users.access[access.UserID] = access
+	return nil
+}
+
+// ListURLs ссылки всех пользователей, новые первыми
+func (m *MapStorage) ListURLs(_ context.Context, filter entity.URLFilter) ([]entity.URLInfo, error) {
+	m.claimMu.RLock()
+	defer m.claimMu.RUnlock()
+	query := strings.ToLower(filter.Query)
+	result := make([]entity.URLInfo, 0)
+	m.data.Range(func(key, value interface{}) bool {
+		u := value.(entity.UserURL)
+		if filter.UserID != "" && u.UserID != filter.UserID {
+			return true
+		}
+		if query != "" && !strings.Contains(strings.ToLower(key.(string)), query) &&
+			!strings.Contains(strings.ToLower(u.OriginalURL), query) {
+			return true
+		}
+		result = append(result, entity.URLInfo{ShortURL: key.(string), UserURL: u})
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ShortURL < result[j].ShortURL
+	})
+	return page(result, filter.Limit, filter.Offset), nil
+}
+
+// SetURLDisabled блокировка ссылки
+func (m *MapStorage) SetURLDisabled(_ context.Context, shortURL string, disabled bool) error {
+	for {
+		value, ok := m.data.Load(shortURL)
+		if !ok {
+			return internalerrors.ErrNotFound
+		}
+		u := value.(entity.UserURL)
+		u.IsDisabled = disabled
+		if m.data.CompareAndSwap(shortURL, value, u) {
+			break
+		}
+	}
+	if m.helper != nil {
+		return m.helper.RMFile(m.data)
+	}
+	return nil
+}
+
+// DeleteURL удаление ссылки любого пользователя, повторное удаление не меняет время удаления
+func (m *MapStorage) DeleteURL(_ context.Context, shortURL string) error {
+	for {
+		value, ok := m.data.Load(shortURL)
+		if !ok {
+			return internalerrors.ErrNotFound
+		}
+		u := value.(entity.UserURL)
+		if u.IsDeleted {
+			return nil
+		}
+		u.IsDeleted = true
+		u.DeletedAt = time.Now()
+		if m.data.CompareAndSwap(shortURL, value, u) {
+			m.stats.markDeleted(u.UserID, u.DeletedAt)
+			break
+		}
+	}
+	if m.helper != nil {
+		return m.helper.RMFile(m.data)
+	}
+	return nil
+}
+
+// ListUsers пользователи с паролем и пользователи провайдеров входа, новые первыми
+func (m *MapStorage) ListUsers(_ context.Context, filter entity.UserFilter) ([]entity.UserInfo, error) {
+	m.users.mu.RLock()
+	defer m.users.mu.RUnlock()
+	query := strings.ToLower(filter.Query)
+	result := make([]entity.UserInfo, 0)
+	for _, u := range m.users.byLogin {
+		if query == "" || strings.Contains(strings.ToLower(u.Login), query) || u.ID == filter.Query {
+			access := m.users.access[u.ID]
+			result = append(result, entity.UserInfo{ID: u.ID, Login: u.Login, Role: access.Role, Disabled: access.Disabled, CreatedAt: u.CreatedAt})
+		}
+	}
+	for _, i := range m.users.identities {
+		if query == "" || strings.Contains(strings.ToLower(i.Subject), query) || i.UserID == filter.Query {
+			access := m.users.access[i.UserID]
+			result = append(result, entity.UserInfo{ID: i.UserID, Issuer: i.Issuer, Subject: i.Subject,
+				Role: access.Role, Disabled: access.Disabled, CreatedAt: i.CreatedAt})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return page(result, filter.Limit, filter.Offset), nil
+}
+
+// DeleteUser удаление пользователя, его ключей API, участия в пространствах и личных ссылок,
+// ИД остается заблокированным; ссылки пространств остаются у пространств
+//
+// Владение пространствами последнего владельца переходит самому давнему участнику,
+// ссылки пространств без других участников удаляются.
+func (m *MapStorage) DeleteUser(_ context.Context, userID string) (int, error) {
+	m.users.mu.Lock()
+	owners, abandoned := m.users.heirs(userID)
+	for i := range owners {
+		if err := m.users.append(userRecord{Member: &owners[i]}); err != nil {
+			m.users.mu.Unlock()
+			return 0, err
+		}
+		m.users.setMember(owners[i])
+	}
+	access := m.users.access[userID]
+	access.UserID, access.Disabled = userID, true
+	err := m.users.append(userRecord{DeletedUser: userID})
+	if err == nil {
+		err = m.users.append(userRecord{Access: &access})
+	}
+	if err != nil {
+		m.users.mu.Unlock()
+		return 0, err
+	}
+	m.users.remove(userID)
+	m.users.access[userID] = access
+	m.users.mu.Unlock()
+
+	if err := m.deleteUserAPIKeys(userID); err != nil {
+		return 0, err
+	}
+
+	m.claimMu.Lock()
+	defer m.claimMu.Unlock()
+	deleted := 0
+	m.data.Range(func(key, value interface{}) bool {
+		for {
+			u := value.(entity.UserURL)
+			personal := u.UserID == userID && u.WorkspaceID == ""
+			if !(personal || abandoned[u.WorkspaceID]) || u.IsDeleted {
+				return true
+			}
+			u.IsDeleted = true
+			u.DeletedAt = time.Now()
+			if m.data.CompareAndSwap(key, value, u) {
+				m.stats.markDeleted(u.UserID, u.DeletedAt)
+				deleted++
+				return true
+			}
+			value, _ = m.data.Load(key)
+		}
+	})
+	if deleted > 0 && m.helper != nil {
+		if err := m.helper.RMFile(m.data); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// remove удаление логина, субъектов провайдеров и участия в пространствах пользователя,
+// вызывается под блокировкой mu
+//
+// ИД остается в ids: ссылки удаленного пользователя нельзя перенести как анонимные.
+func (s *userStore) remove(userID string) {
+	for login, u := range s.byLogin {
+		if u.ID == userID {
+			delete(s.byLogin, login)
+		}
+	}
+	for key, i := range s.identities {
+		if i.UserID == userID {
+			delete(s.identities, key)
+		}
+	}
+	for _, members := range s.members {
+		delete(members, userID)
+	}
+}
+
+// deleteUserAPIKeys отзыв всех ключей API пользователя
+func (m *MapStorage) deleteUserAPIKeys(userID string) error {
+	m.apiKeys.mu.Lock()
+	defer m.apiKeys.mu.Unlock()
+	removed := false
+	for id, k := range m.apiKeys.keys {
+		if k.UserID == userID {
+			delete(m.apiKeys.keys, id)
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	return m.apiKeys.save()
+}
+
+// page страница списка по смещению и размеру, limit 0 - без ограничения
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return items[:0]
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
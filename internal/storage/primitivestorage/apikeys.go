@@ -0,0 +1,134 @@
+package primitivestorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SversusN/shortener/internal/internalerrors"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+)
+
+// apiKeyStore ключи API в памяти со снимком в файле
+//
+// Снимок перезаписывается при создании и отзыве ключа, время использования
+// попадает в файл со следующим снимком.
+type apiKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]entity.APIKey
+	path string // пустой - ключи только в памяти
+}
+
+// newAPIKeyStore пустое хранилище ключей в памяти
+func newAPIKeyStore() *apiKeyStore {
+	return &apiKeyStore{keys: make(map[string]entity.APIKey)}
+}
+
+// OpenAPIKeys загрузка ключей API из файла и сохранение в него изменений
+func (m *MapStorage) OpenAPIKeys(path string) error {
+	var keys []entity.APIKey
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("read api keys file: %w", err)
+	case len(data) > 0:
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return fmt.Errorf("parse api keys file: %w", err)
+		}
+	}
+	m.apiKeys.mu.Lock()
+	defer m.apiKeys.mu.Unlock()
+	for _, k := range keys {
+		m.apiKeys.keys[k.ID] = k
+	}
+	m.apiKeys.path = path
+	return m.apiKeys.save()
+}
+
+// save запись снимка ключей во временный файл и замена им основного, вызывается под блокировкой
+func (ks *apiKeyStore) save() error {
+	if ks.path == "" {
+		return nil
+	}
+	keys := make([]entity.APIKey, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		keys = append(keys, k)
+	}
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	tmp := ks.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write api keys file: %w", err)
+	}
+	return os.Rename(tmp, ks.path)
+}
+
+// CreateAPIKey сохранение ключа API
+func (m *MapStorage) CreateAPIKey(_ context.Context, k entity.APIKey) error {
+	m.apiKeys.mu.Lock()
+	defer m.apiKeys.mu.Unlock()
+	if k.CreatedAt.IsZero() {
+		k.CreatedAt = time.Now()
+	}
+	m.apiKeys.keys[k.ID] = k
+	if err := m.apiKeys.save(); err != nil {
+		delete(m.apiKeys.keys, k.ID)
+		return err
+	}
+	return nil
+}
+
+// GetAPIKey ключ API по ИД
+func (m *MapStorage) GetAPIKey(_ context.Context, id string) (entity.APIKey, error) {
+	m.apiKeys.mu.RLock()
+	defer m.apiKeys.mu.RUnlock()
+	k, ok := m.apiKeys.keys[id]
+	if !ok {
+		return entity.APIKey{}, internalerrors.ErrNotFound
+	}
+	return k, nil
+}
+
+// ListAPIKeys ключи API пользователя по времени создания
+func (m *MapStorage) ListAPIKeys(_ context.Context, userID string) ([]entity.APIKey, error) {
+	m.apiKeys.mu.RLock()
+	defer m.apiKeys.mu.RUnlock()
+	keys := make([]entity.APIKey, 0)
+	for _, k := range m.apiKeys.keys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// DeleteAPIKey отзыв ключа API пользователя
+func (m *MapStorage) DeleteAPIKey(_ context.Context, userID, id string) error {
+	m.apiKeys.mu.Lock()
+	defer m.apiKeys.mu.Unlock()
+	k, ok := m.apiKeys.keys[id]
+	if !ok || k.UserID != userID {
+		return internalerrors.ErrNotFound
+	}
+	delete(m.apiKeys.keys, id)
+	return m.apiKeys.save()
+}
+
+// TouchAPIKey обновление времени последнего использования ключа API
+func (m *MapStorage) TouchAPIKey(_ context.Context, id string, at time.Time) error {
+	m.apiKeys.mu.Lock()
+	defer m.apiKeys.mu.Unlock()
+	if k, ok := m.apiKeys.keys[id]; ok {
+		k.LastUsedAt = at
+		m.apiKeys.keys[id] = k
+	}
+	return nil
+}
@@ -2,63 +2,106 @@
 package primitivestorage
 
 import (
+	"context"
 	"errors"
-	"log"
 	"sync"
+	"time"
+
+	"go.uber.org/zap"
 
 	"github.com/SversusN/shortener/internal/internalerrors"
+	"github.com/SversusN/shortener/internal/logger"
 	"github.com/SversusN/shortener/internal/pkg/utils"
 	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
 )
 
 // MapStorage cnhernehf c потокобезопасной map и файлом
 type MapStorage struct {
-	data   *sync.Map
-	helper *utils.FileHelper
+	data    *sync.Map
+	helper  *utils.FileHelper
+	stats   *statsCounters
+	users   *userStore
+	apiKeys *apiKeyStore
+	//claimMu переносы ссылок между пользователями, GetUserUrls ждет их завершения
+	claimMu sync.RWMutex
 }
 
 // NewStorage хелпер межет придти nil, в этом случае сохранение в файл не работает
 func NewStorage(helper *utils.FileHelper, err error) *MapStorage {
 	if err != nil {
+		data := &sync.Map{}
 		return &MapStorage{
-			data: &sync.Map{},
+			data:    data,
+			stats:   newStatsCounters(data),
+			users:   newUserStore(),
+			apiKeys: newAPIKeyStore(),
 		}
 	}
 	tempMap := helper.ReadFile()
 	return &MapStorage{
-		data:   tempMap,
-		helper: helper,
+		data:    tempMap,
+		helper:  helper,
+		stats:   newStatsCounters(tempMap),
+		users:   newUserStore(),
+		apiKeys: newAPIKeyStore(),
 	}
 }
 
 // GetURL реализация получения единичной ссылки
-func (m *MapStorage) GetURL(id string) (string, error) {
+func (m *MapStorage) GetURL(_ context.Context, id string) (string, error) {
 	userURL, ok := m.data.Load(id)
 	if !ok {
 		return "", errors.New("original url not found")
 	}
+	if userURL.(entity.UserURL).IsDeleted {
+		return "", internalerrors.ErrDeleted
+	}
+	if userURL.(entity.UserURL).IsDisabled {
+		return "", internalerrors.ErrDisabled
+	}
 	s := userURL.(entity.UserURL).OriginalURL
+	m.stats.click(time.Now())
 	return s, nil
 }
 
+// Ping проверка хранилища: в памяти оно доступно всегда, файл должен существовать
+func (m *MapStorage) Ping(_ context.Context) error {
+	if m.helper == nil {
+		return nil
+	}
+	return m.helper.Check()
+}
+
+// store сохранение новой ссылки с учетом в статистике
+func (m *MapStorage) store(ctx context.Context, shortURL string, userURL entity.UserURL) entity.UserURL {
+	if userURL.CreatedAt.IsZero() {
+		userURL.CreatedAt = time.Now()
+	}
+	_, loaded := m.data.LoadOrStore(shortURL, userURL)
+	if loaded {
+		logger.FromCtx(ctx).Warn("key is already in the storage", zap.String("short_url", shortURL))
+		return userURL
+	}
+	m.stats.add(userURL)
+	if m.helper != nil {
+		m.helper.WriteFile(lenSyncMap(m.data), shortURL, userURL)
+	}
+	return userURL
+}
+
 // SetURL реализация установки единичной ссылки
-func (m *MapStorage) SetURL(shortURL string, originalURL string, userID string) (string, error) {
+func (m *MapStorage) SetURL(ctx context.Context, shortURL string, originalURL string, owner entity.Owner) (string, error) {
 
 	userURL := entity.UserURL{
-		UserID:      userID,
+		UserID:      owner.UserID,
 		OriginalURL: originalURL,
+		WorkspaceID: owner.WorkspaceID,
 	}
 	result, err := m.GetKey(userURL)
 	switch {
 	case errors.Is(err, internalerrors.ErrNotFound):
 		{
-			_, loaded := m.data.LoadOrStore(shortURL, userURL)
-			if m.helper != nil {
-				m.helper.WriteFile(lenSyncMap(m.data), shortURL, userURL)
-			}
-			if loaded {
-				log.Println("key is already in the storage")
-			}
+			m.store(ctx, shortURL, userURL)
 			return shortURL, nil
 		}
 	case errors.Is(err, internalerrors.ErrOriginalURLAlreadyExists):
@@ -73,7 +116,7 @@ func (m *MapStorage) SetURL(shortURL string, originalURL string, userID string)
 }
 
 // SetURLBatch пакетное сохранение ссылок в файл
-func (m *MapStorage) SetURLBatch(u map[string]entity.UserURL) (map[string]entity.UserURL, error) {
+func (m *MapStorage) SetURLBatch(ctx context.Context, u map[string]entity.UserURL) (map[string]entity.UserURL, error) {
 	returned := make(map[string]entity.UserURL)
 	var possibleDoubleError error
 	for s := range u {
@@ -81,14 +124,7 @@ func (m *MapStorage) SetURLBatch(u map[string]entity.UserURL) (map[string]entity
 		switch {
 		case errors.Is(err, internalerrors.ErrNotFound):
 			{
-				_, loaded := m.data.LoadOrStore(s, u[s])
-				if m.helper != nil {
-					m.helper.WriteFile(lenSyncMap(m.data), s, u[s])
-				}
-				if loaded {
-					log.Println("key is already in the storage")
-				}
-				returned[s] = u[s]
+				returned[s] = m.store(ctx, s, u[s])
 			}
 		case errors.Is(err, internalerrors.ErrOriginalURLAlreadyExists):
 			{
@@ -104,17 +140,19 @@ func (m *MapStorage) SetURLBatch(u map[string]entity.UserURL) (map[string]entity
 	return returned, possibleDoubleError
 }
 
-// GetUserUrls получение пользовательских ссылок по фильтру ИД пользователя
-func (m *MapStorage) GetUserUrls(userID string) (any, error) {
+// GetUserUrls получение ссылок владельца: личных ссылок пользователя или ссылок пространства
+func (m *MapStorage) GetUserUrls(_ context.Context, owner entity.Owner) (any, error) {
+	m.claimMu.RLock()
+	defer m.claimMu.RUnlock()
 	result := make([]entity.UserURLEntity, 0)
 	m.data.Range(func(key, value interface{}) bool {
-		if value.(entity.UserURL).UserID == userID {
+		u := value.(entity.UserURL)
+		if owner.Owns(u) && !u.IsDeleted {
 			result = append(result, entity.UserURLEntity{
 				ShortURL:    key.(string),
-				OriginalURL: value.(entity.UserURL).OriginalURL})
-			return true
+				OriginalURL: u.OriginalURL})
 		}
-		return false
+		return true
 	})
 	if len(result) == 0 {
 		return nil, internalerrors.ErrNotFound
@@ -123,13 +161,31 @@ func (m *MapStorage) GetUserUrls(userID string) (any, error) {
 }
 
 // DeleteUserURLs асинхронное удаление ссылок
-func (m *MapStorage) DeleteUserURLs(userID string, group *sync.WaitGroup) (deletedURLs chan string, err error) {
+//
+// Как и в PostgreSQL, ссылки владельца помечаются удаленными, чужие ключи пропускаются.
+func (m *MapStorage) DeleteUserURLs(_ context.Context, owner entity.Owner, group *sync.WaitGroup) (deletedURLs chan string, err error) {
 	deletedURLs = make(chan string)
 	group.Add(1)
 	go func() {
-		group.Done()
+		defer group.Done()
 		for key := range deletedURLs {
-			m.data.Delete(key)
+			value, ok := m.data.Load(key)
+			if !ok {
+				continue
+			}
+			u := value.(entity.UserURL)
+			if !owner.Owns(u) || u.IsDeleted {
+				continue
+			}
+			deleted := u
+			deleted.IsDeleted = true
+			deleted.DeletedAt = time.Now()
+			if m.data.CompareAndSwap(key, value, deleted) {
+				m.stats.markDeleted(u.UserID, deleted.DeletedAt)
+			}
+		}
+		if m.helper == nil {
+			return
 		}
 		err := m.helper.RMFile(m.data)
 		if err != nil {
@@ -144,12 +200,13 @@ func (m *MapStorage) GetKey(userURL entity.UserURL) (string, error) {
 	var storedKey string
 	ok := false
 	m.data.Range(func(key, value interface{}) bool {
-		if value.(entity.UserURL).OriginalURL == userURL.OriginalURL {
+		u := value.(entity.UserURL)
+		if u.OriginalURL == userURL.OriginalURL && !u.IsDeleted {
 			storedKey = key.(string)
 			ok = true
 			return false
 		}
-		return false
+		return true
 	})
 	if ok {
 		return storedKey, internalerrors.ErrOriginalURLAlreadyExists
@@ -158,22 +215,22 @@ func (m *MapStorage) GetKey(userURL entity.UserURL) (string, error) {
 	}
 }
 
-// GetStats функция статистики пользователя и ссылок
-func (m *MapStorage) GetStats() (usersCount int, URLsCount int, statError error) {
-	URLsCount = lenSyncMap(m.data)
-	userArray := make([]string, 0)
-	m.data.Range(func(key, value interface{}) bool {
-		if value.(entity.UserURL).UserID != "" {
-			userArray = append(userArray, value.(entity.UserURL).UserID)
-		}
-		return true
-	})
-	uniqueUserList := map[string]bool{}
-	for _, key := range userArray {
-		uniqueUserList[key] = true
+// GetStats статистика из счетчиков, без обхода всех ссылок
+func (m *MapStorage) GetStats(_ context.Context) (entity.Stats, error) {
+	return m.stats.snapshot(), nil
+}
+
+// CountUserURLs активные ссылки пользователя по счетчикам статистики
+func (m *MapStorage) CountUserURLs(_ context.Context, userID string) (int, error) {
+	return m.stats.active(userID), nil
+}
+
+// GetTimeSeries события по интервалам [from, to)
+func (m *MapStorage) GetTimeSeries(_ context.Context, from, to time.Time, interval time.Duration) ([]entity.TimeBucket, error) {
+	if err := entity.ValidateTimeRange(from, to, interval); err != nil {
+		return nil, err
 	}
-	usersCount = len(uniqueUserList)
-	return usersCount, URLsCount, nil
+	return m.stats.timeSeries(from, to, interval), nil
 }
 
 // lenSyncMap размер map
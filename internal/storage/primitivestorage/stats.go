@@ -0,0 +1,162 @@
+// Счетчики статистики для хранилища в памяти
+package primitivestorage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+)
+
+// statsWindow окно для подсчета недавно созданных ссылок
+const statsWindow = 24 * time.Hour
+
+// statsCounters счетчики, которые обновляются при сохранении и удалении ссылок
+//
+// Ведутся так же, как счетчики триггера urls_stats в PostgreSQL.
+type statsCounters struct {
+	mu      sync.Mutex
+	total   int
+	deleted int
+	users   map[string]int                  // активные ссылки пользователя
+	created []time.Time                     // время создания ссылок за окно, по возрастанию
+	hourly  map[time.Time]entity.TimeBucket // события по часам для временного ряда
+}
+
+// newStatsCounters инициализация счетчиков по уже загруженным данным
+func newStatsCounters(data *sync.Map) *statsCounters {
+	sc := &statsCounters{users: make(map[string]int), hourly: make(map[time.Time]entity.TimeBucket)}
+	data.Range(func(_, value interface{}) bool {
+		sc.add(value.(entity.UserURL))
+		return true
+	})
+	sort.Slice(sc.created, func(i, j int) bool { return sc.created[i].Before(sc.created[j]) })
+	return sc
+}
+
+// add учет новой ссылки
+func (sc *statsCounters) add(u entity.UserURL) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.total++
+	if u.IsDeleted {
+		sc.deleted++
+		if !u.DeletedAt.IsZero() {
+			sc.updateHour(u.DeletedAt, func(b *entity.TimeBucket) { b.Deleted++ })
+		}
+	} else if u.UserID != "" {
+		sc.users[u.UserID]++
+	}
+	if time.Since(u.CreatedAt) < statsWindow {
+		sc.created = append(sc.created, u.CreatedAt)
+	}
+	if !u.CreatedAt.IsZero() {
+		sc.updateHour(u.CreatedAt, func(b *entity.TimeBucket) { b.Created++ })
+	}
+}
+
+// updateHour изменение почасового интервала, вызывается под блокировкой
+func (sc *statsCounters) updateHour(t time.Time, fn func(b *entity.TimeBucket)) {
+	start := entity.BucketStart(t, entity.IntervalHour)
+	b := sc.hourly[start]
+	fn(&b)
+	sc.hourly[start] = b
+}
+
+// click учет перехода по ссылке
+func (sc *statsCounters) click(t time.Time) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.updateHour(t, func(b *entity.TimeBucket) { b.Clicked++ })
+}
+
+// markDeleted учет удаления ссылки пользователя
+func (sc *statsCounters) markDeleted(userID string, at time.Time) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.deleted++
+	sc.updateHour(at, func(b *entity.TimeBucket) { b.Deleted++ })
+	if userID == "" {
+		return
+	}
+	if sc.users[userID] <= 1 {
+		delete(sc.users, userID)
+	} else {
+		sc.users[userID]--
+	}
+}
+
+// move учет переноса активных ссылок другому пользователю
+func (sc *statsCounters) move(fromUserID, toUserID string, active int) {
+	if active == 0 {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.users[fromUserID] <= active {
+		delete(sc.users, fromUserID)
+	} else {
+		sc.users[fromUserID] -= active
+	}
+	sc.users[toUserID] += active
+}
+
+// active активные ссылки пользователя
+func (sc *statsCounters) active(userID string) int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.users[userID]
+}
+
+// snapshot текущие значения счетчиков
+func (sc *statsCounters) snapshot() entity.Stats {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	//Отбрасываем ссылки, вышедшие из окна
+	border := time.Now().Add(-statsWindow)
+	i := sort.Search(len(sc.created), func(i int) bool { return sc.created[i].After(border) })
+	sc.created = sc.created[i:]
+
+	stats := entity.Stats{
+		Total:          sc.total,
+		Active:         sc.total - sc.deleted,
+		Deleted:        sc.deleted,
+		CreatedLastDay: len(sc.created),
+		Users:          len(sc.users),
+		TopUsers:       make([]entity.UserStat, 0, len(sc.users)),
+	}
+	for userID, count := range sc.users {
+		stats.TopUsers = append(stats.TopUsers, entity.UserStat{UserID: userID, URLs: count})
+	}
+	sort.Slice(stats.TopUsers, func(i, j int) bool {
+		if stats.TopUsers[i].URLs == stats.TopUsers[j].URLs {
+			return stats.TopUsers[i].UserID < stats.TopUsers[j].UserID
+		}
+		return stats.TopUsers[i].URLs > stats.TopUsers[j].URLs
+	})
+	if len(stats.TopUsers) > entity.TopUsersLimit {
+		stats.TopUsers = stats.TopUsers[:entity.TopUsersLimit]
+	}
+	return stats
+}
+
+// timeSeries сводка почасовых интервалов в интервалы interval
+func (sc *statsCounters) timeSeries(from, to time.Time, interval time.Duration) []entity.TimeBucket {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	first := entity.BucketStart(from, interval)
+	sparse := make(map[time.Time]entity.TimeBucket)
+	for hour, b := range sc.hourly {
+		if hour.Before(first) || !hour.Before(to) {
+			continue
+		}
+		start := entity.BucketStart(hour, interval)
+		acc := sparse[start]
+		acc.Created += b.Created
+		acc.Deleted += b.Deleted
+		acc.Clicked += b.Clicked
+		sparse[start] = acc
+	}
+	return entity.FillTimeSeries(from, to, interval, sparse)
+}
@@ -0,0 +1,71 @@
+package primitivestorage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SversusN/shortener/internal/internalerrors"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+)
+
+func TestGetStats(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage(nil, errors.New("dont need file"))
+	_, err := s.SetURL(ctx, "k1", "http://example1.com", entity.Owner{UserID: "user1"})
+	require.NoError(t, err)
+	_, err = s.SetURL(ctx, "k2", "http://example2.com", entity.Owner{UserID: "user1"})
+	require.NoError(t, err)
+	_, err = s.SetURLBatch(ctx, map[string]entity.UserURL{
+		"k3": {UserID: "user2", OriginalURL: "http://example3.com"},
+	})
+	require.NoError(t, err)
+
+	wg := &sync.WaitGroup{}
+	ch, err := s.DeleteUserURLs(ctx, entity.Owner{UserID: "user2"}, wg)
+	require.NoError(t, err)
+	ch <- "k3"
+	ch <- "k1" //чужая ссылка не удаляется
+	close(ch)
+	wg.Wait()
+
+	stats, err := s.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Total)
+	assert.Equal(t, 2, stats.Active)
+	assert.Equal(t, 1, stats.Deleted)
+	assert.Equal(t, 3, stats.CreatedLastDay)
+	assert.Equal(t, 1, stats.Users)
+	assert.Equal(t, []entity.UserStat{{UserID: "user1", URLs: 2}}, stats.TopUsers)
+
+	_, err = s.GetURL(ctx, "k3")
+	assert.ErrorIs(t, err, internalerrors.ErrDeleted)
+}
+
+func TestGetTimeSeries(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage(nil, errors.New("dont need file"))
+	_, err := s.SetURL(ctx, "k1", "http://example1.com", entity.Owner{UserID: "user1"})
+	require.NoError(t, err)
+	_, err = s.GetURL(ctx, "k1")
+	require.NoError(t, err)
+	_, err = s.GetURL(ctx, "k1")
+	require.NoError(t, err)
+
+	to := time.Now()
+	buckets, err := s.GetTimeSeries(ctx, to.Add(-48*time.Hour), to, entity.IntervalDay)
+	require.NoError(t, err)
+	require.Len(t, buckets, 3)
+	last := buckets[len(buckets)-1]
+	assert.Equal(t, 1, last.Created)
+	assert.Equal(t, 2, last.Clicked)
+	assert.Equal(t, 0, buckets[0].Created)
+
+	_, err = s.GetTimeSeries(ctx, to, to.Add(-time.Hour), entity.IntervalHour)
+	assert.ErrorIs(t, err, entity.ErrBadTimeRange)
+}
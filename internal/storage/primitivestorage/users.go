@@ -0,0 +1,210 @@
+package primitivestorage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SversusN/shortener/internal/internalerrors"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+)
+
+// userStore пользователи в памяти с дозаписью в файл
+type userStore struct {
+	mu         sync.RWMutex
+	byLogin    map[string]entity.User
+	identities map[identityKey]entity.Identity
+	access     map[string]entity.UserAccess // роли и блокировки, без записи - пользователь без блокировки
+	ids        map[string]struct{}          // ИД зарегистрированных и удаленных пользователей
+	workspaces map[string]entity.Workspace
+	members    map[string]map[string]entity.WorkspaceMember // участники по ИД пространства и пользователя
+	file       *os.File                                     // nil - пользователи только в памяти
+}
+
+// identityKey субъект уникален в пределах издателя
+type identityKey struct {
+	issuer, subject string
+}
+
+// userRecord строка файла пользователей: пользователь с паролем, внешний пользователь,
+// изменение роли, удаление пользователя или изменение пространства; при загрузке побеждает последняя запись
+type userRecord struct {
+	*entity.User
+	Identity      *entity.Identity        `json:"identity,omitempty"`
+	Access        *entity.UserAccess      `json:"access,omitempty"`
+	DeletedUser   string                  `json:"deleted_user,omitempty"`
+	Workspace     *entity.Workspace       `json:"workspace,omitempty"`
+	Member        *entity.WorkspaceMember `json:"member,omitempty"`
+	RemovedMember *entity.WorkspaceMember `json:"removed_member,omitempty"`
+}
+
+// newUserStore пустое хранилище пользователей в памяти
+func newUserStore() *userStore {
+	return &userStore{
+		byLogin:    make(map[string]entity.User),
+		identities: make(map[identityKey]entity.Identity),
+		access:     make(map[string]entity.UserAccess),
+		ids:        make(map[string]struct{}),
+		workspaces: make(map[string]entity.Workspace),
+		members:    make(map[string]map[string]entity.WorkspaceMember),
+	}
+}
+
+// OpenUsers загрузка пользователей из файла и дозапись в него новых
+//
+// Файл хранится отдельно от файла ссылок: тот перезаписывается после удаления ссылок.
+func (m *MapStorage) OpenUsers(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open users file: %w", err)
+	}
+	scanner := bufio.NewScanner(file)
+	m.users.mu.Lock()
+	defer m.users.mu.Unlock()
+	for scanner.Scan() {
+		var rec userRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			file.Close()
+			return fmt.Errorf("read users file: %w", err)
+		}
+		switch {
+		case rec.Identity != nil:
+			m.users.identities[identityKey{rec.Identity.Issuer, rec.Identity.Subject}] = *rec.Identity
+			m.users.ids[rec.Identity.UserID] = struct{}{}
+		case rec.Access != nil:
+			m.users.access[rec.Access.UserID] = *rec.Access
+		case rec.DeletedUser != "":
+			m.users.remove(rec.DeletedUser)
+		case rec.Workspace != nil:
+			m.users.workspaces[rec.Workspace.ID] = *rec.Workspace
+		case rec.Member != nil:
+			m.users.setMember(*rec.Member)
+		case rec.RemovedMember != nil:
+			delete(m.users.members[rec.RemovedMember.WorkspaceID], rec.RemovedMember.UserID)
+		case rec.User != nil:
+			m.users.byLogin[rec.Login] = *rec.User
+			m.users.ids[rec.ID] = struct{}{}
+		default:
+			file.Close()
+			return errors.New("read users file: empty record")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return fmt.Errorf("read users file: %w", err)
+	}
+	m.users.file = file
+	return nil
+}
+
+// CreateUser регистрация пользователя, логин уникален
+func (m *MapStorage) CreateUser(_ context.Context, u entity.User) error {
+	m.users.mu.Lock()
+	defer m.users.mu.Unlock()
+	if _, ok := m.users.byLogin[u.Login]; ok {
+		return internalerrors.ErrLoginTaken
+	}
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	if err := m.users.append(userRecord{User: &u}); err != nil {
+		return err
+	}
+	m.users.byLogin[u.Login] = u
+	m.users.ids[u.ID] = struct{}{}
+	return nil
+}
+
+// GetOrCreateIdentity ИД пользователя для субъекта издателя, при первом входе субъект связывается с identity.UserID
+func (m *MapStorage) GetOrCreateIdentity(_ context.Context, identity entity.Identity) (string, error) {
+	key := identityKey{identity.Issuer, identity.Subject}
+	m.users.mu.Lock()
+	defer m.users.mu.Unlock()
+	if existing, ok := m.users.identities[key]; ok {
+		return existing.UserID, nil
+	}
+	if identity.CreatedAt.IsZero() {
+		identity.CreatedAt = time.Now()
+	}
+	if err := m.users.append(userRecord{Identity: &identity}); err != nil {
+		return "", err
+	}
+	m.users.identities[key] = identity
+	m.users.ids[identity.UserID] = struct{}{}
+	return identity.UserID, nil
+}
+
+// append дозапись строки в файл пользователей, вызывается под блокировкой mu
+func (s *userStore) append(rec userRecord) error {
+	if s.file == nil {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write users file: %w", err)
+	}
+	return nil
+}
+
+// GetUserByLogin пользователь по логину
+func (m *MapStorage) GetUserByLogin(_ context.Context, login string) (entity.User, error) {
+	m.users.mu.RLock()
+	defer m.users.mu.RUnlock()
+	u, ok := m.users.byLogin[login]
+	if !ok {
+		return entity.User{}, internalerrors.ErrUserNotFound
+	}
+	return u, nil
+}
+
+// ClaimURLs перенос ссылок анонимного пользователя в учетную запись
+//
+// Перенос идет под блокировкой записи claimMu, поэтому GetUserUrls не видит
+// частично перенесенные ссылки. Параллельное удаление ссылки учитывается через CompareAndSwap.
+func (m *MapStorage) ClaimURLs(_ context.Context, fromUserID, toUserID string) (int, error) {
+	m.users.mu.RLock()
+	_, registered := m.users.ids[fromUserID]
+	m.users.mu.RUnlock()
+	if registered {
+		return 0, internalerrors.ErrNotAnonymous
+	}
+	m.claimMu.Lock()
+	defer m.claimMu.Unlock()
+	claimed, active := 0, 0
+	m.data.Range(func(key, value interface{}) bool {
+		for {
+			u := value.(entity.UserURL)
+			if u.UserID != fromUserID {
+				return true
+			}
+			moved := u
+			moved.UserID = toUserID
+			if m.data.CompareAndSwap(key, value, moved) {
+				claimed++
+				if !u.IsDeleted {
+					active++
+				}
+				return true
+			}
+			value, _ = m.data.Load(key)
+		}
+	})
+	if claimed == 0 {
+		return 0, nil
+	}
+	m.stats.move(fromUserID, toUserID, active)
+	if m.helper != nil {
+		if err := m.helper.RMFile(m.data); err != nil {
+			return claimed, err
+		}
+	}
+	return claimed, nil
+}
@@ -0,0 +1,183 @@
+package primitivestorage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SversusN/shortener/internal/internalerrors"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+)
+
+func TestClaimURLs(t *testing.T) {
+	ctx := context.Background()
+	m := NewStorage(nil, errors.New("no file"))
+	require.NoError(t, m.CreateUser(ctx, entity.User{ID: "account", Login: "alice"}))
+	require.NoError(t, m.CreateUser(ctx, entity.User{ID: "other", Login: "bob"}))
+	_, err := m.SetURL(ctx, "a1", "https://example.com/1", entity.Owner{UserID: "anon"})
+	require.NoError(t, err)
+	_, err = m.SetURL(ctx, "a2", "https://example.com/2", entity.Owner{UserID: "anon"})
+	require.NoError(t, err)
+	_, err = m.SetURL(ctx, "b1", "https://example.com/3", entity.Owner{UserID: "account"})
+	require.NoError(t, err)
+	//Удаленная ссылка тоже переходит к учетной записи, но не считается активной
+	ch, err := m.DeleteUserURLs(ctx, entity.Owner{UserID: "anon"}, &sync.WaitGroup{})
+	require.NoError(t, err)
+	ch <- "a2"
+	close(ch)
+	require.Eventually(t, func() bool { return m.stats.snapshot().Deleted == 1 }, time.Second, time.Millisecond)
+
+	claimed, err := m.ClaimURLs(ctx, "anon", "account")
+	require.NoError(t, err)
+	assert.Equal(t, 2, claimed)
+	urls, err := m.GetUserUrls(ctx, entity.Owner{UserID: "account"})
+	require.NoError(t, err)
+	assert.Len(t, urls, 2)
+	_, err = m.GetUserUrls(ctx, entity.Owner{UserID: "anon"})
+	assert.ErrorIs(t, err, internalerrors.ErrNotFound)
+	stats, err := m.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Users)
+	assert.Equal(t, []entity.UserStat{{UserID: "account", URLs: 2}}, stats.TopUsers)
+
+	//Повтор ничего не переносит, ссылки зарегистрированного пользователя не забираются
+	claimed, err = m.ClaimURLs(ctx, "anon", "account")
+	require.NoError(t, err)
+	assert.Zero(t, claimed)
+	_, err = m.ClaimURLs(ctx, "account", "other")
+	assert.ErrorIs(t, err, internalerrors.ErrNotAnonymous)
+}
+
+func TestIdentities(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.json")
+	m := NewStorage(nil, errors.New("no file"))
+	require.NoError(t, m.OpenUsers(path))
+	require.NoError(t, m.CreateUser(ctx, entity.User{ID: "account", Login: "alice"}))
+	userID, err := m.GetOrCreateIdentity(ctx, entity.Identity{Issuer: "https://idp", Subject: "alice", UserID: "sso"})
+	require.NoError(t, err)
+	assert.Equal(t, "sso", userID)
+	userID, err = m.GetOrCreateIdentity(ctx, entity.Identity{Issuer: "https://idp", Subject: "alice", UserID: "new"})
+	require.NoError(t, err)
+	assert.Equal(t, "sso", userID)
+
+	//После перезапуска связь сохраняется, ссылки пользователя провайдера не забираются
+	restarted := NewStorage(nil, errors.New("no file"))
+	require.NoError(t, restarted.OpenUsers(path))
+	userID, err = restarted.GetOrCreateIdentity(ctx, entity.Identity{Issuer: "https://idp", Subject: "alice", UserID: "new"})
+	require.NoError(t, err)
+	assert.Equal(t, "sso", userID)
+	_, err = restarted.GetUserByLogin(ctx, "alice")
+	require.NoError(t, err)
+	_, err = restarted.ClaimURLs(ctx, "sso", "account")
+	assert.ErrorIs(t, err, internalerrors.ErrNotAnonymous)
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.json")
+	m := NewStorage(nil, errors.New("no file"))
+	require.NoError(t, m.OpenUsers(path))
+	require.NoError(t, m.CreateUser(ctx, entity.User{ID: "bob", Login: "bob"}))
+	require.NoError(t, m.SetUserAccess(ctx, entity.UserAccess{UserID: "bob", Role: "auditor"}))
+	_, err := m.SetURL(ctx, "b1", "https://example.com/1", entity.Owner{UserID: "bob"})
+	require.NoError(t, err)
+	_, err = m.SetURL(ctx, "b2", "https://example.com/2", entity.Owner{UserID: "bob"})
+	require.NoError(t, err)
+	require.NoError(t, m.DeleteURL(ctx, "b1"))
+
+	deleted, err := m.DeleteUser(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	_, err = m.GetURL(ctx, "b2")
+	assert.ErrorIs(t, err, internalerrors.ErrDeleted)
+
+	//После перезапуска логина нет, ИД заблокирован с прежней ролью
+	restarted := NewStorage(nil, errors.New("no file"))
+	require.NoError(t, restarted.OpenUsers(path))
+	_, err = restarted.GetUserByLogin(ctx, "bob")
+	assert.ErrorIs(t, err, internalerrors.ErrUserNotFound)
+	access, err := restarted.GetUserAccess(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserAccess{UserID: "bob", Role: "auditor", Disabled: true}, access)
+}
+
+func TestWorkspaces(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.json")
+	m := NewStorage(nil, errors.New("no file"))
+	require.NoError(t, m.OpenUsers(path))
+	ws := entity.Workspace{ID: "team", Name: "Team"}
+	require.NoError(t, m.CreateWorkspace(ctx, ws, entity.WorkspaceMember{UserID: "alice", Role: "owner"}))
+	require.NoError(t, m.SetWorkspaceMember(ctx, entity.WorkspaceMember{WorkspaceID: "team", UserID: "bob", Role: "editor"}))
+	require.NoError(t, m.SetWorkspaceMember(ctx, entity.WorkspaceMember{WorkspaceID: "team", UserID: "carol", Role: "viewer"}))
+	require.NoError(t, m.RemoveWorkspaceMember(ctx, "team", "carol"))
+	assert.ErrorIs(t, m.SetWorkspaceMember(ctx, entity.WorkspaceMember{WorkspaceID: "missing", UserID: "bob", Role: "editor"}), internalerrors.ErrNotFound)
+
+	//Ссылка пространства не попадает в личный список создателя
+	_, err := m.SetURL(ctx, "t1", "https://example.com/team", entity.Owner{UserID: "bob", WorkspaceID: "team"})
+	require.NoError(t, err)
+	urls, err := m.GetUserUrls(ctx, entity.Owner{UserID: "alice", WorkspaceID: "team"})
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+	_, err = m.GetUserUrls(ctx, entity.Owner{UserID: "bob"})
+	assert.ErrorIs(t, err, internalerrors.ErrNotFound)
+
+	//После перезапуска пространство и участники сохраняются
+	restarted := NewStorage(nil, errors.New("no file"))
+	require.NoError(t, restarted.OpenUsers(path))
+	list, err := restarted.ListWorkspaces(ctx, "bob")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "Team", list[0].Name)
+	assert.Equal(t, "editor", list[0].Role)
+	_, err = restarted.GetWorkspaceMember(ctx, "team", "carol")
+	assert.ErrorIs(t, err, internalerrors.ErrNotFound)
+	members, err := restarted.ListWorkspaceMembers(ctx, "team")
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+}
+
+func TestWorkspaceOwners(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.json")
+	m := NewStorage(nil, errors.New("no file"))
+	require.NoError(t, m.OpenUsers(path))
+	require.NoError(t, m.CreateWorkspace(ctx, entity.Workspace{ID: "team", Name: "Team"}, entity.WorkspaceMember{UserID: "alice", Role: "owner"}))
+	require.NoError(t, m.SetWorkspaceMember(ctx, entity.WorkspaceMember{WorkspaceID: "team", UserID: "bob", Role: "editor"}))
+	require.NoError(t, m.SetWorkspaceMember(ctx, entity.WorkspaceMember{WorkspaceID: "team", UserID: "carol", Role: "viewer"}))
+	require.NoError(t, m.CreateWorkspace(ctx, entity.Workspace{ID: "solo", Name: "Solo"}, entity.WorkspaceMember{UserID: "alice", Role: "owner"}))
+
+	//Последний владелец не понижается и не исключается
+	assert.ErrorIs(t, m.SetWorkspaceMember(ctx, entity.WorkspaceMember{WorkspaceID: "team", UserID: "alice", Role: "editor"}), internalerrors.ErrLastOwner)
+	assert.ErrorIs(t, m.RemoveWorkspaceMember(ctx, "team", "alice"), internalerrors.ErrLastOwner)
+
+	//При удалении владельца пространство переходит самому давнему участнику,
+	//ссылки пространства без других участников удаляются
+	_, err := m.SetURL(ctx, "s1", "https://example.com/solo", entity.Owner{UserID: "alice", WorkspaceID: "solo"})
+	require.NoError(t, err)
+	_, err = m.SetURL(ctx, "t1", "https://example.com/team", entity.Owner{UserID: "alice", WorkspaceID: "team"})
+	require.NoError(t, err)
+	deleted, err := m.DeleteUser(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	_, err = m.GetURL(ctx, "s1")
+	assert.ErrorIs(t, err, internalerrors.ErrDeleted)
+	_, err = m.GetURL(ctx, "t1")
+	assert.NoError(t, err)
+
+	restarted := NewStorage(nil, errors.New("no file"))
+	require.NoError(t, restarted.OpenUsers(path))
+	bob, err := restarted.GetWorkspaceMember(ctx, "team", "bob")
+	require.NoError(t, err)
+	assert.Equal(t, "owner", bob.Role)
+	carol, err := restarted.GetWorkspaceMember(ctx, "team", "carol")
+	require.NoError(t, err)
+	assert.Equal(t, "viewer", carol.Role)
+}
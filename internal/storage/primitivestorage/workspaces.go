@@ -0,0 +1,156 @@
+package primitivestorage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/SversusN/shortener/internal/internalerrors"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+)
+
+// CreateWorkspace сохранение пространства вместе с первым участником
+func (m *MapStorage) CreateWorkspace(_ context.Context, ws entity.Workspace, owner entity.WorkspaceMember) error {
+	m.users.mu.Lock()
+	defer m.users.mu.Unlock()
+	if ws.CreatedAt.IsZero() {
+		ws.CreatedAt = time.Now()
+	}
+	owner.WorkspaceID, owner.CreatedAt = ws.ID, ws.CreatedAt
+	if err := m.users.append(userRecord{Workspace: &ws}); err != nil {
+		return err
+	}
+	if err := m.users.append(userRecord{Member: &owner}); err != nil {
+		return err
+	}
+	m.users.workspaces[ws.ID] = ws
+	m.users.setMember(owner)
+	return nil
+}
+
+// GetWorkspaceMember участник пространства
+func (m *MapStorage) GetWorkspaceMember(_ context.Context, workspaceID, userID string) (entity.WorkspaceMember, error) {
+	m.users.mu.RLock()
+	defer m.users.mu.RUnlock()
+	member, ok := m.users.members[workspaceID][userID]
+	if !ok {
+		return entity.WorkspaceMember{}, internalerrors.ErrNotFound
+	}
+	return member, nil
+}
+
+// ListWorkspaces пространства пользователя по времени создания
+func (m *MapStorage) ListWorkspaces(_ context.Context, userID string) ([]entity.WorkspaceMembership, error) {
+	m.users.mu.RLock()
+	defer m.users.mu.RUnlock()
+	result := make([]entity.WorkspaceMembership, 0)
+	for id, members := range m.users.members {
+		if member, ok := members[userID]; ok {
+			result = append(result, entity.WorkspaceMembership{Workspace: m.users.workspaces[id], Role: member.Role})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result, nil
+}
+
+// ListWorkspaceMembers участники пространства по времени добавления
+func (m *MapStorage) ListWorkspaceMembers(_ context.Context, workspaceID string) ([]entity.WorkspaceMember, error) {
+	m.users.mu.RLock()
+	defer m.users.mu.RUnlock()
+	result := make([]entity.WorkspaceMember, 0, len(m.users.members[workspaceID]))
+	for _, member := range m.users.members[workspaceID] {
+		result = append(result, member)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result, nil
+}
+
+// SetWorkspaceMember добавление участника или смена его роли, время добавления сохраняется
+func (m *MapStorage) SetWorkspaceMember(_ context.Context, member entity.WorkspaceMember) error {
+	m.users.mu.Lock()
+	defer m.users.mu.Unlock()
+	if _, ok := m.users.workspaces[member.WorkspaceID]; !ok {
+		return internalerrors.ErrNotFound
+	}
+	if member.Role != entity.WorkspaceRoleOwner && m.users.lastOwner(member.WorkspaceID, member.UserID) {
+		return internalerrors.ErrLastOwner
+	}
+	if existing, ok := m.users.members[member.WorkspaceID][member.UserID]; ok {
+		member.CreatedAt = existing.CreatedAt
+	} else if member.CreatedAt.IsZero() {
+		member.CreatedAt = time.Now()
+	}
+	if err := m.users.append(userRecord{Member: &member}); err != nil {
+		return err
+	}
+	m.users.setMember(member)
+	return nil
+}
+
+// RemoveWorkspaceMember исключение участника, последний владелец не исключается
+func (m *MapStorage) RemoveWorkspaceMember(_ context.Context, workspaceID, userID string) error {
+	m.users.mu.Lock()
+	defer m.users.mu.Unlock()
+	member, ok := m.users.members[workspaceID][userID]
+	if !ok {
+		return internalerrors.ErrNotFound
+	}
+	if m.users.lastOwner(workspaceID, userID) {
+		return internalerrors.ErrLastOwner
+	}
+	if err := m.users.append(userRecord{RemovedMember: &member}); err != nil {
+		return err
+	}
+	delete(m.users.members[workspaceID], userID)
+	return nil
+}
+
+// setMember сохранение участника в памяти, вызывается под блокировкой mu
+func (s *userStore) setMember(member entity.WorkspaceMember) {
+	if s.members[member.WorkspaceID] == nil {
+		s.members[member.WorkspaceID] = make(map[string]entity.WorkspaceMember)
+	}
+	s.members[member.WorkspaceID][member.UserID] = member
+}
+
+// lastOwner userID - единственный владелец пространства, вызывается под блокировкой mu
+func (s *userStore) lastOwner(workspaceID, userID string) bool {
+	members := s.members[workspaceID]
+	if members[userID].Role != entity.WorkspaceRoleOwner {
+		return false
+	}
+	for id, member := range members {
+		if id != userID && member.Role == entity.WorkspaceRoleOwner {
+			return false
+		}
+	}
+	return true
+}
+
+// heirs передача владения пространствами, где userID - последний владелец, самому давнему участнику
+//
+// Возвращает новых владельцев и пространства без других участников. Вызывается под блокировкой mu.
+func (s *userStore) heirs(userID string) (owners []entity.WorkspaceMember, abandoned map[string]bool) {
+	abandoned = make(map[string]bool)
+	for workspaceID, members := range s.members {
+		if _, ok := members[userID]; !ok {
+			continue
+		}
+		if len(members) == 1 {
+			abandoned[workspaceID] = true
+			continue
+		}
+		if !s.lastOwner(workspaceID, userID) {
+			continue
+		}
+		var heir entity.WorkspaceMember
+		for id, member := range members {
+			if id != userID && (heir.UserID == "" || member.CreatedAt.Before(heir.CreatedAt)) {
+				heir = member
+			}
+		}
+		heir.Role = entity.WorkspaceRoleOwner
+		owners = append(owners, heir)
+	}
+	return owners, abandoned
+}
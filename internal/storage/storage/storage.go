@@ -2,21 +2,109 @@
 package storage
 
 import (
-	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+	"context"
 	"sync"
+	"time"
+
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
 )
 
 // Storage интерфейс описания методов хранилища
 type Storage interface {
-	GetURL(id string) (string, error)
-	SetURL(id string, targetURL string, userID string) (string, error)
-	SetURLBatch(u map[string]entity.UserURL) (map[string]entity.UserURL, error)
-	GetUserUrls(userID string) (any, error)
-	DeleteUserURLs(userID string, group *sync.WaitGroup) (chan string, error)
-	GetStats() (int, int, error)
+	GetURL(ctx context.Context, id string) (string, error)
+	SetURL(ctx context.Context, id string, targetURL string, owner entity.Owner) (string, error)
+	SetURLBatch(ctx context.Context, u map[string]entity.UserURL) (map[string]entity.UserURL, error)
+	// GetUserUrls ссылки владельца: личные ссылки пользователя или все ссылки пространства
+	GetUserUrls(ctx context.Context, owner entity.Owner) (any, error)
+	// DeleteUserURLs удаляет ключи из канала, ссылки других владельцев пропускаются
+	DeleteUserURLs(ctx context.Context, owner entity.Owner, group *sync.WaitGroup) (chan string, error)
+	GetStats(ctx context.Context) (entity.Stats, error)
+	GetTimeSeries(ctx context.Context, from, to time.Time, interval time.Duration) ([]entity.TimeBucket, error)
 }
 
 // Pinger интерфейс для проверки соединения PostgreSQL
 type Pinger interface {
-	Ping() error
+	Ping(ctx context.Context) error
+}
+
+// UserStore хранилище зарегистрированных пользователей
+type UserStore interface {
+	AccessStore
+	// CreateUser сохраняет пользователя, занятый логин - internalerrors.ErrLoginTaken
+	CreateUser(ctx context.Context, u entity.User) error
+	// GetUserByLogin пользователь по логину, отсутствие - internalerrors.ErrUserNotFound
+	GetUserByLogin(ctx context.Context, login string) (entity.User, error)
+	// ClaimURLs переносит все ссылки анонимного пользователя fromUserID пользователю toUserID
+	// и возвращает их число. Повтор переносит только новые ссылки, для зарегистрированного
+	// fromUserID - internalerrors.ErrNotAnonymous.
+	ClaimURLs(ctx context.Context, fromUserID, toUserID string) (int, error)
+	// GetOrCreateIdentity ИД пользователя для субъекта издателя, при первом входе
+	// субъект связывается с identity.UserID
+	GetOrCreateIdentity(ctx context.Context, identity entity.Identity) (string, error)
+}
+
+// AccessStore роли и блокировки пользователей
+type AccessStore interface {
+	// GetUserAccess роль и блокировка, без записи - роль пользователя без блокировки
+	GetUserAccess(ctx context.Context, userID string) (entity.UserAccess, error)
+	SetUserAccess(ctx context.Context, access entity.UserAccess) error
+}
+
+// AdminStore операции администратора над ссылками и пользователями
+type AdminStore interface {
+	AccessStore
+	// ListURLs ссылки всех пользователей, новые первыми
+	ListURLs(ctx context.Context, filter entity.URLFilter) ([]entity.URLInfo, error)
+	// SetURLDisabled блокировка ссылки, отсутствие - internalerrors.ErrNotFound
+	SetURLDisabled(ctx context.Context, shortURL string, disabled bool) error
+	// DeleteURL удаление ссылки любого пользователя, отсутствие - internalerrors.ErrNotFound
+	DeleteURL(ctx context.Context, shortURL string) error
+	// ListUsers зарегистрированные пользователи, новые первыми
+	ListUsers(ctx context.Context, filter entity.UserFilter) ([]entity.UserInfo, error)
+	// DeleteUser удаляет учетную запись, связи с провайдерами и ключи API, помечает ссылки
+	// удаленными и блокирует ИД, чтобы выданные токены не продлевались. Возвращает число ссылок.
+	// Пространство последнего владельца переходит самому давнему участнику, без участников его ссылки удаляются.
+	DeleteUser(ctx context.Context, userID string) (int, error)
+}
+
+// WorkspaceStore рабочие пространства и их участники
+type WorkspaceStore interface {
+	// CreateWorkspace сохраняет пространство вместе с первым участником
+	CreateWorkspace(ctx context.Context, ws entity.Workspace, owner entity.WorkspaceMember) error
+	// GetWorkspaceMember участник пространства, отсутствие - internalerrors.ErrNotFound
+	GetWorkspaceMember(ctx context.Context, workspaceID, userID string) (entity.WorkspaceMember, error)
+	// ListWorkspaces пространства пользователя по времени создания
+	ListWorkspaces(ctx context.Context, userID string) ([]entity.WorkspaceMembership, error)
+	// ListWorkspaceMembers участники пространства по времени добавления
+	ListWorkspaceMembers(ctx context.Context, workspaceID string) ([]entity.WorkspaceMember, error)
+	// SetWorkspaceMember добавляет участника или меняет его роль,
+	// понижение последнего владельца - internalerrors.ErrLastOwner
+	SetWorkspaceMember(ctx context.Context, m entity.WorkspaceMember) error
+	// RemoveWorkspaceMember исключает участника, отсутствие - internalerrors.ErrNotFound,
+	// последнего владельца - internalerrors.ErrLastOwner
+	RemoveWorkspaceMember(ctx context.Context, workspaceID, userID string) error
+}
+
+// APIKeyStore хранилище ключей API
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, k entity.APIKey) error
+	// GetAPIKey ключ по ИД, отсутствие - internalerrors.ErrNotFound
+	GetAPIKey(ctx context.Context, id string) (entity.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]entity.APIKey, error)
+	// DeleteAPIKey отзыв ключа пользователя, чужой или отсутствующий ключ - internalerrors.ErrNotFound
+	DeleteAPIKey(ctx context.Context, userID, id string) error
+	// TouchAPIKey обновление времени последнего использования
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+}
+
+// RateLimitStore корзины токенов ограничения запросов
+type RateLimitStore interface {
+	// TakeToken списывает токен из корзины key, без токена возвращает false и время до его появления
+	TakeToken(ctx context.Context, key string, bucket entity.TokenBucket) (bool, time.Duration, error)
+}
+
+// QuotaStore подсчет ссылок для квот пользователей
+type QuotaStore interface {
+	// CountUserURLs активные ссылки, созданные пользователем, включая ссылки пространств
+	CountUserURLs(ctx context.Context, userID string) (int, error)
 }
@@ -0,0 +1,169 @@
+// Package tlsconf собирает конфигурацию TLS серверов из файлов сертификатов или autocert.
+package tlsconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// clientNamespace пространство имен uuid версии 5 для ИД клиентов mTLS
+var clientNamespace = uuid.MustParse("745a2574-afd1-40ae-ad6c-157d2fa6ec72")
+
+// ErrNoCertificate не задан ни файл сертификата, ни хосты autocert
+var ErrNoCertificate = errors.New("tls: set certificate and key files or autocert hosts")
+
+// Options источник сертификата и политика TLS
+type Options struct {
+	CertFile         string   // файл сертификата в PEM, вместе с цепочкой
+	KeyFile          string   // файл закрытого ключа в PEM
+	AutocertHosts    []string // хосты для сертификатов Let's Encrypt, если файлы не заданы
+	AutocertCacheDir string   // каталог для сертификатов autocert
+	MinVersion       string   // минимальная версия: 1.2 или 1.3
+}
+
+// Provider конфигурация TLS сервера
+type Provider struct {
+	Config  *tls.Config
+	manager *autocert.Manager // nil при сертификате из файлов
+}
+
+// cipherSuites шифры TLS 1.2: только ECDHE с AEAD, для TLS 1.3 набор фиксирован
+var cipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+}
+
+// ParseVersion разбор минимальной версии TLS, пустая строка - 1.2
+func ParseVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("tls: unsupported min version %q", v)
+	}
+}
+
+// New создает конфигурацию по настройкам
+//
+// Файлы сертификата имеют приоритет над autocert и проверяются сразу,
+// чтобы ошибка обнаружилась при старте, а не на первом соединении.
+func New(opts Options) (*Provider, error) {
+	minVersion, err := ParseVersion(opts.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion:       minVersion,
+		CipherSuites:     cipherSuites,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+	}
+	p := &Provider{Config: cfg}
+	switch {
+	case opts.CertFile != "" || opts.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: load key pair: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	case len(opts.AutocertHosts) > 0:
+		p.manager = &autocert.Manager{
+			// директория для хранения сертификатов
+			Cache: autocert.DirCache(opts.AutocertCacheDir),
+			// функция, принимающая Terms of Service издателя сертификатов
+			Prompt: autocert.AcceptTOS,
+			// перечень доменов, для которых будут поддерживаться сертификаты
+			HostPolicy: autocert.HostWhitelist(opts.AutocertHosts...),
+		}
+		cfg.GetCertificate = p.manager.GetCertificate
+		cfg.NextProtos = []string{"h2", "http/1.1", "acme-tls/1"}
+	default:
+		return nil, ErrNoCertificate
+	}
+	return p, nil
+}
+
+// GRPCConfig конфигурация TLS для gRPC сервера
+//
+// Сертификат тот же, что у HTTPS. Непустой clientCAFile включает mTLS:
+// клиент обязан предъявить сертификат, подписанный одним из CA файла.
+func (p *Provider) GRPCConfig(clientCAFile string) (*tls.Config, error) {
+	cfg := p.Config.Clone()
+	cfg.NextProtos = []string{"h2"}
+	if clientCAFile == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("tls: no certificates in %s", clientCAFile)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
+
+// ClientIdentity имя клиента из сертификата mTLS
+//
+// Берется CommonName субъекта, без него - первое имя SAN: DNS, URI, затем email.
+func ClientIdentity(cert *x509.Certificate) string {
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	}
+	return ""
+}
+
+// ClientUserID постоянный ИД пользователя uuid для клиента mTLS, пустой без имени в сертификате
+//
+// Ссылки в БД принадлежат пользователям с ИД uuid, поэтому имя из ClientIdentity напрямую не используется.
+func ClientUserID(cert *x509.Certificate) string {
+	identity := ClientIdentity(cert)
+	if identity == "" {
+		return ""
+	}
+	return uuid.NewSHA1(clientNamespace, []byte(identity)).String()
+}
+
+// RedirectHandler обработчик HTTP сервера, перенаправляющий на HTTPS адрес httpsAddr
+//
+// Для autocert он же отвечает на проверку HTTP-01.
+func (p *Provider) RedirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+	if p.manager != nil {
+		return p.manager.HTTPHandler(redirect)
+	}
+	return redirect
+}
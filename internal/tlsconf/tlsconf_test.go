@@ -0,0 +1,75 @@
+package tlsconf
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SversusN/shortener/internal/tlsconf/tlstest"
+)
+
+func TestNew(t *testing.T) {
+	_, err := New(Options{})
+	assert.ErrorIs(t, err, ErrNoCertificate)
+	_, err = New(Options{CertFile: "missing.pem", KeyFile: "missing.pem"})
+	assert.Error(t, err)
+	_, err = New(Options{AutocertHosts: []string{"example.com"}, MinVersion: "1.0"})
+	assert.Error(t, err)
+
+	p, err := New(Options{AutocertHosts: []string{"example.com"}, AutocertCacheDir: t.TempDir()})
+	require.NoError(t, err)
+	assert.NotNil(t, p.Config.GetCertificate)
+}
+
+func TestServerTLS(t *testing.T) {
+	ca := tlstest.NewCA(t, "test-ca")
+	pair := ca.Server(t)
+	p, err := New(Options{CertFile: pair.CertFile, KeyFile: pair.KeyFile, MinVersion: "1.2"})
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = p.Config
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := func(maxVersion uint16) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:    ca.Pool,
+			MaxVersion: maxVersion,
+		}}}
+	}
+
+	resp, err := client(tls.VersionTLS12).Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, uint16(tls.VersionTLS12), resp.TLS.Version)
+
+	//Версии ниже минимальной отклоняются при рукопожатии
+	_, err = client(tls.VersionTLS11).Get(srv.URL)
+	assert.Error(t, err)
+}
+
+func TestRedirectHandler(t *testing.T) {
+	p := &Provider{}
+	tests := []struct {
+		httpsAddr string
+		location  string
+	}{
+		{":443", "https://example.com/abc?x=1"},
+		{":8443", "https://example.com:8443/abc?x=1"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://example.com:80/abc?x=1", nil)
+		p.RedirectHandler(tt.httpsAddr).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+		assert.Equal(t, tt.location, rec.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,144 @@
+// Package tlstest выпускает одноразовые сертификаты для тестов TLS.
+package tlstest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// CA тестовый удостоверяющий центр
+type CA struct {
+	Cert    *x509.Certificate
+	Pool    *x509.CertPool // пул с сертификатом CA для проверки
+	CertPEM []byte
+	key     *ecdsa.PrivateKey
+	serial  int64
+}
+
+// Pair выпущенный сертификат и файлы с ним
+type Pair struct {
+	TLS      tls.Certificate
+	CertFile string
+	KeyFile  string
+}
+
+// NewCA создает самоподписанный CA со сроком действия на время теста
+func NewCA(t testing.TB, name string) *CA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &CA{
+		Cert:    cert,
+		Pool:    pool,
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:     key,
+		serial:  1,
+	}
+}
+
+// WriteCert записывает сертификат CA в файл во временном каталоге теста
+func (ca *CA) WriteCert(t testing.TB) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(file, ca.CertPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// Server выпускает серверный сертификат для localhost и 127.0.0.1 и дополнительных имен
+func (ca *CA) Server(t testing.TB, dnsNames ...string) Pair {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    append([]string{"localhost"}, dnsNames...),
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	return ca.issue(t, tmpl)
+}
+
+// Client выпускает клиентский сертификат с CommonName и DNS именами SAN
+func (ca *CA) Client(t testing.TB, commonName string, dnsNames ...string) Pair {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: commonName},
+		DNSNames:    dnsNames,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	return ca.issue(t, tmpl)
+}
+
+// issue подпись сертификата и запись пары в файлы
+func (ca *CA) issue(t testing.TB, tmpl *x509.Certificate) Pair {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.serial++
+	tmpl.SerialNumber = big.NewInt(ca.serial)
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.Cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Leaf заполняется X509KeyPair не во всех версиях Go
+	if pair.Leaf, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	p := Pair{TLS: pair, CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")}
+	if err := os.WriteFile(p.CertFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p.KeyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataCarrier адаптер gRPC metadata для пропагатора OpenTelemetry
+type MetadataCarrier metadata.MD
+
+// Get первое значение ключа
+func (mc MetadataCarrier) Get(key string) string {
+	values := metadata.MD(mc).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Set установка значения ключа
+func (mc MetadataCarrier) Set(key, value string) {
+	metadata.MD(mc).Set(key, value)
+}
+
+// Keys список ключей
+func (mc MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(mc))
+	for k := range mc {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// StartGRPCSpan создает серверный спан gRPC вызова с родителем из входящих метаданных
+func StartGRPCSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, MetadataCarrier(md))
+	}
+	return tracer().Start(ctx, fullMethod,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.RPCSystemGRPC, semconv.RPCMethod(fullMethod)))
+}
+
+// EndGRPCSpan завершает спан gRPC вызова с кодом ответа
+func EndGRPCSpan(span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+	"github.com/SversusN/shortener/internal/storage/storage"
+)
+
+// tracedStorage хранилище с дочерним спаном на каждый вызов
+type tracedStorage struct {
+	s storage.Storage
+}
+
+// tracedPinger хранилище со спанами и проверкой соединения
+type tracedPinger struct {
+	*tracedStorage
+	p storage.Pinger
+}
+
+// NewStorage оборачивает хранилище трассировкой
+//
+// Если хранилище поддерживает Ping, обертка тоже реализует storage.Pinger.
+func NewStorage(s storage.Storage) storage.Storage {
+	ts := &tracedStorage{s: s}
+	if p, ok := s.(storage.Pinger); ok {
+		return &tracedPinger{tracedStorage: ts, p: p}
+	}
+	return ts
+}
+
+// startSpan дочерний спан операции хранилища
+func startSpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+}
+
+// endSpan завершение спана с ошибкой операции
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// GetURL получение ссылки
+func (ts *tracedStorage) GetURL(ctx context.Context, id string) (string, error) {
+	ctx, span := startSpan(ctx, "GetURL", attribute.String("short_url", id))
+	url, err := ts.s.GetURL(ctx, id)
+	endSpan(span, err)
+	return url, err
+}
+
+// SetURL сохранение ссылки
+func (ts *tracedStorage) SetURL(ctx context.Context, id string, targetURL string, owner entity.Owner) (string, error) {
+	ctx, span := startSpan(ctx, "SetURL", attribute.String("short_url", id))
+	result, err := ts.s.SetURL(ctx, id, targetURL, owner)
+	endSpan(span, err)
+	return result, err
+}
+
+// SetURLBatch пакетное сохранение ссылок
+func (ts *tracedStorage) SetURLBatch(ctx context.Context, u map[string]entity.UserURL) (map[string]entity.UserURL, error) {
+	ctx, span := startSpan(ctx, "SetURLBatch", attribute.Int("batch_size", len(u)))
+	result, err := ts.s.SetURLBatch(ctx, u)
+	endSpan(span, err)
+	return result, err
+}
+
+// GetUserUrls получение ссылок пользователя
+func (ts *tracedStorage) GetUserUrls(ctx context.Context, owner entity.Owner) (any, error) {
+	ctx, span := startSpan(ctx, "GetUserUrls")
+	result, err := ts.s.GetUserUrls(ctx, owner)
+	endSpan(span, err)
+	return result, err
+}
+
+// DeleteUserURLs запуск удаления ссылок пользователя
+func (ts *tracedStorage) DeleteUserURLs(ctx context.Context, owner entity.Owner, group *sync.WaitGroup) (chan string, error) {
+	ctx, span := startSpan(ctx, "DeleteUserURLs")
+	result, err := ts.s.DeleteUserURLs(ctx, owner, group)
+	endSpan(span, err)
+	return result, err
+}
+
+// GetStats статистика хранилища
+func (ts *tracedStorage) GetStats(ctx context.Context) (entity.Stats, error) {
+	ctx, span := startSpan(ctx, "GetStats")
+	stats, err := ts.s.GetStats(ctx)
+	endSpan(span, err)
+	return stats, err
+}
+
+// GetTimeSeries временной ряд статистики
+func (ts *tracedStorage) GetTimeSeries(ctx context.Context, from, to time.Time, interval time.Duration) ([]entity.TimeBucket, error) {
+	ctx, span := startSpan(ctx, "GetTimeSeries", attribute.String("interval", interval.String()))
+	buckets, err := ts.s.GetTimeSeries(ctx, from, to, interval)
+	endSpan(span, err)
+	return buckets, err
+}
+
+// Ping проверка соединения
+func (tp *tracedPinger) Ping(ctx context.Context) error {
+	ctx, span := startSpan(ctx, "Ping")
+	err := tp.p.Ping(ctx)
+	endSpan(span, err)
+	return err
+}
@@ -0,0 +1,76 @@
+// Package tracing содержит настройку трассировки OpenTelemetry.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Экспортеры трассировки
+const (
+	ExporterNone   = "none"   // трассировка выключена
+	ExporterOTLP   = "otlp"   // OTLP по gRPC
+	ExporterStdout = "stdout" // вывод спанов в stdout, для отладки и тестов
+)
+
+// tracerName имя трассировщика сервиса
+const tracerName = "github.com/SversusN/shortener"
+
+// serviceName имя сервиса в ресурсах спанов
+const serviceName = "shortener"
+
+// Setup настраивает глобальный провайдер трассировки и пропагатор W3C traceparent
+//
+// Возвращает функцию остановки, которая отправляет накопленные спаны.
+func Setup(ctx context.Context, exporter string, endpoint string, insecure bool) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	var (
+		exp sdktrace.SpanExporter
+		err error
+	)
+	switch exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+		if insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exp, err = otlptracegrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing exporter: %w", err)
+	}
+	tp := NewProvider(sdktrace.WithBatcher(exp))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// NewProvider создает провайдер с ресурсом сервиса
+//
+// В тестах используется с sdktrace.WithSyncer и экспортером tracetest.InMemoryExporter.
+func NewProvider(opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, opts...)...)
+}
+
+// tracer трассировщик из глобального провайдера
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/SversusN/shortener/internal/storage/primitivestorage"
+)
+
+func TestHTTPMW(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := NewProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer tp.Shutdown(context.Background())
+
+	s := NewStorage(primitivestorage.NewStorage(nil, errors.New("dont need file")))
+	r := chi.NewRouter()
+	r.Use(HTTPMW)
+	r.Get("/{shortKey}", func(w http.ResponseWriter, r *http.Request) {
+		_, err := s.GetURL(r.Context(), chi.URLParam(r, "shortKey"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	req := httptest.NewRequest(http.MethodGet, "/sk", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	storageSpan, serverSpan := spans[0], spans[1]
+	assert.Equal(t, "storage.GetURL", storageSpan.Name)
+	assert.Equal(t, "GET /{shortKey}", serverSpan.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", serverSpan.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", serverSpan.Parent.SpanID().String())
+	assert.Equal(t, serverSpan.SpanContext.SpanID(), storageSpan.Parent.SpanID())
+}
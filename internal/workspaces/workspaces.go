@@ -0,0 +1,167 @@
+// Package workspaces рабочие пространства: общие ссылки команды с ролями участников.
+//
+// Активное пространство запроса выбирается заголовком X-Workspace-ID или метаданными
+// gRPC x-workspace-id. С ним создание, список и удаление ссылок работают со ссылками
+// пространства, без него - с личными ссылками пользователя.
+package workspaces
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/SversusN/shortener/internal/auth"
+	"github.com/SversusN/shortener/internal/internalerrors"
+	entity "github.com/SversusN/shortener/internal/storage/dbstorage"
+	"github.com/SversusN/shortener/internal/storage/storage"
+)
+
+// Передача активного пространства в запросе
+const (
+	Header  = "X-Workspace-ID" // заголовок HTTP
+	MetaKey = "x-workspace-id" // ключ метаданных gRPC
+)
+
+// Роли участников пространства
+const (
+	RoleOwner  = entity.WorkspaceRoleOwner // управляет участниками, создает и удаляет ссылки
+	RoleEditor = "editor"                  // создает и удаляет ссылки
+	RoleViewer = "viewer"                  // только просматривает ссылки
+)
+
+// Roles все роли участников
+var Roles = []string{RoleOwner, RoleEditor, RoleViewer}
+
+// maxNameLen ограничение длины имени пространства
+const maxNameLen = 100
+
+// Ошибки пространств
+var (
+	ErrInvalidName = errors.New("workspace name must be 1-100 characters")
+	ErrInvalidRole = errors.New("role must be one of owner, editor, viewer")
+	ErrNotMember   = errors.New("not a member of the workspace")
+	ErrForbidden   = errors.New("only workspace owners can manage members")
+	ErrLastOwner   = internalerrors.ErrLastOwner
+	ErrNotFound    = internalerrors.ErrNotFound
+)
+
+// memberKey ключ участника активного пространства в контексте
+type memberKey struct{}
+
+// WithMember сохраняет участие пользователя в активном пространстве в контексте
+func WithMember(ctx context.Context, m entity.WorkspaceMember) context.Context {
+	return context.WithValue(ctx, memberKey{}, m)
+}
+
+// MemberFromCtx участие пользователя в активном пространстве, false - пространство не выбрано
+func MemberFromCtx(ctx context.Context) (entity.WorkspaceMember, bool) {
+	m, ok := ctx.Value(memberKey{}).(entity.WorkspaceMember)
+	return m, ok
+}
+
+// OwnerFromCtx владелец ссылок запроса: активное пространство или пользователь
+func OwnerFromCtx(ctx context.Context) (entity.Owner, error) {
+	userID, err := auth.UserIDFromCtx(ctx)
+	if err != nil {
+		return entity.Owner{}, err
+	}
+	m, _ := MemberFromCtx(ctx)
+	return entity.Owner{UserID: userID, WorkspaceID: m.WorkspaceID}, nil
+}
+
+// CanWrite запросу разрешено создавать и удалять ссылки: личные ссылки или роль не ниже editor
+func CanWrite(ctx context.Context) bool {
+	m, ok := MemberFromCtx(ctx)
+	return !ok || m.Role == RoleOwner || m.Role == RoleEditor
+}
+
+// Service пространства и их участники
+type Service struct {
+	store storage.WorkspaceStore
+}
+
+// New создает сервис пространств
+func New(store storage.WorkspaceStore) *Service {
+	return &Service{store: store}
+}
+
+// Create создание пространства, создатель становится владельцем
+func (s *Service) Create(ctx context.Context, userID, name string) (entity.WorkspaceMembership, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLen {
+		return entity.WorkspaceMembership{}, ErrInvalidName
+	}
+	ws := entity.Workspace{ID: uuid.NewString(), Name: name, CreatedAt: time.Now()}
+	if err := s.store.CreateWorkspace(ctx, ws, entity.WorkspaceMember{UserID: userID, Role: RoleOwner}); err != nil {
+		return entity.WorkspaceMembership{}, err
+	}
+	return entity.WorkspaceMembership{Workspace: ws, Role: RoleOwner}, nil
+}
+
+// List пространства пользователя с его ролями
+func (s *Service) List(ctx context.Context, userID string) ([]entity.WorkspaceMembership, error) {
+	return s.store.ListWorkspaces(ctx, userID)
+}
+
+// Resolve участие пользователя в пространстве, не участнику - ErrNotMember
+func (s *Service) Resolve(ctx context.Context, userID, workspaceID string) (entity.WorkspaceMember, error) {
+	if _, err := uuid.Parse(workspaceID); err != nil {
+		return entity.WorkspaceMember{}, ErrNotMember
+	}
+	m, err := s.store.GetWorkspaceMember(ctx, workspaceID, userID)
+	if errors.Is(err, internalerrors.ErrNotFound) {
+		return entity.WorkspaceMember{}, ErrNotMember
+	}
+	return m, err
+}
+
+// Members участники пространства, видны любому участнику
+func (s *Service) Members(ctx context.Context, userID, workspaceID string) ([]entity.WorkspaceMember, error) {
+	if _, err := s.Resolve(ctx, userID, workspaceID); err != nil {
+		return nil, err
+	}
+	return s.store.ListWorkspaceMembers(ctx, workspaceID)
+}
+
+// SetMember добавление участника или смена его роли владельцем пространства
+func (s *Service) SetMember(ctx context.Context, userID, workspaceID, memberID, role string) error {
+	if !slices.Contains(Roles, role) {
+		return ErrInvalidRole
+	}
+	if _, err := uuid.Parse(memberID); err != nil {
+		return ErrNotFound
+	}
+	if err := s.requireOwner(ctx, userID, workspaceID); err != nil {
+		return err
+	}
+	return s.store.SetWorkspaceMember(ctx, entity.WorkspaceMember{WorkspaceID: workspaceID, UserID: memberID, Role: role})
+}
+
+// RemoveMember исключение участника владельцем или выход участника из пространства
+func (s *Service) RemoveMember(ctx context.Context, userID, workspaceID, memberID string) error {
+	if userID == memberID {
+		if _, err := s.Resolve(ctx, userID, workspaceID); err != nil {
+			return err
+		}
+	} else if err := s.requireOwner(ctx, userID, workspaceID); err != nil {
+		return err
+	}
+	return s.store.RemoveWorkspaceMember(ctx, workspaceID, memberID)
+}
+
+// requireOwner пользователь - владелец пространства
+func (s *Service) requireOwner(ctx context.Context, userID, workspaceID string) error {
+	m, err := s.Resolve(ctx, userID, workspaceID)
+	if err != nil {
+		return err
+	}
+	if m.Role != RoleOwner {
+		return ErrForbidden
+	}
+	return nil
+}